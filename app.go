@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// appTokenRefreshMargin is how long before its expiry an installation token is refreshed
+const appTokenRefreshMargin = 5 * time.Minute
+
+// appAcceptHeader is the media type of GitHub Apps API, which is in preview
+const appAcceptHeader = "application/vnd.github.machine-man-preview+json"
+
+// AppTokenSource is an oauth2.TokenSource which authenticates as an installation of a GitHub App.
+// It signs a JWT with the private key of the app, exchanges it for an installation token, and refreshes it before expiry
+type AppTokenSource struct {
+	AppID int64
+
+	// InstallationID is the installation of the app on the repository, looked up from Owner and Repo if 0
+	InstallationID int64
+	Owner, Repo    string
+
+	Key     *rsa.PrivateKey
+	BaseURL *url.URL
+
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// NewAppTokenSource creates a new AppTokenSource. baseURL is the url of GitHub API ending with a slash
+func NewAppTokenSource(appID, installationID int64, owner, repo string, key *rsa.PrivateKey, baseURL *url.URL) *AppTokenSource {
+	return &AppTokenSource{
+		AppID:          appID,
+		InstallationID: installationID,
+		Owner:          owner,
+		Repo:           repo,
+		Key:            key,
+		BaseURL:        baseURL,
+		client:         http.DefaultClient,
+		now:            time.Now,
+	}
+}
+
+// Token returns the cached installation token, or a new one if it is about to expire
+func (s *AppTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != nil && s.now().Add(appTokenRefreshMargin).Before(s.token.Expiry) {
+		return s.token, nil
+	}
+
+	jwt, err := s.jwt()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if s.InstallationID == 0 {
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+
+		if err := s.do("GET", fmt.Sprintf("repos/%s/%s/installation", s.Owner, s.Repo), jwt, nil, &installation); err != nil {
+			return nil, errors.Wrapf(err, "failed to find an installation of GitHub App %d on %s/%s", s.AppID, s.Owner, s.Repo)
+		}
+
+		s.InstallationID = installation.ID
+	}
+
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	// The token is scoped to the repository, so that it cannot touch the other repositories of the installation
+	scope := map[string][]string{"repositories": {s.Repo}}
+
+	if err := s.do("POST", fmt.Sprintf("installations/%d/access_tokens", s.InstallationID), jwt, scope, &token); err != nil {
+		return nil, errors.Wrapf(err, "failed to create an installation token of GitHub App %d", s.AppID)
+	}
+
+	s.token = &oauth2.Token{AccessToken: token.Token, TokenType: "token", Expiry: token.ExpiresAt}
+
+	return s.token, nil
+}
+
+// Permissions returns the permissions of the installation, e.g. {"contents": "write"}
+func (s *AppTokenSource) Permissions() (map[string]string, error) {
+	// Token looks up the installation if needed
+	if _, err := s.Token(); err != nil {
+		return nil, err
+	}
+
+	jwt, err := s.jwt()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var installation struct {
+		Permissions map[string]string `json:"permissions"`
+	}
+
+	if err := s.do("GET", fmt.Sprintf("app/installations/%d", s.InstallationID), jwt, nil, &installation); err != nil {
+		return nil, errors.Wrapf(err, "failed to get permissions of GitHub App %d", s.AppID)
+	}
+
+	return installation.Permissions, nil
+}
+
+// jwt signs a JSON Web Token with RS256 to authenticate as the app. It is valid for 10 minutes at most,
+// and issued a minute ago to allow clock drift
+func (s *AppTokenSource) jwt() (string, error) {
+	now := s.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d,"exp":%d,"iss":%d}`, now.Add(-time.Minute).Unix(), now.Add(9*time.Minute).Unix(), s.AppID)))
+
+	digest := sha256.Sum256([]byte(header + "." + claims))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest[:])
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign a JWT of GitHub App")
+	}
+
+	return header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// do sends a request authenticated as the app with in as a JSON body unless it is nil, and decodes the response into v
+func (s *AppTokenSource) do(method, path, jwt string, in, v interface{}) error {
+	u, err := s.BaseURL.Parse(path)
+
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+
+	if in != nil {
+		b, err := json.Marshal(in)
+
+		if err != nil {
+			return errors.Wrap(err, "failed to encode a request body")
+		}
+
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", appAcceptHeader)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := s.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+
+		json.Unmarshal(content, &e)
+
+		return errors.Errorf("%s %s: %s %s", method, u, res.Status, e.Message)
+	}
+
+	return json.NewDecoder(bytes.NewReader(content)).Decode(v)
+}
+
+// ParseAppKey parses a private key of a GitHub App in PEM, either PKCS#1 or PKCS#8
+func ParseAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, errors.New("invalid private key of GitHub App: no PEM data is found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid private key of GitHub App")
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+
+	if !ok {
+		return nil, errors.New("invalid private key of GitHub App: key must be RSA")
+	}
+
+	return rsaKey, nil
+}
+
+// LoadAppKey loads a private key of a GitHub App from a PEM file, or from PEM itself
+func LoadAppKey(pathOrPEM string) (*rsa.PrivateKey, error) {
+	if strings.HasPrefix(strings.TrimSpace(pathOrPEM), "-----BEGIN") {
+		return ParseAppKey([]byte(pathOrPEM))
+	}
+
+	data, err := ioutil.ReadFile(pathOrPEM)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read a private key of GitHub App")
+	}
+
+	return ParseAppKey(data)
+}
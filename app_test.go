@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAppKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+
+	if err != nil {
+		t.Fatalf("failed to generate a key: %s", err)
+	}
+
+	return key
+}
+
+// testAppServer stands in for GitHub API which issues installation tokens, and verifies JWTs with key
+func testAppServer(t *testing.T, key *rsa.PrivateKey, now func() time.Time) (*httptest.Server, *int) {
+	issued := 0
+	mux := http.NewServeMux()
+
+	verify := func(w http.ResponseWriter, r *http.Request) bool {
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+
+		if len(parts) != 3 {
+			http.Error(w, `{"message": "A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+			return false
+		}
+
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+			return false
+		}
+
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims struct {
+			Iat, Exp, Iss int64
+		}
+
+		if err := json.Unmarshal(payload, &claims); err != nil || claims.Iss != 1234 || claims.Exp-claims.Iat > 600 {
+			http.Error(w, `{"message": "Invalid claims"}`, http.StatusUnauthorized)
+			return false
+		}
+
+		return true
+	}
+
+	mux.HandleFunc("/repos/o/r/installation", func(w http.ResponseWriter, r *http.Request) {
+		if verify(w, r) {
+			fmt.Fprint(w, `{"id": 42}`)
+		}
+	})
+
+	mux.HandleFunc("/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Fatalf("invalid method: want: POST, got: %s", r.Method)
+		}
+
+		var scope struct {
+			Repositories []string `json:"repositories"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&scope); err != nil || len(scope.Repositories) != 1 || scope.Repositories[0] != "r" {
+			http.Error(w, `{"message": "The token is supposed to be scoped to the repository"}`, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if verify(w, r) {
+			issued++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": "v1.token%d", "expires_at": %q}`, issued, now().Add(time.Hour).UTC().Format(time.RFC3339))
+		}
+	})
+
+	mux.HandleFunc("/app/installations/42", func(w http.ResponseWriter, r *http.Request) {
+		if verify(w, r) {
+			fmt.Fprint(w, `{"id": 42, "permissions": {"contents": "write", "pull_requests": "read", "metadata": "read"}}`)
+		}
+	})
+
+	return httptest.NewServer(mux), &issued
+}
+
+func TestAppTokenSource(t *testing.T) {
+	key := testAppKey(t)
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	server, issued := testAppServer(t, key, clock)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL + "/")
+	ts := NewAppTokenSource(1234, 0, "o", "r", key, baseURL)
+	ts.now = clock
+
+	cases := []struct {
+		after     time.Duration
+		wantToken string
+	}{
+		{after: 0, wantToken: "v1.token1"},
+		{after: 30 * time.Minute, wantToken: "v1.token1"},
+		{after: 56 * time.Minute, wantToken: "v1.token2"},
+	}
+
+	start := now
+
+	for i, tc := range cases {
+		now = start.Add(tc.after)
+		token, err := ts.Token()
+
+		if err != nil {
+			t.Fatalf("#%d Token failed: %s", i, err)
+		}
+
+		if token.AccessToken != tc.wantToken {
+			t.Fatalf("#%d invalid token: want: %s, got: %s", i, tc.wantToken, token.AccessToken)
+		}
+	}
+
+	if ts.InstallationID != 42 || *issued != 2 {
+		t.Fatalf("invalid installation or number of issued tokens: installation: %d, issued: %d", ts.InstallationID, *issued)
+	}
+}
+
+func TestAppTokenSourceFail(t *testing.T) {
+	key := testAppKey(t)
+	server, _ := testAppServer(t, key, time.Now)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL + "/")
+
+	cases := []*AppTokenSource{
+		NewAppTokenSource(1234, 0, "o", "unknown", key, baseURL),
+		NewAppTokenSource(1234, 43, "o", "r", key, baseURL),
+		NewAppTokenSource(5678, 42, "o", "r", key, baseURL),
+		NewAppTokenSource(1234, 42, "o", "r", testAppKey(t), baseURL),
+	}
+
+	for i, ts := range cases {
+		if _, err := ts.Token(); err == nil {
+			t.Fatalf("#%d Token is supposed to fail", i)
+		}
+	}
+}
+
+func TestAppTokenSourcePermissions(t *testing.T) {
+	key := testAppKey(t)
+	server, _ := testAppServer(t, key, time.Now)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL + "/")
+	perms, err := NewAppTokenSource(1234, 0, "o", "r", key, baseURL).Permissions()
+
+	if err != nil {
+		t.Fatalf("Permissions failed: %s", err)
+	}
+
+	if perms["contents"] != "write" || perms["pull_requests"] != "read" {
+		t.Fatalf("invalid permissions: %v", perms)
+	}
+
+	if missing := missingAppPermissions(perms); len(missing) != 1 {
+		t.Fatalf("invalid missing permissions: %q", missing)
+	}
+}
+
+func TestParseAppKey(t *testing.T) {
+	key := testAppKey(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+
+	if err != nil {
+		t.Fatalf("failed to marshal a key: %s", err)
+	}
+
+	cases := []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	}
+
+	for i, tc := range cases {
+		got, err := ParseAppKey(pem.EncodeToMemory(tc))
+
+		if err != nil {
+			t.Fatalf("#%d ParseAppKey failed: %s", i, err)
+		}
+
+		if got.N.Cmp(key.N) != 0 {
+			t.Fatalf("#%d ParseAppKey returns another key", i)
+		}
+	}
+
+	if _, err := ParseAppKey([]byte("not a key")); err == nil {
+		t.Fatalf("ParseAppKey is supposed to fail")
+	}
+}
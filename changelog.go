@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Changelog is the name of a Keep a Changelog style changelog file
+const Changelog = "CHANGELOG.md"
+
+var unreleasedHeadingRegex = regexp.MustCompile(`(?i)^##\s+\[?unreleased\]?\s*$`)
+
+var linkDefinitionRegex = regexp.MustCompile(`^\[([^\]]+)\]:\s*(\S+)`)
+
+// updateChangelog adds a `## [next] - date` section to a Keep a Changelog style changelog.
+// The section is placed under `## [Unreleased]`, taking over its entries, or at the top of
+// the versions if there is no Unreleased section. Commits are listed in the section as well,
+// and the comparison links at the bottom are updated
+func updateChangelog(content, current, next, date string, ccs *ComparedCommits) (string, error) {
+	lines := strings.Split(content, "\n")
+
+	for _, l := range lines {
+		if strings.HasPrefix(l, "## ["+next+"]") || strings.HasPrefix(l, "## "+next+" ") {
+			return "", errors.Errorf("%s already has a section of %s", Changelog, next)
+		}
+	}
+
+	section := []string{fmt.Sprintf("## [%s] - %s", next, date), ""}
+	unreleased := -1
+	insertAt := -1
+
+	for i, l := range lines {
+		if unreleasedHeadingRegex.MatchString(l) {
+			unreleased = i
+			break
+		}
+
+		if strings.HasPrefix(l, "## ") || linkDefinitionRegex.MatchString(l) {
+			insertAt = i
+			break
+		}
+	}
+
+	if unreleased >= 0 {
+		end := unreleased + 1
+
+		for end < len(lines) && !strings.HasPrefix(lines[end], "## ") && !linkDefinitionRegex.MatchString(lines[end]) {
+			end++
+		}
+
+		if body := trimBlankLines(lines[unreleased+1 : end]); len(body) != 0 {
+			section = append(section, body...)
+			section = append(section, "")
+		}
+
+		section = append(section, changelogEntries(ccs)...)
+
+		rest := append([]string{lines[unreleased], ""}, section...)
+		lines = append(append(lines[:unreleased:unreleased], rest...), lines[end:]...)
+	} else {
+		section = append(section, changelogEntries(ccs)...)
+
+		if insertAt < 0 {
+			insertAt = len(lines)
+
+			if len(lines) != 0 && len(lines[insertAt-1]) == 0 {
+				insertAt--
+			}
+
+			section = append([]string{""}, section...)
+			section = section[:len(section)-1]
+		}
+
+		lines = append(append(lines[:insertAt:insertAt], section...), lines[insertAt:]...)
+	}
+
+	return strings.Join(updateChangelogLinks(lines, current, next), "\n"), nil
+}
+
+// changelogEntries lists commits as changelog entries followed by a blank line
+func changelogEntries(ccs *ComparedCommits) []string {
+	if ccs == nil || len(ccs.Commits) == 0 {
+		return nil
+	}
+
+	var entries []string
+
+	for _, c := range ccs.Commits {
+		entries = append(entries, fmt.Sprintf("- %s ([@%s](%s))", c.Title(), c.Author, c.HTMLURL))
+	}
+
+	return append(entries, "")
+}
+
+// updateChangelogLinks points the Unreleased comparison link to the next version, and adds
+// a comparison link of the next version. It does nothing if the changelog has no comparison links
+func updateChangelogLinks(lines []string, current, next string) []string {
+	for i, l := range lines {
+		m := linkDefinitionRegex.FindStringSubmatch(l)
+
+		if m == nil || !strings.Contains(m[2], "/compare/") {
+			continue
+		}
+
+		base := m[2][:strings.Index(m[2], "/compare/")]
+		prefix := ""
+
+		if strings.HasPrefix(m[2][len(base)+len("/compare/"):], "v") {
+			prefix = "v"
+		}
+
+		link := fmt.Sprintf("[%s]: %s/compare/%s%s...%s%s", next, base, prefix, current, prefix, next)
+
+		if strings.EqualFold(m[1], "unreleased") {
+			lines[i] = fmt.Sprintf("[%s]: %s/compare/%s%s...HEAD", m[1], base, prefix, next)
+			i++
+		}
+
+		return append(append(lines[:i:i], link), lines[i:]...)
+	}
+
+	return lines
+}
+
+func trimBlankLines(lines []string) []string {
+	for len(lines) != 0 && len(strings.TrimSpace(lines[0])) == 0 {
+		lines = lines[1:]
+	}
+
+	for len(lines) != 0 && len(strings.TrimSpace(lines[len(lines)-1])) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestUpdateChangelog(t *testing.T) {
+	ccs := &ComparedCommits{Commits: []*ComparedCommit{
+		{Author: "shuheiktgw", Message: "Add foo\n\nDetails of foo", HTMLURL: "https://github.com/o/r/commit/aaa"},
+		{Author: "shuheiktgw", Message: "Fix bar", HTMLURL: "https://github.com/o/r/commit/bbb"},
+	}}
+
+	cases := []struct {
+		content, want string
+		ccs           *ComparedCommits
+	}{
+		{
+			content: `# Changelog
+All notable changes to this project will be documented in this file.
+
+## [Unreleased]
+### Added
+- Foo option
+
+## [1.2.3] - 2018-06-01
+### Fixed
+- Something
+
+[Unreleased]: https://github.com/o/r/compare/v1.2.3...HEAD
+[1.2.3]: https://github.com/o/r/compare/v1.2.2...v1.2.3
+`,
+			ccs: ccs,
+			want: `# Changelog
+All notable changes to this project will be documented in this file.
+
+## [Unreleased]
+
+## [1.3.0] - 2018-07-01
+
+### Added
+- Foo option
+
+- Add foo ([@shuheiktgw](https://github.com/o/r/commit/aaa))
+- Fix bar ([@shuheiktgw](https://github.com/o/r/commit/bbb))
+
+## [1.2.3] - 2018-06-01
+### Fixed
+- Something
+
+[Unreleased]: https://github.com/o/r/compare/v1.3.0...HEAD
+[1.3.0]: https://github.com/o/r/compare/v1.2.3...v1.3.0
+[1.2.3]: https://github.com/o/r/compare/v1.2.2...v1.2.3
+`,
+		},
+		{
+			content: `# Changelog
+
+## [1.2.3] - 2018-06-01
+- Something
+
+[1.2.3]: https://github.com/o/r/compare/v1.2.2...v1.2.3
+`,
+			ccs: ccs,
+			want: `# Changelog
+
+## [1.3.0] - 2018-07-01
+
+- Add foo ([@shuheiktgw](https://github.com/o/r/commit/aaa))
+- Fix bar ([@shuheiktgw](https://github.com/o/r/commit/bbb))
+
+## [1.2.3] - 2018-06-01
+- Something
+
+[1.3.0]: https://github.com/o/r/compare/v1.2.3...v1.3.0
+[1.2.3]: https://github.com/o/r/compare/v1.2.2...v1.2.3
+`,
+		},
+		{
+			content: "# Changelog\n",
+			ccs:     nil,
+			want:    "# Changelog\n\n## [1.3.0] - 2018-07-01\n",
+		},
+	}
+
+	for i, tc := range cases {
+		got, err := updateChangelog(tc.content, "1.2.3", "1.3.0", "2018-07-01", tc.ccs)
+
+		if err != nil {
+			t.Fatalf("#%d updateChangelog failed: %s", i, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid changelog: want: %q, got: %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestUpdateChangelogFail(t *testing.T) {
+	content := "# Changelog\n\n## [1.3.0] - 2018-07-01\n- Something\n"
+
+	if _, err := updateChangelog(content, "1.2.3", "1.3.0", "2018-07-01", nil); err == nil {
+		t.Fatalf("updateChangelog is supposed to fail")
+	}
+}
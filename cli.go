@@ -5,53 +5,105 @@ import (
 	"flag"
 	"os"
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 const EnvGitHubToken = "GITHUB_TOKEN"
 
+// EnvGitHubAPIURL is the environment variable for the url of GitHub Enterprise Server API
+const EnvGitHubAPIURL = "GITHUB_API_URL"
+
 const (
 	ExitCodeOK    = iota
 	ExitCodeError
 	ExitCodeParseFlagsError
 	ExitCodeInvalidFlagError
+	ExitCodePreflightError
 )
 
 type CLI struct {
 	outStream, errStream io.Writer
+
+	// newForge creates the client of the forge instead of the real one if it is not nil, e.g. a fake in tests
+	newForge func(cfg *Config, kind string) Forge
 }
 
 func (cli *CLI)Run(args []string) int {
+	if len(args) > 1 {
+		switch args[1] {
+		case "config":
+			return cli.runConfig(args[2:])
+		case "resume", "rollback":
+			return cli.runJournal(args[1], args[2:])
+		}
+	}
+
+	cfg, err := cli.loadConfig()
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to load config: %s\n", err)
+		return ExitCodeInvalidFlagError
+	}
+
 	var (
-		owner string
-		repo string
-		branch string
-		path string
 		token string
+		verbose bool
 		version bool
 		dryRun bool
 		patch bool
 		minor bool
 		major bool
+		pre string
+		promote bool
+		auto bool
+		labels bool
+		local bool
+		push bool
 	)
 
 	flags := flag.NewFlagSet(Name, flag.ContinueOnError)
 	flags.SetOutput(cli.errStream)
 
-	flags.StringVar(&owner, "username", "", "a long option for a GitHub username of your gem")
-	flags.StringVar(&owner, "u", "", "a short option for a GitHub username of your gem")
+	flags.StringVar(&cfg.Username, "username", cfg.Username, "a long option for a GitHub username of your gem")
+	flags.StringVar(&cfg.Username, "u", cfg.Username, "a short option for a GitHub username of your gem")
 
-	flags.StringVar(&repo, "repository", "", "a long option for a GitHub repository of your gem")
-	flags.StringVar(&repo, "r", "", "a short option for a GitHub repository of your gem")
+	flags.StringVar(&cfg.Repository, "repository", cfg.Repository, "a long option for a GitHub repository of your gem")
+	flags.StringVar(&cfg.Repository, "r", cfg.Repository, "a short option for a GitHub repository of your gem")
 
-	flags.StringVar(&branch, "branch", "master", "a long option for a GitHub branch your release is based on")
-	flags.StringVar(&branch, "b", "master", "a long option for a GitHub branch your release is based on")
+	flags.StringVar(&cfg.Forge, "forge", cfg.Forge, "an option for a forge hosting your gem, one of github, gitlab and gitea, inferred from -api-url or the git remote by default")
 
-	flags.StringVar(&path, "path", "", "a long option for a path to version.rb from the root of your gem")
-	flags.StringVar(&path, "p", "", "a short option for a path to version.rb from the root of your gem")
+	flags.StringVar(&cfg.APIURL, "api-url", cfg.APIURL, "an option for a url of GitHub Enterprise Server API, e.g. https://github.example.com/api/v3/, or GitLab or Gitea API")
+	flags.StringVar(&cfg.UploadURL, "upload-url", cfg.UploadURL, "an option for an upload url of GitHub Enterprise Server API, derived from -api-url by default")
 
-	flags.StringVar(&token, "token", os.Getenv(EnvGitHubToken), "a long option for a GitHub token")
-	flags.StringVar(&token, "t", os.Getenv(EnvGitHubToken), "a short option for a GitHub token")
+	flags.StringVar(&cfg.Remote, "remote", cfg.Remote, "an option for a git remote to infer a GitHub username and repository from when they are not given")
+
+	flags.StringVar(&cfg.Branch, "branch", cfg.Branch, "a long option for a GitHub branch your release is based on")
+	flags.StringVar(&cfg.Branch, "b", cfg.Branch, "a long option for a GitHub branch your release is based on")
+
+	flags.StringVar(&cfg.Path, "path", cfg.Path, "a long option for a path to a version file (version.rb, .gemspec or VERSION) from the root of your gem")
+	flags.StringVar(&cfg.Path, "p", cfg.Path, "a short option for a path to a version file (version.rb, .gemspec or VERSION) from the root of your gem")
+
+	flags.StringVar(&cfg.Constant, "constant", cfg.Constant, "an option for a name of the constant which holds the version of your gem")
+
+	flags.StringVar(&cfg.ReleaseSections, "release-sections", cfg.ReleaseSections, "an option for sections of release notes and labels of pull requests in them, e.g. \"Features=feature;Bug Fixes=bug\"")
+
+	flags.StringVar(&cfg.Templates.Branch, "branch-template", cfg.Templates.Branch, "an option for a Go template of a name of the branch to bump up the version, e.g. \"release/{{.NextTag}}\"")
+	flags.StringVar(&cfg.Templates.PullRequestTitle, "pr-title-template", cfg.Templates.PullRequestTitle, "an option for a Go template of a title of the pull request")
+	flags.StringVar(&cfg.Templates.PullRequestBody, "pr-body-template", cfg.Templates.PullRequestBody, "an option for a Go template of a body of the pull request")
+	flags.StringVar(&cfg.Templates.ReleaseName, "release-name-template", cfg.Templates.ReleaseName, "an option for a Go template of a name of the release")
+	flags.StringVar(&cfg.Templates.ReleaseBody, "release-body-template", cfg.Templates.ReleaseBody, "an option for a Go template of a body of the release")
+
+	flags.StringVar(&cfg.AppID, "app-id", cfg.AppID, "an option for an ID of GitHub App to authenticate as instead of a GitHub token")
+	flags.StringVar(&cfg.AppKey, "app-key", cfg.AppKey, "an option for a path to a private key (PEM) of GitHub App")
+	flags.StringVar(&cfg.InstallationID, "installation-id", cfg.InstallationID, "an option for an installation ID of GitHub App, looked up from the repository by default")
+
+	flags.StringVar(&token, "token", "", "a long option for a GitHub token, looked up from GITHUB_TOKEN, GH_TOKEN, gh, netrc and git credential helpers by default")
+	flags.StringVar(&token, "t", "", "a short option for a GitHub token, looked up from GITHUB_TOKEN, GH_TOKEN, gh, netrc and git credential helpers by default")
+
+	flags.BoolVar(&verbose, "verbose", false, "an option to show where settings such as a GitHub token come from")
 
 	flags.BoolVar(&version, "version", false, "a long option to show the current version of gemer")
 	flags.BoolVar(&version, "v", false, "a short option to show the current version of gemer")
@@ -61,43 +113,106 @@ func (cli *CLI)Run(args []string) int {
 
 	flags.BoolVar(&major, "major", false, "an option to increment major version")
 	flags.BoolVar(&minor, "minor", false, "an option to increment minor version")
-	flags.BoolVar(&patch, "patch", true, "an option to increment patch version")
+	flags.BoolVar(&patch, "patch", false, "an option to increment patch version")
+
+	flags.BoolVar(&auto, "auto", false, "an option to infer a version to increment from Conventional Commits since the last release")
+	flags.BoolVar(&labels, "labels", false, "an option to infer a version to increment from semver:major, semver:minor and semver:patch labels of pull requests merged since the last release")
+	flags.BoolVar(&cfg.ZeroBreakingMinor, "zero-breaking-minor", cfg.ZeroBreakingMinor, "an option to increment minor version instead of major version for breaking changes of 0.x versions with -auto")
+
+	flags.StringVar(&pre, "pre", "", "an option to bump up to a pre-release version, one of alpha, beta and rc")
+	flags.BoolVar(&promote, "promote", false, "an option to promote a pre-release version to a release version")
+
+	flags.BoolVar(&local, "local", false, "an option to work on the local git repository instead of the API, tagging the bump commit instead of drafting a release")
+	flags.BoolVar(&push, "push", false, "an option to push the branch and the tag to the remote with -local")
 
 	if err := flags.Parse(args[1:]); err != nil {
 		return ExitCodeParseFlagsError
 	}
 
 	if version {
-		fmt.Fprintf(cli.outStream, OutputVersion())
+		// GitLab and Gitea do not host gemer, so that the latest version is checked on github.com for them
+		apiURL := cfg.APIURL
+
+		if cli.forgeKind(cfg) != ForgeGitHub {
+			apiURL = ""
+		}
+
+		fmt.Fprintf(cli.outStream, OutputVersion(apiURL))
 		return ExitCodeOK
 	}
 
-	if len(owner) == 0 {
-		fmt.Fprintf(cli.errStream, "Failed to set up gemer: GitHub username is missing\n" +
-			"Please set it via `-u` option\n\n")
+	flags.Visit(func(f *flag.Flag) {
+		if key := configKeyOfFlag(f.Name); len(key) != 0 {
+			cfg.SetOrigin(key, "-"+f.Name)
+		}
+	})
+
+	if push && !local {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: `-push` option can only be used with `-local` option\n\n")
 		return ExitCodeInvalidFlagError
 	}
 
-	if len(repo) == 0 {
-		fmt.Fprintf(cli.errStream, "Failed to set up gemer: GitHub repository nane is missing\n" +
-			"Please set it via `-r` option\n\n")
+	if len(pre) != 0 && preReleaseIndex(pre) < 0 {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: invalid pre-release label: %s\n" +
+			"Please set one of %s via `-pre` option\n\n", pre, strings.Join(PreReleases, ", "))
 		return ExitCodeInvalidFlagError
 	}
 
-	if len(path) == 0 {
-		path = fmt.Sprintf("lib/%s/version.rb", strings.ToLower(repo))
+	if auto && promote {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: `-auto` and `-promote` options cannot be used together\n\n")
+		return ExitCodeInvalidFlagError
+	}
+
+	if labels && (auto || promote) {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: `-labels` option cannot be used together with `-auto` or `-promote` option\n\n")
+		return ExitCodeInvalidFlagError
+	}
+
+	if len(pre) != 0 && promote {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: `-pre` and `-promote` options cannot be used together\n\n")
+		return ExitCodeInvalidFlagError
+	}
+
+	var sections []*ReleaseNoteSection
+
+	if len(cfg.ReleaseSections) != 0 {
+		s, err := ParseReleaseNoteSections(cfg.ReleaseSections)
+
+		if err != nil {
+			fmt.Fprintf(cli.errStream, "Failed to set up gemer: %s\n" +
+				"Please fix it via `-release-sections` option\n\n", err)
+			return ExitCodeInvalidFlagError
+		}
+
+		sections = s
 	}
 
-	if len(token) == 0 {
-		fmt.Fprintf(cli.errStream, "Failed to set up gemer: GitHub Personal Access Token is missing\n" +
-			"Please set it via `%s` environment variable or `-t` option\n\n" +
-			"To create GitHub Personal Access token, see https://bit.ly/2rvbeT1\n",
-			EnvGitHubToken)
+	if err := cfg.Templates.Validate(); err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: %s\n" +
+			"Please fix it via `-branch-template`, `-pr-title-template`, `-pr-body-template`, `-release-name-template` or `-release-body-template` option\n\n", err)
 		return ExitCodeInvalidFlagError
 	}
 
-	// The default is PatchVersion
-	ver := PatchVersion
+	// Flags are all checked, so it is time to look up credentials and set up the client
+	var client Forge
+	var code int
+
+	if local {
+		client, code = cli.localClient(cfg, push)
+	} else {
+		client, code = cli.remoteForge(cfg, token, verbose)
+	}
+
+	if code != ExitCodeOK {
+		return code
+	}
+
+	ver := cfg.BumpVersion()
+
+	// Flags take precedence over config, and the default of them is PatchVersion
+	if major || minor || patch || auto || labels {
+		ver = PatchVersion
+	}
 
 	if major {
 		ver = MajorVersion
@@ -107,16 +222,40 @@ func (cli *CLI)Run(args []string) int {
 		ver = MinorVersion
 	}
 
-	client, err := NewGitHubClient(owner, repo, token)
+	if auto {
+		ver = AutoVersion
+	}
+
+	if labels {
+		ver = LabelVersion
+	}
+
+	if promote {
+		ver = PromoteVersion
+	}
+
+	gemer := Gemer{Forge: client, outStream: cli.outStream, ZeroBreakingMinor: cfg.ZeroBreakingMinor, ReleaseNoteSections: sections, Templates: &cfg.Templates, JournalDir: JournalDir()}
+
+	var source VersionSource
+
+	if len(cfg.Path) == 0 {
+		source, err = gemer.DetectVersionSource(cfg.Branch, cfg.Constant)
+	} else {
+		source, err = NewVersionSource(cfg.Path, cfg.Constant)
+	}
+
 	if err != nil {
-		fmt.Fprintf(cli.errStream, "Failed to create a GitHub client: %s\n", err)
+		fmt.Fprintf(cli.errStream, "Failed to find a version file: %s\n", err)
 		return ExitCodeError
 	}
 
-	gemer := Gemer{GitHubClient: client, outStream: cli.outStream}
-
 	if dryRun {
-		err := gemer.DryUpdateVersion(branch, path, ver)
+		err := gemer.DryUpdateVersion(cfg.Branch, source, ver, pre)
+		if pe, ok := errors.Cause(err).(*PreflightError); ok {
+			cli.printPreflightError(pe)
+			return ExitCodePreflightError
+		}
+
 		if err != nil {
 			fmt.Fprintf(cli.errStream, "Failed to update version with dry-run option: %s\n", err)
 			return ExitCodeError
@@ -125,15 +264,612 @@ func (cli *CLI)Run(args []string) int {
 		return ExitCodeOK
 	}
 
-	result, err := gemer.UpdateVersion(branch, path, ver)
+	result, err := gemer.UpdateVersion(cfg.Branch, source, ver, pre)
+	if pe, ok := errors.Cause(err).(*PreflightError); ok {
+		cli.printPreflightError(pe)
+		return ExitCodePreflightError
+	}
+
 	if err != nil {
 		fmt.Fprintf(cli.errStream, "Failed to update version: %s\n", err)
+		cli.printRollbackHint(err)
 		return ExitCodeError
 	}
 
+	if local {
+		cli.printLocalResult(result, cfg.Branch, cfg.Remote, push)
+	} else {
+		cli.printResult(result, client)
+	}
+
+	return ExitCodeOK
+}
+
+// runJournal runs `gemer resume` and `gemer rollback`, which pick up a run recorded in a journal
+func (cli *CLI) runJournal(command string, args []string) int {
+	cfg, err := cli.loadConfig()
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to load config: %s\n", err)
+		return ExitCodeInvalidFlagError
+	}
+
+	var (
+		token string
+		verbose bool
+	)
+
+	flags := flag.NewFlagSet(Name+" "+command, flag.ContinueOnError)
+	flags.SetOutput(cli.errStream)
+	flags.Usage = func() {
+		fmt.Fprintf(cli.errStream, "Usage: %s %s [options] [journal]\n\n" +
+			"The journal is looked up from %s by default\n\n", Name, command, JournalDir())
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&cfg.Forge, "forge", cfg.Forge, "an option for a forge hosting your gem, one of github, gitlab and gitea, inferred from -api-url or the git remote by default")
+
+	flags.StringVar(&cfg.APIURL, "api-url", cfg.APIURL, "an option for a url of GitHub Enterprise Server API, e.g. https://github.example.com/api/v3/, or GitLab or Gitea API")
+	flags.StringVar(&cfg.UploadURL, "upload-url", cfg.UploadURL, "an option for an upload url of GitHub Enterprise Server API, derived from -api-url by default")
+
+	flags.StringVar(&cfg.AppID, "app-id", cfg.AppID, "an option for an ID of GitHub App to authenticate as instead of a GitHub token")
+	flags.StringVar(&cfg.AppKey, "app-key", cfg.AppKey, "an option for a path to a private key (PEM) of GitHub App")
+	flags.StringVar(&cfg.InstallationID, "installation-id", cfg.InstallationID, "an option for an installation ID of GitHub App, looked up from the repository by default")
+
+	flags.StringVar(&token, "token", "", "a long option for a GitHub token, looked up from GITHUB_TOKEN, GH_TOKEN, gh, netrc and git credential helpers by default")
+	flags.StringVar(&token, "t", "", "a short option for a GitHub token, looked up from GITHUB_TOKEN, GH_TOKEN, gh, netrc and git credential helpers by default")
+
+	flags.BoolVar(&verbose, "verbose", false, "an option to show where settings such as a GitHub token come from")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitCodeParseFlagsError
+	}
+
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return ExitCodeParseFlagsError
+	}
+
+	path := flags.Arg(0)
+
+	if len(path) == 0 {
+		if path, err = FindJournal(JournalDir()); err != nil {
+			fmt.Fprintf(cli.errStream, "Failed to %s: %s\n", command, err)
+			return ExitCodeInvalidFlagError
+		}
+	}
+
+	j, err := LoadJournal(path)
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to %s: %s\n", command, err)
+		return ExitCodeInvalidFlagError
+	}
+
+	cli.verbosef(verbose, "==> Use a journal %s\n", path)
+
+	// The journal knows which repository the run is for, and whether it works on the local repository
+	cfg.Username, cfg.Repository = j.Owner, j.Repo
+
+	// as well as the forge hosting it, which is inferred from the config and the remote only for old journals
+	if len(j.Forge) != 0 {
+		cfg.Forge, cfg.APIURL = j.Forge, j.APIURL
+	}
+
+	var client Forge
+	var code int
+
+	if j.Local {
+		client, code = cli.localClient(cfg, j.Push)
+	} else {
+		client, code = cli.forge(cfg, cli.forgeKind(cfg), token, verbose)
+	}
+
+	if code != ExitCodeOK {
+		return code
+	}
+
+	gemer := Gemer{Forge: client, outStream: cli.outStream}
+
+	if command == "rollback" {
+		if err := gemer.Rollback(j); err != nil {
+			fmt.Fprintf(cli.errStream, "Failed to roll back: %s\n", err)
+			cli.printRollbackHint(err)
+			return ExitCodeError
+		}
+
+		fmt.Fprintf(cli.outStream, "Rolled back bumping up %s/%s to %s\n", j.Owner, j.Repo, j.Next)
+
+		return ExitCodeOK
+	}
+
+	result, err := gemer.Resume(j)
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to resume: %s\n", err)
+		cli.printRollbackHint(err)
+		return ExitCodeError
+	}
+
+	if j.Local {
+		cli.printLocalResult(result, j.Base, cfg.Remote, j.Push)
+	} else {
+		cli.printResult(result, client)
+	}
+
+	return ExitCodeOK
+}
+
+// remoteForge sets up a client of the forge hosting the repository, inferring the repository from the git remote if it is missing.
+// It returns an exit code other than ExitCodeOK if it fails
+func (cli *CLI) remoteForge(cfg *Config, token string, verbose bool) (Forge, int) {
+	kind := cli.forgeKind(cfg)
+	cli.inferAPIURL(cfg, kind)
+
+	if len(cfg.Username) == 0 || len(cfg.Repository) == 0 {
+		if err := cli.inferRepository(cfg, kind); err != nil {
+			fmt.Fprintf(cli.errStream, "Failed to set up gemer: %s\n" +
+				"Please set GitHub username and repository via `-u` and `-r` options, or choose another remote via `-remote` option\n\n", err)
+			return nil, ExitCodeInvalidFlagError
+		}
+	}
+
+	if len(cfg.Username) == 0 {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: GitHub username is missing\n" +
+			"Please set it via `-u` option or `username` of `%s`\n\n", ConfigFile)
+		return nil, ExitCodeInvalidFlagError
+	}
+
+	if len(cfg.Repository) == 0 {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: GitHub repository nane is missing\n" +
+			"Please set it via `-r` option or `repository` of `%s`\n\n", ConfigFile)
+		return nil, ExitCodeInvalidFlagError
+	}
+
+	return cli.forge(cfg, kind, token, verbose)
+}
+
+// localClient sets up a client of the local git repository of the current directory.
+// It returns an exit code other than ExitCodeOK if it fails
+func (cli *CLI) localClient(cfg *Config, push bool) (*LocalClient, int) {
+	dir, err := os.Getwd()
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: %s\n\n", err)
+		return nil, ExitCodeError
+	}
+
+	client, err := NewLocalClient(dir, cfg.Remote, push)
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: %s\n" +
+			"Please run gemer with `-local` option inside a checkout of your gem\n\n", err)
+		return nil, ExitCodeInvalidFlagError
+	}
+
+	return client, ExitCodeOK
+}
+
+// forgeKind returns `forge` of the config, or infers it from the host of `api_url` or the git remote. It falls back to GitHub
+func (cli *CLI) forgeKind(cfg *Config) string {
+	if len(cfg.Forge) != 0 {
+		return cfg.Forge
+	}
+
+	if len(cfg.APIURL) != 0 {
+		return DetectForge(cfg.APIURL)
+	}
+
+	dir, err := os.Getwd()
+
+	if err != nil {
+		return ForgeGitHub
+	}
+
+	host, err := RemoteHost(dir, cfg.Remote)
+
+	if err != nil {
+		return ForgeGitHub
+	}
+
+	return DetectForge(host)
+}
+
+// inferAPIURL sets `api_url` of GitLab or Gitea to the host of the git remote if it is missing, so that self-hosted
+// instances work without `-api-url`. Their API is served on the same host as the repositories, i.e. https://<host>/api/v4/
+// for GitLab and https://<host>/api/v1/ for Gitea
+func (cli *CLI) inferAPIURL(cfg *Config, kind string) {
+	if len(cfg.APIURL) != 0 || (kind != ForgeGitLab && kind != ForgeGitea) {
+		return
+	}
+
+	dir, err := os.Getwd()
+
+	if err != nil {
+		return
+	}
+
+	host, err := RemoteHost(dir, cfg.Remote)
+
+	if err != nil || len(host) == 0 || host == GitHubHost {
+		return
+	}
+
+	cfg.APIURL = "https://" + host + "/"
+	cfg.SetOrigin("api_url", "remote "+cfg.Remote)
+}
+
+// forge creates a client of the forge of kind. It returns an exit code other than ExitCodeOK if it fails
+func (cli *CLI) forge(cfg *Config, kind, token string, verbose bool) (Forge, int) {
+	if cli.newForge != nil {
+		return cli.newForge(cfg, kind), ExitCodeOK
+	}
+
+	switch kind {
+	case ForgeGitHub:
+		client, code := cli.gitHubClient(cfg, token, verbose)
+
+		if code != ExitCodeOK {
+			return nil, code
+		}
+
+		return client, ExitCodeOK
+	case ForgeGitLab:
+		client, code := cli.gitLabClient(cfg, token, verbose)
+
+		if code != ExitCodeOK {
+			return nil, code
+		}
+
+		return client, ExitCodeOK
+	case ForgeGitea:
+		client, code := cli.giteaClient(cfg, token, verbose)
+
+		if code != ExitCodeOK {
+			return nil, code
+		}
+
+		return client, ExitCodeOK
+	}
+
+	fmt.Fprintf(cli.errStream, "Failed to set up gemer: unknown forge %q\n" +
+		"Please set one of %s via `-forge` option\n\n", kind, strings.Join(Forges, ", "))
+	return nil, ExitCodeInvalidFlagError
+}
+
+// gitLabClient creates a GitLab client from the config, looking up a GitLab token if token is empty.
+// It returns an exit code other than ExitCodeOK if it fails
+func (cli *CLI) gitLabClient(cfg *Config, token string, verbose bool) (*GitLabClient, int) {
+	host := restWebHost(cfg.APIURL, GitLabHost)
+	token, code := cli.forgeToken(cfg, token, verbose, "GitLab", EnvGitLabToken, host,
+		fmt.Sprintf("To create GitLab access token with `api` scope, see https://%s/-/profile/personal_access_tokens\n", host))
+
+	if code != ExitCodeOK {
+		return nil, code
+	}
+
+	client, err := NewGitLabClient(cfg.Username, cfg.Repository, token, cfg.APIURL)
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to create a GitLab client: %s\n", err)
+		return nil, ExitCodeError
+	}
+
+	return client, ExitCodeOK
+}
+
+// giteaClient creates a Gitea client from the config, looking up a Gitea token if token is empty.
+// It returns an exit code other than ExitCodeOK if it fails
+func (cli *CLI) giteaClient(cfg *Config, token string, verbose bool) (*GiteaClient, int) {
+	host := restWebHost(cfg.APIURL, GiteaHost)
+	token, code := cli.forgeToken(cfg, token, verbose, "Gitea", EnvGiteaToken, host,
+		fmt.Sprintf("To create Gitea access token with `write:repository` scope, see https://%s/user/settings/applications\n", host))
+
+	if code != ExitCodeOK {
+		return nil, code
+	}
+
+	client, err := NewGiteaClient(cfg.Username, cfg.Repository, token, cfg.APIURL)
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to create a Gitea client: %s\n", err)
+		return nil, ExitCodeError
+	}
+
+	return client, ExitCodeOK
+}
+
+// forgeToken returns token, or looks up a token of the forge on host from env, ~/.netrc or a git credential helper,
+// for forges other than GitHub which cannot use GitHub App. It returns an exit code other than ExitCodeOK if it fails
+func (cli *CLI) forgeToken(cfg *Config, token string, verbose bool, name, env, host, help string) (string, int) {
+	if len(cfg.AppID) != 0 {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: GitHub App cannot be used with %s\n" +
+			"Please remove `-app-id` option or `app_id` of `%s`\n\n", name, ConfigFile)
+		return "", ExitCodeInvalidFlagError
+	}
+
+	if len(token) != 0 {
+		cli.verbosef(verbose, "==> Use a %s token from `-t` option\n", name)
+		return token, ExitCodeOK
+	}
+
+	chain := cli.credentialChain(verbose)
+	chain.Envs = []string{env}
+	cred, err := chain.Find(host)
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: failed to look for a %s token: %s\n\n", name, err)
+		return "", ExitCodeInvalidFlagError
+	}
+
+	if cred == nil {
+		fmt.Fprintf(cli.errStream, "Failed to set up gemer: %s access token is missing\n" +
+			"Please set it via `%s` environment variable, `~/.netrc`, a git credential helper or `-t` option\n\n%s",
+			name, env, help)
+		return "", ExitCodeInvalidFlagError
+	}
+
+	cli.verbosef(verbose, "==> Use a %s token from %s\n", name, cred.Source)
+
+	return cred.Token, ExitCodeOK
+}
+
+// gitHubClient creates a GitHub client from the config, looking up a GitHub token if token is empty and GitHub App is not used.
+// It returns an exit code other than ExitCodeOK if it fails
+func (cli *CLI) gitHubClient(cfg *Config, token string, verbose bool) (*GitHubClient, int) {
+	var app *AppTokenSource
+
+	if len(cfg.AppID) != 0 {
+		a, err := newAppTokenSource(cfg)
+
+		if err != nil {
+			fmt.Fprintf(cli.errStream, "Failed to set up gemer: %s\n" +
+				"Please fix it via `-app-id`, `-app-key` or `-installation-id` option\n\n", err)
+			return nil, ExitCodeInvalidFlagError
+		}
+
+		app = a
+	}
+
+	if len(token) != 0 {
+		cli.verbosef(verbose, "==> Use a GitHub token from `-t` option\n")
+	} else if app != nil {
+		cli.verbosef(verbose, "==> Use an installation token of GitHub App %d\n", app.AppID)
+	} else {
+		host := WebHost(cfg.APIURL)
+		cred, err := cli.credentialChain(verbose).Find(host)
+
+		if err != nil {
+			fmt.Fprintf(cli.errStream, "Failed to set up gemer: failed to look for a GitHub token: %s\n\n", err)
+			return nil, ExitCodeInvalidFlagError
+		}
+
+		if cred == nil {
+			fmt.Fprintf(cli.errStream, "Failed to set up gemer: GitHub Personal Access Token is missing\n" +
+				"Please set it via `%s` or `%s` environment variable, `gh auth login`, `~/.netrc`, a git credential helper or `-t` option, " +
+				"or use GitHub App via `-app-id` and `-app-key` options\n\n" +
+				"To create GitHub Personal Access token, see https://%s/settings/tokens\n",
+				EnvGitHubToken, EnvGHToken, host)
+			return nil, ExitCodeInvalidFlagError
+		}
+
+		cli.verbosef(verbose, "==> Use a GitHub token from %s\n", cred.Source)
+		token = cred.Token
+	}
+
+	client, err := newGitHubClient(cfg, token, app)
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to create a GitHub client: %s\n", err)
+		return nil, ExitCodeError
+	}
+
+	return client, ExitCodeOK
+}
+
+func (cli *CLI) printResult(result *UpdateVersionResult, client Forge) {
+	if result.ReleasePending {
+		fmt.Fprintf(cli.outStream, "Now, your gem is ready to release! Remaining tasks are ...\n\n" +
+			"1. Access %s and merge the PR\n" +
+			"2. Run `%s resume` to create the release on the merged commit\n", result.PrURL, Name)
+		return
+	}
+
+	// The release is published as soon as it is created after the PR is merged
+	if _, ok := client.(releaseOnMerge); ok {
+		fmt.Fprintf(cli.outStream, "Now, your gem is released! See %s\n", result.ReleaseURL)
+		return
+	}
+
 	fmt.Fprintf(cli.outStream, "Now, your gem is ready to release! Remaining tasks are ...\n\n" +
 		"1. Access %s and merge the PR\n" +
 		"2. Access %s and publish the release\n", result.PrURL, result.ReleaseURL)
+}
+
+// printLocalResult tells what is left after bumping up the version on the local repository, which depends on whether it has pushed
+func (cli *CLI) printLocalResult(result *UpdateVersionResult, base, remote string, push bool) {
+	fmt.Fprintf(cli.outStream, "Now, your gem is ready to release! Remaining tasks are ...\n\n")
+	step := 1
+
+	if !push {
+		fmt.Fprintf(cli.outStream, "%d. Push the branch and the tag by `git push %s %s %s`\n", step, remote, result.Branch, result.Tag)
+		step++
+	}
+
+	fmt.Fprintf(cli.outStream, "%d. Merge branch `%s` into `%s`, e.g. by a pull request\n", step, result.Branch, base)
+}
+
+// runConfig runs `gemer config` subcommands
+func (cli *CLI) runConfig(args []string) int {
+	if len(args) != 1 || args[0] != "show" {
+		fmt.Fprintf(cli.errStream, "Usage: %s config show\n", Name)
+		return ExitCodeParseFlagsError
+	}
+
+	cfg, err := cli.loadConfig()
+
+	if err != nil {
+		fmt.Fprintf(cli.errStream, "Failed to load config: %s\n", err)
+		return ExitCodeInvalidFlagError
+	}
+
+	fmt.Fprint(cli.outStream, cfg)
 
 	return ExitCodeOK
 }
+
+// inferRepository fills in the missing username and repository from the git remote of the current directory.
+// The username is the namespace of the project on GitLab
+func (cli *CLI) inferRepository(cfg *Config, kind string) error {
+	dir, err := os.Getwd()
+
+	if err != nil {
+		return err
+	}
+
+	var owner, repo string
+
+	switch kind {
+	case ForgeGitLab:
+		owner, repo, err = RemoteGitLabProject(dir, cfg.Remote, restWebHost(cfg.APIURL, GitLabHost))
+	case ForgeGitea:
+		owner, repo, err = RemoteRepository(dir, cfg.Remote, restWebHost(cfg.APIURL, GiteaHost))
+	default:
+		owner, repo, err = RemoteRepository(dir, cfg.Remote, WebHost(cfg.APIURL))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.Username) == 0 {
+		cfg.Username = owner
+		cfg.SetOrigin("username", "remote "+cfg.Remote)
+	}
+
+	if len(cfg.Repository) == 0 {
+		cfg.Repository = repo
+		cfg.SetOrigin("repository", "remote "+cfg.Remote)
+	}
+
+	return nil
+}
+
+func (cli *CLI) printPreflightError(pe *PreflightError) {
+	fmt.Fprintf(cli.errStream, "Failed to update version: found the following problems before making any changes\n\n")
+
+	for _, p := range pe.Problems {
+		fmt.Fprintf(cli.errStream, "  - %s\n", p)
+	}
+
+	fmt.Fprintln(cli.errStream)
+}
+
+// printRollbackHint tells how to clean up what a rollback has left behind
+func (cli *CLI) printRollbackHint(err error) {
+	if re, ok := err.(*RollbackError); ok && len(re.Failures) != 0 {
+		fmt.Fprintf(cli.errStream, "\nThe journal is kept, run `%s rollback` to try to clean them up again\n", Name)
+	}
+}
+
+// credentialChain creates a CredentialChain which tells skipped sources if verbose is true
+func (cli *CLI) credentialChain(verbose bool) *CredentialChain {
+	chain := NewCredentialChain()
+
+	if verbose {
+		chain.Logf = func(format string, args ...interface{}) {
+			fmt.Fprintf(cli.outStream, format, args...)
+		}
+	}
+
+	return chain
+}
+
+func (cli *CLI) verbosef(verbose bool, format string, args ...interface{}) {
+	if verbose {
+		fmt.Fprintf(cli.outStream, format, args...)
+	}
+}
+
+// newGitHubClient creates a GitHub client authenticated by app if it is not nil, or by token otherwise
+func newGitHubClient(cfg *Config, token string, app *AppTokenSource) (*GitHubClient, error) {
+	var client *GitHubClient
+	var err error
+
+	if app != nil {
+		client, err = NewGitHubClientWithTokenSource(cfg.Username, cfg.Repository, app)
+	} else {
+		client, err = NewGitHubClient(cfg.Username, cfg.Repository, token)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if WebHost(cfg.APIURL) != GitHubHost {
+		if err := client.SetEnterpriseURLs(cfg.APIURL, cfg.UploadURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if app != nil {
+		app.BaseURL = client.Client.BaseURL
+	}
+
+	return client, nil
+}
+
+// newAppTokenSource creates a token source of GitHub App from the config
+func newAppTokenSource(cfg *Config) (*AppTokenSource, error) {
+	appID, err := strconv.ParseInt(cfg.AppID, 10, 64)
+
+	if err != nil {
+		return nil, errors.Errorf("invalid GitHub App ID: %s", cfg.AppID)
+	}
+
+	var installationID int64
+
+	if len(cfg.InstallationID) != 0 {
+		if installationID, err = strconv.ParseInt(cfg.InstallationID, 10, 64); err != nil {
+			return nil, errors.Errorf("invalid installation ID of GitHub App: %s", cfg.InstallationID)
+		}
+	}
+
+	if len(cfg.AppKey) == 0 {
+		return nil, errors.New("private key of GitHub App is missing")
+	}
+
+	key, err := LoadAppKey(cfg.AppKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAppTokenSource(appID, installationID, cfg.Username, cfg.Repository, key, nil), nil
+}
+
+func (cli *CLI) loadConfig() (*Config, error) {
+	dir, err := os.Getwd()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadConfig(dir)
+}
+
+// configKeyOfFlag returns the config key a flag sets, or an empty string if the flag has nothing to do with config
+func configKeyOfFlag(name string) string {
+	switch name {
+	case "major", "minor", "patch", "auto", "labels", "promote":
+		return "bump"
+	}
+
+	for _, k := range configKeys {
+		if k.flag == name || k.short == name {
+			return k.name
+		}
+	}
+
+	return ""
+}
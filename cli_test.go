@@ -5,6 +5,8 @@ import (
 	"bytes"
 	"strings"
 	"fmt"
+	"os"
+	"path/filepath"
 )
 
 func testCli() (*CLI, *bytes.Buffer, *bytes.Buffer) {
@@ -13,13 +15,77 @@ func testCli() (*CLI, *bytes.Buffer, *bytes.Buffer) {
 	return &CLI{outStream: outStream, errStream: errStream}, outStream, errStream
 }
 
+// testCliIsolatedEnvs are the environment variables of credentials and settings which testCliDir clears
+// in addition to ones starting with EnvConfigPrefix
+var testCliIsolatedEnvs = []string{EnvGitHubToken, EnvGHToken, EnvGitLabToken, EnvGiteaToken, EnvGitHubAPIURL, "GH_CONFIG_DIR", "NETRC"}
+
+// testCliDir runs a test in an empty working directory with an empty home directory, so that neither config files
+// nor credentials of the machine affect gemer. The returned function restores them
+func testCliDir(t *testing.T) (string, func()) {
+	dir, cleanup := testConfigDir(t)
+	work := filepath.Join(dir, "work")
+
+	if err := os.MkdirAll(work, 0755); err != nil {
+		t.Fatalf("failed to create a working directory: %s", err)
+	}
+
+	wd, err := os.Getwd()
+
+	if err != nil {
+		t.Fatalf("failed to get the working directory: %s", err)
+	}
+
+	if err := os.Chdir(work); err != nil {
+		t.Fatalf("failed to change the working directory: %s", err)
+	}
+
+	envs := map[string]string{"GIT_CONFIG_NOSYSTEM": os.Getenv("GIT_CONFIG_NOSYSTEM")}
+
+	for _, kv := range os.Environ() {
+		if k := kv[:strings.Index(kv, "=")]; strings.HasPrefix(k, EnvConfigPrefix) {
+			envs[k] = os.Getenv(k)
+		}
+	}
+
+	for _, k := range testCliIsolatedEnvs {
+		envs[k] = os.Getenv(k)
+	}
+
+	for k := range envs {
+		os.Unsetenv(k)
+	}
+
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	return work, func() {
+		os.Chdir(wd)
+
+		for k, v := range envs {
+			if len(v) == 0 {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, v)
+			}
+		}
+
+		cleanup()
+	}
+}
+
 func TestCliRunFail(t *testing.T) {
+	_, cleanup := testCliDir(t)
+	defer cleanup()
+
 	cases := []struct {
 		command string
 		expectedErrorCode int
 	}{
-		{command: "gemer -repository testRepo -branch testBranch -path test/path", expectedErrorCode: ExitCodeInvalidFlagError},
-		{command: "gemer -username testUser -branch testBranch -path test/path", expectedErrorCode: ExitCodeInvalidFlagError},
+		{command: "gemer -repository testRepo -branch testBranch -path test/path -remote unknown", expectedErrorCode: ExitCodeInvalidFlagError},
+		{command: "gemer -username testUser -branch testBranch -path test/path -remote unknown", expectedErrorCode: ExitCodeInvalidFlagError},
+		{command: "gemer -username testUser -repository testRepo -forge bitbucket", expectedErrorCode: ExitCodeInvalidFlagError},
+		{command: "gemer -username testUser -repository testRepo -forge gitlab -app-id 1", expectedErrorCode: ExitCodeInvalidFlagError},
+		{command: "gemer -username testUser -repository testRepo -forge gitea -app-id 1", expectedErrorCode: ExitCodeInvalidFlagError},
+		{command: "gemer -username testUser -repository testRepo -push", expectedErrorCode: ExitCodeInvalidFlagError},
 	}
 
 	for i, tc := range cases {
@@ -32,26 +98,153 @@ func TestCliRunFail(t *testing.T) {
 	}
 }
 
+func TestCliRunInvalidFlagsBeforeSetUp(t *testing.T) {
+	_, cleanup := testCliDir(t)
+	defer cleanup()
+
+	// The unknown forge would fail setting up the client, so the errors have to come from the checks of flags before it
+	cases := []struct {
+		command string
+		expectedError string
+	}{
+		{command: "gemer -forge bitbucket -pre gamma", expectedError: "invalid pre-release label: gamma"},
+		{command: "gemer -forge bitbucket -auto -promote", expectedError: "`-auto` and `-promote` options cannot be used together"},
+		{command: "gemer -forge bitbucket -labels -auto", expectedError: "`-labels` option cannot be used together"},
+		{command: "gemer -forge bitbucket -pre rc -promote", expectedError: "`-pre` and `-promote` options cannot be used together"},
+		{command: "gemer -forge bitbucket -push", expectedError: "`-push` option can only be used with `-local` option"},
+		{command: "gemer -forge bitbucket -release-sections Features", expectedError: "-release-sections"},
+		{command: "gemer -forge bitbucket -branch-template {{.Unknown", expectedError: "-branch-template"},
+	}
+
+	for i, tc := range cases {
+		cli, _, errStream := testCli()
+		args := strings.Split(tc.command, " ")
+
+		if got := cli.Run(args); got != ExitCodeInvalidFlagError {
+			t.Fatalf("#%d %q exits with %d, want %d", i, tc.command, got, ExitCodeInvalidFlagError)
+		}
+
+		if got := errStream.String(); !strings.Contains(got, tc.expectedError) || strings.Contains(got, "unknown forge") {
+			t.Fatalf("#%d %q outputs %q, want %q", i, tc.command, got, tc.expectedError)
+		}
+	}
+}
+
 func TestCliRun_dryRunFlag(t *testing.T) {
+	_, cleanup := testCliDir(t)
+	defer cleanup()
+
 	cases := []struct {
 		command string
 		expectedErrorCode int
 	}{
-		{command: fmt.Sprintf("gemer -username %s -repository %s -branch %s -d", TestOwner, TestRepo, "master"), expectedErrorCode: ExitCodeOK},
-		{command: fmt.Sprintf("gemer -username %s -repository %s -branch %s -dry-run", TestOwner, TestRepo, "master"), expectedErrorCode: ExitCodeOK},
+		{command: "gemer -username o -repository r -branch master -d", expectedErrorCode: ExitCodeOK},
+		{command: "gemer -username o -repository r -branch master -dry-run", expectedErrorCode: ExitCodeOK},
 	}
 
 	for i, tc := range cases {
-		cli, _, _ := testCli()
+		cli, outStream, _ := testCli()
+		f := testFakeForge()
+		cli.newForge = func(cfg *Config, kind string) Forge {
+			if kind != ForgeGitHub || cfg.Username != "o" || cfg.Repository != "r" {
+				t.Fatalf("#%d invalid forge: %s %s/%s", i, kind, cfg.Username, cfg.Repository)
+			}
+
+			return f
+		}
+
 		args := strings.Split(tc.command, " ")
 
 		if got := cli.Run(args); got != tc.expectedErrorCode {
 			t.Fatalf("#%d %q exits with %d, want %d", i, tc.command, got, tc.expectedErrorCode)
 		}
+
+		if !strings.Contains(outStream.String(), "bumps_up_to_0.1.1") {
+			t.Fatalf("#%d %q outputs %s", i, tc.command, outStream)
+		}
+
+		if len(f.branches) != 3 || len(f.pullRequests) != 2 || len(f.releases) != 0 {
+			t.Fatalf("#%d nothing is supposed to be changed: branches: %v", i, f.branches)
+		}
+	}
+}
+
+func TestCliRunSelfHostedRemote(t *testing.T) {
+	work, cleanup := testCliDir(t)
+	defer cleanup()
+
+	cases := []struct {
+		remote string
+		kind, owner, repo, apiURL string
+	}{
+		{remote: "git@gitlab.example.com:team/mygem.git", kind: ForgeGitLab, owner: "team", repo: "mygem", apiURL: "https://gitlab.example.com/api/v4/"},
+		{remote: "https://gitlab.example.com/group/subgroup/mygem.git", kind: ForgeGitLab, owner: "group/subgroup", repo: "mygem", apiURL: "https://gitlab.example.com/api/v4/"},
+		{remote: "git@gitea.example.com:team/mygem.git", kind: ForgeGitea, owner: "team", repo: "mygem", apiURL: "https://gitea.example.com/api/v1/"},
+		{remote: "https://forgejo.example.org/team/mygem.git", kind: ForgeGitea, owner: "team", repo: "mygem", apiURL: "https://forgejo.example.org/api/v1/"},
+		{remote: "https://codeberg.org/team/mygem.git", kind: ForgeGitea, owner: "team", repo: "mygem", apiURL: "https://codeberg.org/api/v1/"},
+	}
+
+	for i, tc := range cases {
+		testWriteFile(t, filepath.Join(work, ".git", "config"), fmt.Sprintf("[remote \"origin\"]\n\turl = %s\n", tc.remote))
+
+		cli, _, errStream := testCli()
+		var apiURL string
+		cli.newForge = func(cfg *Config, kind string) Forge {
+			if kind != tc.kind || cfg.Username != tc.owner || cfg.Repository != tc.repo {
+				t.Fatalf("#%d invalid forge: %s %s/%s", i, kind, cfg.Username, cfg.Repository)
+			}
+
+			if kind == ForgeGitLab {
+				c, _ := NewGitLabClient(cfg.Username, cfg.Repository, "glpat-test", cfg.APIURL)
+				apiURL = c.BaseURL.String()
+			} else {
+				c, _ := NewGiteaClient(cfg.Username, cfg.Repository, "gitea-test", cfg.APIURL)
+				apiURL = c.BaseURL.String()
+			}
+
+			return testFakeForge()
+		}
+
+		if got := cli.Run([]string{"gemer", "-branch", "master", "-dry-run"}); got != ExitCodeOK {
+			t.Fatalf("#%d exits with %d: %s", i, got, errStream)
+		}
+
+		if apiURL != tc.apiURL {
+			t.Fatalf("#%d invalid API url: want: %s, got: %s", i, tc.apiURL, apiURL)
+		}
+	}
+}
+
+func TestCliRunJournalForge(t *testing.T) {
+	work, cleanup := testCliDir(t)
+	defer cleanup()
+
+	// The config of the current directory points to another forge, which the journal wins over
+	testWriteFile(t, filepath.Join(work, ConfigFile), "forge: github\napi_url: https://github.example.com/api/v3/\n")
+	path := filepath.Join(work, "r-0.1.1.json")
+
+	for i, command := range []string{"rollback", "resume"} {
+		testWriteFile(t, path, `{"owner": "o", "repo": "r", "base": "master", "next_version": "0.1.1", "forge": "gitlab", "api_url": "https://gitlab.example.com/api/v4/", "templates": {"branch": "bumps_up_to_0.1.1"}, "completed": []}`)
+
+		cli, _, errStream := testCli()
+		var kind, apiURL string
+		cli.newForge = func(cfg *Config, k string) Forge {
+			kind, apiURL = k, cfg.APIURL
+			return testFakeForge()
+		}
+
+		cli.Run([]string{"gemer", command, path})
+
+		if kind != ForgeGitLab || apiURL != "https://gitlab.example.com/api/v4/" {
+			t.Fatalf("#%d %s is supposed to use the forge of the journal: %s %s: %s", i, command, kind, apiURL, errStream)
+		}
 	}
 }
 
 func TestCliRun_versionFlag(t *testing.T) {
+	_, cleanup := testCliDir(t)
+	defer cleanup()
+
 	command := "gemer -version"
 
 	cli, outStream, _ := testCli()
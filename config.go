@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ConfigFile is the name of a project config file, which is looked up from the current directory to the git root
+const ConfigFile = ".gemer.yml"
+
+// EnvConfigPrefix is the prefix of environment variables for config keys, e.g. GEMER_BRANCH or GEMER_TEMPLATES_BRANCH
+const EnvConfigPrefix = "GEMER_"
+
+// Bumps are the values of `bump` config key
+var Bumps = []string{"major", "minor", "patch", "auto", "labels"}
+
+// Config holds the settings of gemer which can be given by config files and environment variables as well as flags
+type Config struct {
+	Username, Repository, Branch, Path, Constant string
+
+	// Forge is the kind of the forge hosting the repository, one of Forges. It is inferred from APIURL or Remote if it is empty
+	Forge string
+
+	// APIURL and UploadURL are the urls of GitHub Enterprise Server API, or APIURL is the url of GitLab or Gitea API
+	APIURL, UploadURL string
+
+	// AppID, AppKey and InstallationID are the settings of GitHub App to authenticate as
+	AppID, AppKey, InstallationID string
+
+	// Remote is the git remote Username and Repository are inferred from when they are not given
+	Remote string
+
+	// Bump is the version to increment, one of Bumps
+	Bump string
+
+	ZeroBreakingMinor bool
+	ReleaseSections   string
+	Templates         Templates
+
+	// origins records where each key is set, e.g. a path to a config file or an environment variable
+	origins map[string]string
+}
+
+// configKey binds a key of config files to a field of Config
+type configKey struct {
+	name        string
+	flag, short string
+	env         string
+	ptr         func(c *Config) *string
+}
+
+var configKeys = []configKey{
+	{name: "username", flag: "username", short: "u", ptr: func(c *Config) *string { return &c.Username }},
+	{name: "repository", flag: "repository", short: "r", ptr: func(c *Config) *string { return &c.Repository }},
+	{name: "forge", flag: "forge", ptr: func(c *Config) *string { return &c.Forge }},
+	{name: "api_url", flag: "api-url", env: EnvGitHubAPIURL, ptr: func(c *Config) *string { return &c.APIURL }},
+	{name: "upload_url", flag: "upload-url", ptr: func(c *Config) *string { return &c.UploadURL }},
+	{name: "app_id", flag: "app-id", ptr: func(c *Config) *string { return &c.AppID }},
+	{name: "app_key", flag: "app-key", ptr: func(c *Config) *string { return &c.AppKey }},
+	{name: "installation_id", flag: "installation-id", ptr: func(c *Config) *string { return &c.InstallationID }},
+	{name: "remote", flag: "remote", ptr: func(c *Config) *string { return &c.Remote }},
+	{name: "branch", flag: "branch", short: "b", ptr: func(c *Config) *string { return &c.Branch }},
+	{name: "path", flag: "path", short: "p", ptr: func(c *Config) *string { return &c.Path }},
+	{name: "constant", flag: "constant", ptr: func(c *Config) *string { return &c.Constant }},
+	{name: "bump", ptr: func(c *Config) *string { return &c.Bump }},
+	{name: "zero_breaking_minor", flag: "zero-breaking-minor"},
+	{name: "release_sections", flag: "release-sections", ptr: func(c *Config) *string { return &c.ReleaseSections }},
+	{name: "templates.branch", flag: "branch-template", ptr: func(c *Config) *string { return &c.Templates.Branch }},
+	{name: "templates.pr_title", flag: "pr-title-template", ptr: func(c *Config) *string { return &c.Templates.PullRequestTitle }},
+	{name: "templates.pr_body", flag: "pr-body-template", ptr: func(c *Config) *string { return &c.Templates.PullRequestBody }},
+	{name: "templates.release_name", flag: "release-name-template", ptr: func(c *Config) *string { return &c.Templates.ReleaseName }},
+	{name: "templates.release_body", flag: "release-body-template", ptr: func(c *Config) *string { return &c.Templates.ReleaseBody }},
+}
+
+// DefaultConfig returns the config gemer uses when nothing is given
+func DefaultConfig() *Config {
+	c := &Config{Remote: DefaultRemote, Branch: "master", Constant: DefaultConstant, Bump: "patch", ZeroBreakingMinor: true, origins: make(map[string]string)}
+
+	for _, k := range configKeys {
+		c.origins[k.name] = "default"
+	}
+
+	return c
+}
+
+// LoadConfig merges the user config, the project config found from dir and environment variables into the defaults,
+// in ascending order of precedence
+func LoadConfig(dir string) (*Config, error) {
+	c := DefaultConfig()
+
+	paths := []string{UserConfigPath()}
+
+	if p, err := FindProjectConfig(dir); err != nil {
+		return nil, err
+	} else if len(p) != 0 {
+		paths = append(paths, p)
+	}
+
+	for _, p := range paths {
+		if len(p) == 0 {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(p)
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read config file %s", p)
+		}
+
+		if err := c.merge(p, string(content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.mergeEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// UserConfigPath returns the path to the user config, that is $XDG_CONFIG_HOME/gemer/config.yml or ~/.config/gemer/config.yml
+func UserConfigPath() string {
+	if d := os.Getenv("XDG_CONFIG_HOME"); len(d) != 0 {
+		return filepath.Join(d, Name, "config.yml")
+	}
+
+	if h := os.Getenv("HOME"); len(h) != 0 {
+		return filepath.Join(h, ".config", Name, "config.yml")
+	}
+
+	return ""
+}
+
+// FindProjectConfig looks for ConfigFile from dir up to the git root. Only dir is searched if it is not in a git repository.
+// It returns an empty string if there is no config file
+func FindProjectConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find %s", ConfigFile)
+	}
+
+	var dirs []string
+
+	for d := dir; ; d = filepath.Dir(d) {
+		dirs = append(dirs, d)
+
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+
+		if filepath.Dir(d) == d {
+			dirs = dirs[:1]
+			break
+		}
+	}
+
+	for _, d := range dirs {
+		p := filepath.Join(d, ConfigFile)
+
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+
+	return "", nil
+}
+
+// merge applies a config file to the config
+func (c *Config) merge(file, content string) error {
+	entries, err := parseYAML(file, content)
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := c.set(e.Key, e.Value); err != nil {
+			return &yamlError{File: file, Line: e.Line, Column: e.Column, Message: err.Error()}
+		}
+
+		c.origins[e.Key] = file
+	}
+
+	return nil
+}
+
+// mergeEnv applies environment variables to the config
+func (c *Config) mergeEnv(getenv func(string) string) error {
+	for _, k := range configKeys {
+		env := configEnv(k)
+		v := getenv(env)
+
+		if len(v) == 0 {
+			continue
+		}
+
+		if err := c.set(k.name, v); err != nil {
+			return errors.Wrapf(err, "invalid environment variable %s", env)
+		}
+
+		c.origins[k.name] = "$" + env
+	}
+
+	return nil
+}
+
+// SetOrigin records that key is set by origin, e.g. a flag
+func (c *Config) SetOrigin(key, origin string) {
+	c.origins[key] = origin
+}
+
+// set sets a value to a field of the config by its key
+func (c *Config) set(key, value string) error {
+	switch key {
+	case "zero_breaking_minor":
+		b, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return errors.Errorf("%s must be true or false: %q", key, value)
+		}
+
+		c.ZeroBreakingMinor = b
+		return nil
+	case "bump":
+		if configBumpVersion(value) < 0 {
+			return errors.Errorf("%s must be one of %s: %q", key, strings.Join(Bumps, ", "), value)
+		}
+	case "forge":
+		if !isForge(value) {
+			return errors.Errorf("%s must be one of %s: %q", key, strings.Join(Forges, ", "), value)
+		}
+	case "release_sections":
+		if _, err := ParseReleaseNoteSections(value); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range configKeys {
+		if k.name == key {
+			*k.ptr(c) = value
+			return nil
+		}
+	}
+
+	return errors.Errorf("unknown key %q", key)
+}
+
+// BumpVersion returns the version Bump stands for
+func (c *Config) BumpVersion() int {
+	return configBumpVersion(c.Bump)
+}
+
+func configBumpVersion(bump string) int {
+	switch bump {
+	case "major":
+		return MajorVersion
+	case "minor":
+		return MinorVersion
+	case "patch":
+		return PatchVersion
+	case "auto":
+		return AutoVersion
+	case "labels":
+		return LabelVersion
+	}
+
+	return -1
+}
+
+// redacted replaces values which can be credentials in the output of `gemer config show`
+const redacted = "[REDACTED]"
+
+// credentialLike matches a private key such as the one of GitHub App given by app_key, and tokens of GitHub, GitLab and Gitea
+var credentialLike = regexp.MustCompile(`-----BEGIN|\b(gh[pousr]_[A-Za-z0-9]{20,}|github_pat_\w{20,}|glpat-[\w-]{20,}|[0-9a-f]{40})\b`)
+
+// redactConfigValue hides a value which looks like a credential, or a url with a password
+func redactConfigValue(value string) string {
+	if credentialLike.MatchString(value) {
+		return redacted
+	}
+
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return redacted
+		}
+	}
+
+	return value
+}
+
+// String formats the config as YAML, with comments which tell where each value comes from. Credentials are redacted
+func (c *Config) String() string {
+	var b bytes.Buffer
+	var parent string
+
+	for _, k := range configKeys {
+		name := k.name
+		indent := ""
+
+		if i := strings.Index(name, "."); i >= 0 {
+			if parent != name[:i] {
+				parent = name[:i]
+				fmt.Fprintf(&b, "%s:\n", parent)
+			}
+
+			name, indent = name[i+1:], "  "
+		}
+
+		var value string
+
+		if k.ptr == nil {
+			value = strconv.FormatBool(c.ZeroBreakingMinor)
+		} else {
+			value = strconv.Quote(redactConfigValue(*k.ptr(c)))
+		}
+
+		fmt.Fprintf(&b, "%s%s: %s # %s\n", indent, name, value, c.origins[k.name])
+	}
+
+	return b.String()
+}
+
+// configEnv returns the environment variable for a key, e.g. GEMER_TEMPLATES_PR_TITLE for templates.pr_title
+func configEnv(k configKey) string {
+	if len(k.env) != 0 {
+		return k.env
+	}
+
+	return EnvConfigPrefix + strings.ToUpper(strings.Replace(k.name, ".", "_", -1))
+}
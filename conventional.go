@@ -0,0 +1,94 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// conventionalHeaderRegex matches the header of a Conventional Commits message, e.g. `feat(parser)!: add foo`
+var conventionalHeaderRegex = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?:\s+(.+)$`)
+
+// breakingChangeFooterRegex matches a `BREAKING CHANGE:` footer of a Conventional Commits message
+var breakingChangeFooterRegex = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:\s`)
+
+// conventionalCommit is a commit message parsed with Conventional Commits rules
+type conventionalCommit struct {
+	Type, Scope, Description string
+	Breaking                 bool
+}
+
+// parseConventionalCommit parses a commit message, and returns nil if it does not follow Conventional Commits
+func parseConventionalCommit(message string) *conventionalCommit {
+	lines := strings.SplitN(message, "\n", 2)
+	m := conventionalHeaderRegex.FindStringSubmatch(strings.TrimSpace(lines[0]))
+
+	if m == nil {
+		return nil
+	}
+
+	cc := &conventionalCommit{Type: strings.ToLower(m[1]), Scope: m[2], Description: m[4], Breaking: m[3] == "!"}
+
+	if len(lines) > 1 && breakingChangeFooterRegex.MatchString(lines[1]) {
+		cc.Breaking = true
+	}
+
+	return cc
+}
+
+// Version returns the version the commit requires to increment, or -1 if it does not require a release
+func (cc *conventionalCommit) Version() int {
+	switch {
+	case cc.Breaking:
+		return MajorVersion
+	case cc.Type == "feat":
+		return MinorVersion
+	case cc.Type == "fix" || cc.Type == "perf":
+		return PatchVersion
+	}
+
+	return -1
+}
+
+// inferVersion infers the version to increment from commits with Conventional Commits rules, and returns
+// the commits which drove the decision as well. If zeroBreakingMinor is true, breaking changes increment
+// the minor version instead of the major version while the current version is 0.x
+func inferVersion(ccs *ComparedCommits, current string, zeroBreakingMinor bool) (int, []*ComparedCommit, error) {
+	version := -1
+	var drivers []*ComparedCommit
+
+	for _, c := range ccs.Commits {
+		cc := parseConventionalCommit(c.Message)
+
+		if cc == nil || cc.Version() < 0 {
+			continue
+		}
+
+		switch v := cc.Version(); {
+		case version < 0 || v < version:
+			version = v
+			drivers = []*ComparedCommit{c}
+		case v == version:
+			drivers = append(drivers, c)
+		}
+	}
+
+	if version < 0 {
+		return 0, nil, errors.New("failed to infer version: no commit requires a release: feat, fix, perf or breaking changes are expected")
+	}
+
+	if version == MajorVersion && zeroBreakingMinor {
+		v, err := ParseGemVersion(current)
+
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if s := v.releaseSegments(); len(s) != 0 && s[0].Number == 0 {
+			version = MinorVersion
+		}
+	}
+
+	return version, drivers, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseConventionalCommit(t *testing.T) {
+	cases := []struct {
+		message string
+		want    *conventionalCommit
+	}{
+		{message: "feat: add foo", want: &conventionalCommit{Type: "feat", Description: "add foo"}},
+		{message: "fix(parser): handle bar\n\nDetails", want: &conventionalCommit{Type: "fix", Scope: "parser", Description: "handle bar"}},
+		{message: "feat!: drop Ruby 2.2", want: &conventionalCommit{Type: "feat", Description: "drop Ruby 2.2", Breaking: true}},
+		{message: "refactor(api)!: rename baz", want: &conventionalCommit{Type: "refactor", Scope: "api", Description: "rename baz", Breaking: true}},
+		{message: "fix: handle bar\n\nBREAKING CHANGE: bar is no longer accepted", want: &conventionalCommit{Type: "fix", Description: "handle bar", Breaking: true}},
+		{message: "Merge pull request #1 from foo/bar", want: nil},
+		{message: "Update README", want: nil},
+	}
+
+	for i, tc := range cases {
+		got := parseConventionalCommit(tc.message)
+
+		if tc.want == nil {
+			if got != nil {
+				t.Fatalf("#%d parseConventionalCommit is supposed to return nil: got: %+v", i, got)
+			}
+
+			continue
+		}
+
+		if got == nil || *got != *tc.want {
+			t.Fatalf("#%d invalid commit: want: %+v, got: %+v", i, tc.want, got)
+		}
+	}
+}
+
+func TestInferVersionSuccess(t *testing.T) {
+	cases := []struct {
+		messages          []string
+		current           string
+		zeroBreakingMinor bool
+		want, drivers     int
+	}{
+		{messages: []string{"fix: a", "chore: b"}, current: "1.2.3", want: PatchVersion, drivers: 1},
+		{messages: []string{"fix: a", "feat: b", "perf: c", "feat(x): d"}, current: "1.2.3", want: MinorVersion, drivers: 2},
+		{messages: []string{"fix: a", "feat!: b"}, current: "1.2.3", want: MajorVersion, drivers: 1},
+		{messages: []string{"fix: a", "docs: b\n\nBREAKING CHANGE: c"}, current: "1.2.3", want: MajorVersion, drivers: 1},
+		{messages: []string{"feat!: a"}, current: "0.5.0", zeroBreakingMinor: true, want: MinorVersion, drivers: 1},
+		{messages: []string{"feat!: a"}, current: "0.5.0", zeroBreakingMinor: false, want: MajorVersion, drivers: 1},
+		{messages: []string{"feat!: a"}, current: "1.5.0", zeroBreakingMinor: true, want: MajorVersion, drivers: 1},
+	}
+
+	for i, tc := range cases {
+		ccs := &ComparedCommits{}
+
+		for _, m := range tc.messages {
+			ccs.Commits = append(ccs.Commits, &ComparedCommit{Author: "shuheiktgw", Message: m, HTMLURL: "https://github.com/o/r/commit/aaa"})
+		}
+
+		got, drivers, err := inferVersion(ccs, tc.current, tc.zeroBreakingMinor)
+
+		if err != nil {
+			t.Fatalf("#%d inferVersion failed: %s", i, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid version: want: %s, got: %s", i, versionName(tc.want), versionName(got))
+		}
+
+		if len(drivers) != tc.drivers {
+			t.Fatalf("#%d invalid number of drivers: want: %d, got: %d", i, tc.drivers, len(drivers))
+		}
+	}
+}
+
+func TestInferVersionFail(t *testing.T) {
+	ccs := &ComparedCommits{Commits: []*ComparedCommit{
+		{Author: "shuheiktgw", Message: "chore: a"},
+		{Author: "shuheiktgw", Message: "Update README"},
+	}}
+
+	if _, _, err := inferVersion(ccs, "1.2.3", true); err == nil {
+		t.Fatalf("inferVersion is supposed to fail")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvGHToken is the environment variable of a GitHub token for gh, GitHub CLI
+const EnvGHToken = "GH_TOKEN"
+
+// Credential is a GitHub token and where it is found, which can be shown to users unlike the token
+type Credential struct {
+	Token, Source string
+}
+
+// CredentialChain looks for a GitHub token in environment variables, the config of gh, netrc
+// and git credential helpers in order
+type CredentialChain struct {
+	Getenv func(string) string
+	Home   string
+
+	// Envs are the environment variables looked up first, EnvGitHubToken and EnvGHToken if it is nil
+	Envs []string
+
+	// GitCredential asks git credential helpers for a token of host
+	GitCredential func(host string) (string, error)
+
+	// Logf tells sources skipped because of errors if it is not nil
+	Logf func(format string, args ...interface{})
+}
+
+// NewCredentialChain creates a CredentialChain of the current user
+func NewCredentialChain() *CredentialChain {
+	return &CredentialChain{Getenv: os.Getenv, Home: os.Getenv("HOME"), GitCredential: gitCredentialFill}
+}
+
+// Find returns the first token for host found in the chain, or nil if there is none. An error reading netrc
+// does not stop the chain, and it is returned only if none of the sources has a token
+func (c *CredentialChain) Find(host string) (*Credential, error) {
+	var netrcErr error
+	envs := c.Envs
+
+	if envs == nil {
+		envs = []string{EnvGitHubToken, EnvGHToken}
+	}
+
+	for _, env := range envs {
+		if t := strings.TrimSpace(c.Getenv(env)); len(t) != 0 {
+			return &Credential{Token: t, Source: "$" + env}, nil
+		}
+	}
+
+	if p := c.ghHostsPath(); len(p) != 0 {
+		t, err := ghHostsToken(p, host)
+
+		// The config of gh is not ours, so it is skipped like a missing one if gemer cannot read it
+		if err != nil && c.Logf != nil {
+			c.Logf("==> Skip %s: %s\n", p, err)
+		}
+
+		if len(t) != 0 {
+			return &Credential{Token: t, Source: p}, nil
+		}
+	}
+
+	if p := c.netrcPath(); len(p) != 0 {
+		t, err := netrcToken(p, host)
+
+		if err != nil {
+			netrcErr = err
+
+			if c.Logf != nil {
+				c.Logf("==> Skip %s: %s\n", p, err)
+			}
+		}
+
+		if len(t) != 0 {
+			return &Credential{Token: t, Source: p}, nil
+		}
+	}
+
+	if c.GitCredential != nil {
+		if t, err := c.GitCredential(host); err == nil && len(t) != 0 {
+			return &Credential{Token: t, Source: "git credential"}, nil
+		}
+	}
+
+	return nil, netrcErr
+}
+
+func (c *CredentialChain) ghHostsPath() string {
+	if d := c.Getenv("GH_CONFIG_DIR"); len(d) != 0 {
+		return filepath.Join(d, "hosts.yml")
+	}
+
+	if d := c.Getenv("XDG_CONFIG_HOME"); len(d) != 0 {
+		return filepath.Join(d, "gh", "hosts.yml")
+	}
+
+	if len(c.Home) != 0 {
+		return filepath.Join(c.Home, ".config", "gh", "hosts.yml")
+	}
+
+	return ""
+}
+
+func (c *CredentialChain) netrcPath() string {
+	if p := c.Getenv("NETRC"); len(p) != 0 {
+		return p
+	}
+
+	if len(c.Home) != 0 {
+		return filepath.Join(c.Home, ".netrc")
+	}
+
+	return ""
+}
+
+// ghHostsToken reads oauth_token of host from hosts.yml of gh. It returns an empty string if the file does not exist
+func ghHostsToken(path, host string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+
+	// Messages of the YAML library can include values, which can be tokens
+	if err := yaml.Unmarshal(content, &hosts); err != nil {
+		return "", errors.Errorf("failed to parse %s", path)
+	}
+
+	return hosts[host].OAuthToken, nil
+}
+
+// netrcToken reads the password of host, or of api.host, from a netrc file. It returns an empty string if the file does not exist
+func netrcToken(path, host string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	var machine, fallback string
+	passwords := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(content))
+	s.Split(bufio.ScanWords)
+
+	for s.Scan() {
+		switch s.Text() {
+		case "machine":
+			if s.Scan() {
+				machine = s.Text()
+			}
+		case "default":
+			machine = ""
+		case "login", "account":
+			s.Scan()
+		case "macdef":
+			// Macros end at a blank line, which ScanWords cannot tell, so stop here as they are rare
+			return netrcLookup(passwords, fallback, host), nil
+		case "password":
+			if !s.Scan() {
+				break
+			}
+
+			if len(machine) == 0 {
+				fallback = s.Text()
+			} else if _, ok := passwords[machine]; !ok {
+				passwords[machine] = s.Text()
+			}
+		}
+	}
+
+	return netrcLookup(passwords, fallback, host), nil
+}
+
+func netrcLookup(passwords map[string]string, fallback, host string) string {
+	if p, ok := passwords[host]; ok {
+		return p
+	}
+
+	if p, ok := passwords["api."+host]; ok {
+		return p
+	}
+
+	return fallback
+}
+
+// gitCredentialFill asks git credential helpers for a password of https://host without prompting
+func gitCredentialFill(host string) (string, error) {
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n\n")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=", "GCM_INTERACTIVE=never")
+
+	out, err := cmd.Output()
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, l := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(l, "password=") {
+			return strings.TrimPrefix(l, "password="), nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentialChainFind(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	hosts := filepath.Join(dir, "home", ".config", "gh", "hosts.yml")
+	netrc := filepath.Join(dir, "home", ".netrc")
+
+	testWriteFile(t, hosts, "github.com:\n    users:\n        shuheiktgw:\n            oauth_token: gho_hosts\n    user: shuheiktgw\n    oauth_token: gho_hosts\n    git_protocol: ssh\nghe.example.com:\n    oauth_token: gho_ghe\n")
+	testWriteFile(t, netrc, "machine api.github.com login shuheiktgw password ghp_netrc\nmachine example.org\n  login foo\n  password bar\ndefault login anonymous password ghp_default\n")
+
+	cases := []struct {
+		env                   map[string]string
+		envs                  []string
+		removeHosts           bool
+		removeNetrc           bool
+		host                  string
+		wantToken, wantSource string
+	}{
+		{env: map[string]string{"GITHUB_TOKEN": "ghp_env", "GH_TOKEN": "ghp_gh"}, host: "github.com", wantToken: "ghp_env", wantSource: "$GITHUB_TOKEN"},
+		{env: map[string]string{"GITHUB_TOKEN": "ghp_env", "GITLAB_TOKEN": "glpat_env"}, envs: []string{EnvGitLabToken}, host: "gitlab.com", wantToken: "glpat_env", wantSource: "$GITLAB_TOKEN"},
+		{env: map[string]string{"GITHUB_TOKEN": "ghp_env"}, envs: []string{EnvGitLabToken}, host: "example.org", wantToken: "bar", wantSource: netrc},
+		{env: map[string]string{"GH_TOKEN": "ghp_gh"}, host: "github.com", wantToken: "ghp_gh", wantSource: "$GH_TOKEN"},
+		{host: "github.com", wantToken: "gho_hosts", wantSource: hosts},
+		{host: "ghe.example.com", wantToken: "gho_ghe", wantSource: hosts},
+		{host: "example.org", wantToken: "bar", wantSource: netrc},
+		{host: "other.example.com", wantToken: "ghp_default", wantSource: netrc},
+		{host: "github.com", removeHosts: true, wantToken: "ghp_netrc", wantSource: netrc},
+		{host: "github.com", removeHosts: true, removeNetrc: true, wantToken: "ghp_git", wantSource: "git credential"},
+	}
+
+	for i, tc := range cases {
+		c := &CredentialChain{
+			Getenv:        func(k string) string { return tc.env[k] },
+			Home:          filepath.Join(dir, "home"),
+			Envs:          tc.envs,
+			GitCredential: func(host string) (string, error) { return "ghp_git", nil },
+		}
+
+		if tc.removeHosts {
+			c.Getenv = func(k string) string {
+				if k == "GH_CONFIG_DIR" {
+					return filepath.Join(dir, "nowhere")
+				}
+
+				if k == "NETRC" && tc.removeNetrc {
+					return filepath.Join(dir, "nowhere", ".netrc")
+				}
+
+				return tc.env[k]
+			}
+		}
+
+		cred, err := c.Find(tc.host)
+
+		if err != nil {
+			t.Fatalf("#%d Find failed: %s", i, err)
+		}
+
+		if cred == nil || cred.Token != tc.wantToken || cred.Source != tc.wantSource {
+			t.Fatalf("#%d invalid credential: want: %s from %s, got: %+v", i, tc.wantToken, tc.wantSource, cred)
+		}
+
+		if strings.Contains(cred.Source, cred.Token) {
+			t.Fatalf("#%d source reveals the token: %s", i, cred.Source)
+		}
+	}
+}
+
+func TestCredentialChainFindNothing(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	c := &CredentialChain{Getenv: func(string) string { return "" }, Home: filepath.Join(dir, "home")}
+	cred, err := c.Find("github.com")
+
+	if err != nil || cred != nil {
+		t.Fatalf("Find is supposed to find nothing: %+v, %v", cred, err)
+	}
+}
+
+func TestCredentialChainFindInvalidGhHosts(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	hosts := filepath.Join(dir, "home", ".config", "gh", "hosts.yml")
+	testWriteFile(t, hosts, "github.com:\n    oauth_token: 'gho_secret' x\n")
+	testWriteFile(t, filepath.Join(dir, "home", ".netrc"), "machine github.com login shuheiktgw password ghp_netrc\n")
+
+	var logs []string
+	c := &CredentialChain{
+		Getenv: func(string) string { return "" },
+		Home:   filepath.Join(dir, "home"),
+		Logf: func(format string, args ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, args...))
+		},
+	}
+
+	cred, err := c.Find("github.com")
+
+	if err != nil {
+		t.Fatalf("Find is supposed to skip invalid hosts.yml: %s", err)
+	}
+
+	if cred == nil || cred.Token != "ghp_netrc" {
+		t.Fatalf("invalid credential: %+v", cred)
+	}
+
+	if len(logs) != 1 || !strings.Contains(logs[0], hosts) {
+		t.Fatalf("invalid hosts.yml is supposed to be logged: %q", logs)
+	}
+
+	if strings.Contains(logs[0], "gho_secret") {
+		t.Fatalf("log reveals the token: %s", logs[0])
+	}
+}
+
+func TestCredentialChainFindUnreadableNetrc(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	// A directory cannot be read as a file, which stands for an unreadable netrc
+	netrc := filepath.Join(dir, "home", ".netrc")
+	testWriteFile(t, filepath.Join(netrc, "dummy"), "")
+
+	cases := []struct {
+		gitToken  string
+		wantToken string
+		wantErr   bool
+	}{
+		{gitToken: "ghp_git", wantToken: "ghp_git"},
+		{gitToken: "", wantErr: true},
+	}
+
+	for i, tc := range cases {
+		var logs []string
+		c := &CredentialChain{
+			Getenv:        func(string) string { return "" },
+			Home:          filepath.Join(dir, "home"),
+			GitCredential: func(host string) (string, error) { return tc.gitToken, nil },
+			Logf: func(format string, args ...interface{}) {
+				logs = append(logs, fmt.Sprintf(format, args...))
+			},
+		}
+
+		cred, err := c.Find("github.com")
+
+		if tc.wantErr {
+			if err == nil || !strings.Contains(err.Error(), netrc) {
+				t.Fatalf("#%d Find is supposed to fail with netrc: %+v, %v", i, cred, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("#%d Find is supposed to skip unreadable netrc: %s", i, err)
+		}
+
+		if cred == nil || cred.Token != tc.wantToken {
+			t.Fatalf("#%d invalid credential: %+v", i, cred)
+		}
+
+		if len(logs) != 1 || !strings.Contains(logs[0], netrc) {
+			t.Fatalf("#%d unreadable netrc is supposed to be logged: %q", i, logs)
+		}
+	}
+}
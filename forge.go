@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// Kinds of forges, which are the values of `forge` config key
+const (
+	ForgeGitHub = "github"
+	ForgeGitLab = "gitlab"
+	ForgeGitea  = "gitea"
+)
+
+// Forges are the kinds of forges gemer supports
+var Forges = []string{ForgeGitHub, ForgeGitLab, ForgeGitea}
+
+// Forge is a host of git repositories gemer bumps up versions on. It covers branches, files, pull requests,
+// releases, comparisons and refs of a repository. GitHubClient is the implementation for GitHub,
+// GitLabClient is the one for GitLab, whose merge requests stand for pull requests, and GiteaClient is the one for Gitea and Forgejo
+type Forge interface {
+	// Repository returns the owner and the name of the repository
+	Repository() (owner, repo string)
+
+	// MissingPermissions returns the permissions needed to bump up the version but not granted
+	MissingPermissions() ([]string, error)
+
+	// GetBranch gets a branch, and returns nil if it does not exist
+	GetBranch(name string) (*github.Branch, error)
+
+	// CreateNewBranch creates a new branch from the head of the origin branch
+	CreateNewBranch(origin, new string) error
+
+	// DeleteLatestRef deletes the branch
+	DeleteLatestRef(branch string) error
+
+	// GetFile gets a file of the branch, and fails with an error isNotFound tells if it does not exist
+	GetFile(branch, path string) (*github.RepositoryContent, error)
+
+	// ListFiles lists names of files and directories in a directory of the branch, dir is empty for the root
+	ListFiles(branch, dir string) ([]string, error)
+
+	// UpdateFiles updates files of the branch in a single commit, and returns the sha of the commit
+	UpdateFiles(branch, message string, files map[string][]byte) (string, error)
+
+	// CreatePullRequest opens a pull request from head to base
+	CreatePullRequest(title, head, base, body string) (*github.PullRequest, error)
+
+	// ClosePullRequest closes a pull request
+	ClosePullRequest(number int) error
+
+	// ListOpenPullRequests lists open pull requests to the base branch
+	ListOpenPullRequests(base string) ([]*github.PullRequest, error)
+
+	// ListMergedPullRequestsWithCommit lists merged pull requests which contain the commit
+	ListMergedPullRequestsWithCommit(sha string) ([]*github.PullRequest, error)
+
+	// CreateRelease drafts a release of the tag
+	CreateRelease(tagName, targetCommitish, name, body string) (*github.RepositoryRelease, error)
+
+	// DeleteRelease deletes a release, which is identified by id, or by the tag on forges without release IDs
+	DeleteRelease(id int64, tag string) error
+
+	// FindRelease finds a release of the tag including drafts, and returns nil if there is none
+	FindRelease(tag string) (*github.RepositoryRelease, error)
+
+	// LatestReleaseTag returns the tag of the latest published release, or an empty string if there is none
+	LatestReleaseTag() (string, error)
+
+	// TagExists returns true if the tag exists
+	TagExists(tag string) (bool, error)
+
+	// CompareCommits lists commits from base, which is a tag or a branch, to head
+	CompareCommits(base, head string) (*ComparedCommits, error)
+}
+
+var (
+	_ Forge = (*GitHubClient)(nil)
+	_ Forge = (*GitLabClient)(nil)
+	_ Forge = (*GiteaClient)(nil)
+)
+
+// releaseTagger is implemented by forges whose releases are tags of the commit bumping up the version itself,
+// which the user brings to the base branch by hand, i.e. the local repository
+type releaseTagger interface {
+	TagsOnRelease()
+}
+
+// releaseOnMerge is implemented by forges which cannot draft releases, so creating a release tags its target right away.
+// gemer creates the release only after the pull request is merged, on the commit it is merged with, so that the tag
+// is on the base branch even if the pull request is squashed or rebased
+type releaseOnMerge interface {
+	// MergeCommit returns the commit the pull request is merged with, or an empty string if it is not merged yet
+	MergeCommit(number int) (string, error)
+}
+
+var _ releaseOnMerge = (*GitLabClient)(nil)
+
+// isForge returns true if kind is one of Forges
+func isForge(kind string) bool {
+	for _, f := range Forges {
+		if f == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DetectForge infers the kind of forge from the host of a url, e.g. ForgeGitLab for gitlab.com or gitlab.example.com,
+// and ForgeGitea for gitea.com, codeberg.org, or hosts starting with gitea. or forgejo.
+func DetectForge(rawurl string) string {
+	host := rawurl
+
+	if u, err := url.Parse(rawurl); err == nil && len(u.Host) != 0 {
+		host = u.Hostname()
+	}
+
+	host = strings.ToLower(host)
+
+	if host == GitLabHost || strings.HasPrefix(host, "gitlab.") {
+		return ForgeGitLab
+	}
+
+	if host == GiteaHost || host == CodebergHost || strings.HasPrefix(host, "gitea.") || strings.HasPrefix(host, "forgejo.") {
+		return ForgeGitea
+	}
+
+	return ForgeGitHub
+}
+
+// NotFoundError is returned by forges other than GitHub when what is asked for does not exist
+type NotFoundError struct {
+	What string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.What + " is not found"
+}
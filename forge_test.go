@@ -0,0 +1,580 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// fakeCommit is a commit of fakeForge, which holds a snapshot of every file
+type fakeCommit struct {
+	sha, parent, message, author string
+	files                        map[string]string
+}
+
+// fakeForge is an in-memory Forge with refs, commits, files, pull requests and releases, so that
+// the release flow can be tested without GitHub
+type fakeForge struct {
+	owner, repo string
+
+	branches  map[string]string
+	tags      map[string]string
+	protected map[string]bool
+	commits   map[string]*fakeCommit
+
+	pullRequests []*github.PullRequest
+	releases     []*github.RepositoryRelease
+
+	// missing is returned by MissingPermissions
+	missing []string
+
+	// fail makes the methods of the names fail, and calls records the methods called in order
+	fail  map[string]bool
+	calls []string
+
+	seq int
+}
+
+// newFakeForge creates a repository o/r with master branch, whose first commit has the files
+func newFakeForge(files map[string]string) *fakeForge {
+	f := &fakeForge{
+		owner:     "o",
+		repo:      "r",
+		branches:  make(map[string]string),
+		tags:      make(map[string]string),
+		protected: make(map[string]bool),
+		commits:   make(map[string]*fakeCommit),
+		fail:      make(map[string]bool),
+	}
+
+	f.branches["master"] = f.newCommit("", "Initial commit", files)
+
+	return f
+}
+
+func (f *fakeForge) newCommit(parent, message string, files map[string]string) string {
+	f.seq++
+	snapshot := make(map[string]string)
+
+	if p, ok := f.commits[parent]; ok {
+		for name, content := range p.files {
+			snapshot[name] = content
+		}
+	}
+
+	for name, content := range files {
+		snapshot[name] = content
+	}
+
+	sha := fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("%d:%s:%s", f.seq, parent, message))))
+	f.commits[sha] = &fakeCommit{sha: sha, parent: parent, message: message, author: "shuheiktgw", files: snapshot}
+
+	return sha
+}
+
+// commit adds a commit to the branch
+func (f *fakeForge) commit(branch, message string, files map[string]string) string {
+	sha := f.newCommit(f.branches[branch], message, files)
+	f.branches[branch] = sha
+
+	return sha
+}
+
+// tag tags the head of the branch
+func (f *fakeForge) tag(name, branch string) {
+	f.tags[name] = f.branches[branch]
+}
+
+// call records the call of the method, and fails if it is asked to
+func (f *fakeForge) call(method string) error {
+	f.calls = append(f.calls, method)
+
+	if f.fail[method] {
+		return errors.Errorf("%s failed", method)
+	}
+
+	return nil
+}
+
+// resolve returns the sha of a branch, a tag or a commit
+func (f *fakeForge) resolve(ref string) (string, error) {
+	if sha, ok := f.branches[ref]; ok {
+		return sha, nil
+	}
+
+	if sha, ok := f.tags[ref]; ok {
+		return sha, nil
+	}
+
+	if _, ok := f.commits[ref]; ok {
+		return ref, nil
+	}
+
+	return "", &NotFoundError{What: "ref " + ref}
+}
+
+func (f *fakeForge) Repository() (string, string) {
+	return f.owner, f.repo
+}
+
+func (f *fakeForge) MissingPermissions() ([]string, error) {
+	if err := f.call("MissingPermissions"); err != nil {
+		return nil, err
+	}
+
+	return f.missing, nil
+}
+
+func (f *fakeForge) GetBranch(name string) (*github.Branch, error) {
+	if err := f.call("GetBranch"); err != nil {
+		return nil, err
+	}
+
+	sha, ok := f.branches[name]
+
+	if !ok {
+		return nil, nil
+	}
+
+	return &github.Branch{Name: github.String(name), Protected: github.Bool(f.protected[name]), Commit: &github.RepositoryCommit{SHA: github.String(sha)}}, nil
+}
+
+func (f *fakeForge) CreateNewBranch(origin, new string) error {
+	if err := f.call("CreateNewBranch"); err != nil {
+		return err
+	}
+
+	sha, ok := f.branches[origin]
+
+	if !ok {
+		return &NotFoundError{What: "branch " + origin}
+	}
+
+	if _, ok := f.branches[new]; ok {
+		return errors.Errorf("reference already exists: %s", new)
+	}
+
+	f.branches[new] = sha
+
+	return nil
+}
+
+func (f *fakeForge) DeleteLatestRef(branch string) error {
+	if err := f.call("DeleteLatestRef"); err != nil {
+		return err
+	}
+
+	if _, ok := f.branches[branch]; !ok {
+		return &NotFoundError{What: "branch " + branch}
+	}
+
+	delete(f.branches, branch)
+
+	// Like GitHub, deleting the head branch closes its pull requests
+	for _, pr := range f.pullRequests {
+		if pr.GetHead().GetRef() == branch {
+			pr.State = github.String("closed")
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeForge) GetFile(branch, name string) (*github.RepositoryContent, error) {
+	if err := f.call("GetFile"); err != nil {
+		return nil, err
+	}
+
+	sha, err := f.resolve(branch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	content, ok := f.commits[sha].files[name]
+
+	if !ok {
+		return nil, &NotFoundError{What: "file " + name}
+	}
+
+	return &github.RepositoryContent{
+		Type:     github.String("file"),
+		Name:     github.String(path.Base(name)),
+		Path:     github.String(name),
+		Encoding: github.String("base64"),
+		Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+	}, nil
+}
+
+func (f *fakeForge) ListFiles(branch, dir string) ([]string, error) {
+	if err := f.call("ListFiles"); err != nil {
+		return nil, err
+	}
+
+	sha, err := f.resolve(branch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+
+	if len(dir) != 0 {
+		prefix = strings.TrimSuffix(dir, "/") + "/"
+	}
+
+	found := make(map[string]bool)
+
+	for name := range f.commits[sha].files {
+		if strings.HasPrefix(name, prefix) {
+			found[strings.SplitN(strings.TrimPrefix(name, prefix), "/", 2)[0]] = true
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, &NotFoundError{What: "directory " + dir}
+	}
+
+	var names []string
+
+	for name := range found {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (f *fakeForge) UpdateFiles(branch, message string, files map[string][]byte) (string, error) {
+	if err := f.call("UpdateFiles"); err != nil {
+		return "", err
+	}
+
+	if _, ok := f.branches[branch]; !ok {
+		return "", &NotFoundError{What: "branch " + branch}
+	}
+
+	contents := make(map[string]string)
+
+	for name, content := range files {
+		contents[name] = string(content)
+	}
+
+	return f.commit(branch, message, contents), nil
+}
+
+func (f *fakeForge) CreatePullRequest(title, head, base, body string) (*github.PullRequest, error) {
+	if err := f.call("CreatePullRequest"); err != nil {
+		return nil, err
+	}
+
+	for _, b := range []string{head, base} {
+		if _, ok := f.branches[b]; !ok {
+			return nil, &NotFoundError{What: "branch " + b}
+		}
+	}
+
+	for _, pr := range f.pullRequests {
+		if pr.GetState() == "open" && pr.GetHead().GetRef() == head && pr.GetBase().GetRef() == base {
+			return nil, errors.Errorf("a pull request already exists for %s", head)
+		}
+	}
+
+	number := len(f.pullRequests) + 1
+	pr := &github.PullRequest{
+		Number:  github.Int(number),
+		Title:   github.String(title),
+		Body:    github.String(body),
+		State:   github.String("open"),
+		HTMLURL: github.String(fmt.Sprintf("https://github.com/%s/%s/pull/%d", f.owner, f.repo, number)),
+		Head:    &github.PullRequestBranch{Ref: github.String(head), SHA: github.String(f.branches[head])},
+		Base:    &github.PullRequestBranch{Ref: github.String(base)},
+	}
+
+	f.pullRequests = append(f.pullRequests, pr)
+
+	return pr, nil
+}
+
+// mergePullRequest opens a pull request with the labels, and merges it into base
+func (f *fakeForge) mergePullRequest(title, base string, labels ...string) *github.PullRequest {
+	head := fmt.Sprintf("feature%d", len(f.pullRequests)+1)
+	f.branches[head] = f.branches[base]
+	f.commit(head, title, map[string]string{head: title})
+
+	pr, _ := f.CreatePullRequest(title, head, base, "")
+	f.calls = f.calls[:len(f.calls)-1]
+
+	for _, l := range labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(l)})
+	}
+
+	f.branches[base] = f.branches[head]
+	pr.State = github.String("closed")
+	pr.MergedAt = &time.Time{}
+
+	return pr
+}
+
+func (f *fakeForge) ClosePullRequest(number int) error {
+	if err := f.call("ClosePullRequest"); err != nil {
+		return err
+	}
+
+	if number < 1 || len(f.pullRequests) < number {
+		return &NotFoundError{What: fmt.Sprintf("pull request #%d", number)}
+	}
+
+	f.pullRequests[number-1].State = github.String("closed")
+
+	return nil
+}
+
+func (f *fakeForge) ListOpenPullRequests(base string) ([]*github.PullRequest, error) {
+	if err := f.call("ListOpenPullRequests"); err != nil {
+		return nil, err
+	}
+
+	var prs []*github.PullRequest
+
+	for _, pr := range f.pullRequests {
+		if pr.GetState() == "open" && pr.GetBase().GetRef() == base {
+			prs = append(prs, pr)
+		}
+	}
+
+	return prs, nil
+}
+
+func (f *fakeForge) ListMergedPullRequestsWithCommit(sha string) ([]*github.PullRequest, error) {
+	if err := f.call("ListMergedPullRequestsWithCommit"); err != nil {
+		return nil, err
+	}
+
+	var prs []*github.PullRequest
+
+	for _, pr := range f.pullRequests {
+		if pr.MergedAt != nil && pr.GetHead().GetSHA() == sha {
+			prs = append(prs, pr)
+		}
+	}
+
+	return prs, nil
+}
+
+func (f *fakeForge) CreateRelease(tagName, targetCommitish, name, body string) (*github.RepositoryRelease, error) {
+	if err := f.call("CreateRelease"); err != nil {
+		return nil, err
+	}
+
+	if _, err := f.resolve(targetCommitish); err != nil {
+		return nil, err
+	}
+
+	for _, r := range f.releases {
+		if r.GetTagName() == tagName {
+			return nil, errors.Errorf("a release of %s already exists", tagName)
+		}
+	}
+
+	f.seq++
+	r := &github.RepositoryRelease{
+		ID:              github.Int64(int64(f.seq)),
+		TagName:         github.String(tagName),
+		TargetCommitish: github.String(targetCommitish),
+		Name:            github.String(name),
+		Body:            github.String(body),
+		Draft:           github.Bool(true),
+		HTMLURL:         github.String(fmt.Sprintf("https://github.com/%s/%s/releases/tag/%s", f.owner, f.repo, tagName)),
+	}
+
+	f.releases = append(f.releases, r)
+
+	return r, nil
+}
+
+func (f *fakeForge) DeleteRelease(id int64, tag string) error {
+	if err := f.call("DeleteRelease"); err != nil {
+		return err
+	}
+
+	for i, r := range f.releases {
+		if r.GetID() == id {
+			f.releases = append(f.releases[:i], f.releases[i+1:]...)
+			return nil
+		}
+	}
+
+	return &NotFoundError{What: fmt.Sprintf("release %d", id)}
+}
+
+func (f *fakeForge) FindRelease(tag string) (*github.RepositoryRelease, error) {
+	if err := f.call("FindRelease"); err != nil {
+		return nil, err
+	}
+
+	for _, r := range f.releases {
+		if r.GetTagName() == tag {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (f *fakeForge) LatestReleaseTag() (string, error) {
+	if err := f.call("LatestReleaseTag"); err != nil {
+		return "", err
+	}
+
+	for i := len(f.releases) - 1; i >= 0; i-- {
+		if !f.releases[i].GetDraft() {
+			return f.releases[i].GetTagName(), nil
+		}
+	}
+
+	return "", nil
+}
+
+func (f *fakeForge) TagExists(tag string) (bool, error) {
+	if err := f.call("TagExists"); err != nil {
+		return false, err
+	}
+
+	_, ok := f.tags[tag]
+
+	return ok, nil
+}
+
+func (f *fakeForge) CompareCommits(base, head string) (*ComparedCommits, error) {
+	if err := f.call("CompareCommits"); err != nil {
+		return nil, err
+	}
+
+	from, err := f.resolve(base)
+
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := f.resolve(head)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var ccs []*ComparedCommit
+
+	for sha := to; sha != from; sha = f.commits[sha].parent {
+		if len(sha) == 0 {
+			return nil, errors.Errorf("%s is not an ancestor of %s", base, head)
+		}
+
+		c := f.commits[sha]
+		ccs = append([]*ComparedCommit{{SHA: c.sha, Author: c.author, Message: c.message, HTMLURL: "https://github.com/o/r/commit/" + c.sha}}, ccs...)
+	}
+
+	return &ComparedCommits{Commits: ccs}, nil
+}
+
+func TestFakeForge(t *testing.T) {
+	f := newFakeForge(map[string]string{"lib/r/version.rb": "VERSION = '0.1.0'\n", "r.gemspec": ""})
+	f.tag("v0.1.0", "master")
+	pr := f.mergePullRequest("feat: add foo", "master", "feature")
+
+	if err := f.CreateNewBranch("master", "bump"); err != nil {
+		t.Fatalf("CreateNewBranch failed: %s", err)
+	}
+
+	if err := f.CreateNewBranch("master", "bump"); err == nil {
+		t.Fatalf("CreateNewBranch is supposed to fail for an existing branch")
+	}
+
+	if _, err := f.UpdateFiles("bump", "Bumps up to 0.1.1", map[string][]byte{"lib/r/version.rb": []byte("VERSION = '0.1.1'\n")}); err != nil {
+		t.Fatalf("UpdateFiles failed: %s", err)
+	}
+
+	for branch, want := range map[string]string{"master": "VERSION = '0.1.0'\n", "bump": "VERSION = '0.1.1'\n"} {
+		rc, err := f.GetFile(branch, "lib/r/version.rb")
+
+		if err != nil {
+			t.Fatalf("GetFile failed: %s", err)
+		}
+
+		if got, _ := decodeContent(rc); got != want {
+			t.Fatalf("invalid content of %s: want: %q, got: %q", branch, want, got)
+		}
+	}
+
+	if _, err := f.GetFile("master", "unknown"); !isNotFound(err) {
+		t.Fatalf("GetFile is supposed to fail with not found: %v", err)
+	}
+
+	if names, _ := f.ListFiles("master", ""); fmt.Sprint(names) != "[feature1 lib r.gemspec]" {
+		t.Fatalf("invalid files: %q", names)
+	}
+
+	ccs, err := f.CompareCommits("v0.1.0", "bump")
+
+	if err != nil {
+		t.Fatalf("CompareCommits failed: %s", err)
+	}
+
+	if len(ccs.Commits) != 2 || ccs.Commits[0].Message != "feat: add foo" {
+		t.Fatalf("invalid commits: %s", ccs)
+	}
+
+	if prs, _ := f.ListMergedPullRequestsWithCommit(ccs.Commits[0].SHA); len(prs) != 1 || prs[0] != pr {
+		t.Fatalf("invalid merged pull requests: %v", prs)
+	}
+
+	if _, err := f.CreatePullRequest("Bumps up to 0.1.1", "bump", "master", ""); err != nil {
+		t.Fatalf("CreatePullRequest failed: %s", err)
+	}
+
+	if err := f.DeleteLatestRef("bump"); err != nil {
+		t.Fatalf("DeleteLatestRef failed: %s", err)
+	}
+
+	if prs, _ := f.ListOpenPullRequests("master"); len(prs) != 0 {
+		t.Fatalf("pull requests are supposed to be closed with their branch: %v", prs)
+	}
+
+	f.fail["GetBranch"] = true
+
+	if _, err := f.GetBranch("master"); err == nil {
+		t.Fatalf("GetBranch is supposed to fail")
+	}
+}
+
+func TestDetectForge(t *testing.T) {
+	cases := []struct {
+		url, want string
+	}{
+		{url: "gitlab.com", want: ForgeGitLab},
+		{url: "https://gitlab.example.com/api/v4/", want: ForgeGitLab},
+		{url: "https://GitLab.com", want: ForgeGitLab},
+		{url: "github.com", want: ForgeGitHub},
+		{url: "https://github.example.com/api/v3/", want: ForgeGitHub},
+		{url: "https://mygitlab.example.com/", want: ForgeGitHub},
+		{url: "gitea.com", want: ForgeGitea},
+		{url: "https://codeberg.org/api/v1/", want: ForgeGitea},
+		{url: "https://gitea.example.com/", want: ForgeGitea},
+		{url: "https://forgejo.example.com/api/v1/", want: ForgeGitea},
+	}
+
+	for i, tc := range cases {
+		if got := DetectForge(tc.url); got != tc.want {
+			t.Fatalf("#%d invalid forge of %s: want: %s, got: %s", i, tc.url, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// gemVersionRegex is the same pattern as Gem::Version::ANCHORED_VERSION_PATTERN
+var gemVersionRegex = regexp.MustCompile(`^\s*([0-9]+(\.[0-9a-zA-Z]+)*(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?)?\s*$`)
+
+var gemSegmentRegex = regexp.MustCompile(`[0-9]+|[a-zA-Z]+`)
+
+var alphaRegex = regexp.MustCompile(`^[a-zA-Z]+$`)
+
+// semverRegex matches a version of Semantic Versioning 2.0.0, e.g. 1.3.0-rc.1+build.5
+var semverRegex = regexp.MustCompile(`^(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?$`)
+
+var numericRegex = regexp.MustCompile(`^[0-9]+$`)
+
+var trailingNumberRegex = regexp.MustCompile(`[0-9]+$`)
+
+// GemVersion represents a version of a gem, and follows the parsing and ordering rules of Gem::Version.
+// version is the string as it is given, whose `-` is read as `.pre.` only in segments, e.g. 1.0-rc1
+type GemVersion struct {
+	version  string
+	segments []gemSegment
+}
+
+// gemSegment is either a numeric or a string segment of a GemVersion
+type gemSegment struct {
+	Number  uint64
+	Text    string
+	Numeric bool
+}
+
+// ParseGemVersion parses a version string in the same way as Gem::Version.new does
+func ParseGemVersion(version string) (*GemVersion, error) {
+	if !gemVersionRegex.MatchString(version) {
+		return nil, errors.Errorf("malformed version number string: %s", version)
+	}
+
+	v := strings.TrimSpace(version)
+
+	if len(v) == 0 {
+		v = "0"
+	}
+
+	var segments []gemSegment
+
+	for _, s := range gemSegmentRegex.FindAllString(strings.Replace(v, "-", ".pre.", -1), -1) {
+		if s[0] < '0' || s[0] > '9' {
+			segments = append(segments, gemSegment{Text: s})
+			continue
+		}
+
+		n, err := strconv.ParseUint(s, 10, 64)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse version segment: version: %s", version)
+		}
+
+		segments = append(segments, gemSegment{Number: n, Numeric: true})
+	}
+
+	return &GemVersion{version: v, segments: segments}, nil
+}
+
+func (v *GemVersion) String() string {
+	return v.version
+}
+
+// IsPrerelease returns true if the version contains a letter, e.g. 1.3.0.rc1, or `-`, e.g. 1.0-1
+func (v *GemVersion) IsPrerelease() bool {
+	return len(v.releaseSegments()) != len(v.segments)
+}
+
+// Release returns the release version of a pre-release, e.g. 1.3.0 for 1.3.0.rc1
+func (v *GemVersion) Release() *GemVersion {
+	if !v.IsPrerelease() {
+		return v
+	}
+
+	return newGemVersion(v.releaseSegments())
+}
+
+// Increment returns the next release version, incrementing the major, minor or patch segment
+// and resetting the following segments to zero. The number of segments is kept, e.g. 1.2.3.4 to 1.2.4.0
+func (v *GemVersion) Increment(version int) (*GemVersion, error) {
+	if version != MajorVersion && version != MinorVersion && version != PatchVersion {
+		return nil, errors.Errorf("invalid version to increment: %d", version)
+	}
+
+	segments := v.releaseSegments()
+
+	for len(segments) <= version {
+		segments = append(segments, gemSegment{Numeric: true})
+	}
+
+	segments[version].Number++
+
+	for i := version + 1; i < len(segments); i++ {
+		segments[i].Number = 0
+	}
+
+	return newGemVersion(segments), nil
+}
+
+// PreRelease returns the next pre-release version with a given label. If the version already
+// is a pre-release of the same label, its number is incremented (e.g. rc1 to rc2), otherwise
+// the label starts from 1 (e.g. 1.3.0 or 1.3.0.beta2 to 1.3.0.rc1)
+func (v *GemVersion) PreRelease(label string) (*GemVersion, error) {
+	if v.IsPrerelease() && v.preReleaseLabel() == label {
+		if m := trailingNumberRegex.FindString(v.version); len(m) != 0 {
+			n, err := strconv.ParseUint(m, 10, 64)
+
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse pre-release number: version: %s", v.version)
+			}
+
+			return ParseGemVersion(strings.TrimSuffix(v.version, m) + strconv.FormatUint(n+1, 10))
+		}
+	}
+
+	return ParseGemVersion(v.Release().String() + "." + label + "1")
+}
+
+// Semver converts the version to the form of Semantic Versioning, e.g. 1.3.0.rc1 to 1.3.0-rc.1 and 1.2 to 1.2.0.
+// It fails if the version has more than three numeric segments before its pre-release part, e.g. 1.2.3.4
+func (v *GemVersion) Semver() (string, error) {
+	release := v.releaseSegments()
+
+	if len(release) > 3 {
+		return "", errors.Errorf("failed to convert version to semver: more than three segments: version: %s", v.version)
+	}
+
+	for len(release) < 3 {
+		release = append(release, gemSegment{Numeric: true})
+	}
+
+	pre := v.segments[len(v.releaseSegments()):]
+
+	// `-` of the version already stands for the start of the pre-release part, which segments spell `pre`
+	if strings.Contains(v.version, "-") && len(pre) != 0 && pre[0].Text == "pre" {
+		pre = pre[1:]
+	}
+
+	version := newGemVersion(release).String()
+
+	if len(pre) != 0 {
+		version += "-" + newGemVersion(pre).String()
+	}
+
+	return version, nil
+}
+
+// ParseSemver parses a version of Semantic Versioning into the Gem::Version form, e.g. 1.3.0-rc.1 to 1.3.0.rc1.
+// Build metadata is dropped as Gem::Version has nothing like it
+func ParseSemver(version string) (*GemVersion, error) {
+	m := semverRegex.FindStringSubmatch(version)
+
+	if m == nil {
+		return nil, errors.Errorf("malformed semver string: %s", version)
+	}
+
+	v := m[1] + "." + m[2] + "." + m[3]
+
+	if len(m[4]) == 0 {
+		return ParseGemVersion(v)
+	}
+
+	var prev string
+
+	for _, id := range strings.Split(m[4], ".") {
+		if strings.Contains(id, "-") {
+			return nil, errors.Errorf("failed to convert semver to version: `-` in pre-release: %s", version)
+		}
+
+		// A number following a label is joined to it, e.g. rc.1 to rc1, in the way rubygems spell pre-releases
+		if alphaRegex.MatchString(prev) && numericRegex.MatchString(id) {
+			v += id
+		} else {
+			v += "." + id
+		}
+
+		prev = id
+	}
+
+	return ParseGemVersion(v)
+}
+
+// Compare compares two versions with Gem::Version#<=> semantics and returns -1, 0 or 1
+func (v *GemVersion) Compare(other *GemVersion) int {
+	if v.version == other.version {
+		return 0
+	}
+
+	lhs, rhs := v.canonicalSegments(), other.canonicalSegments()
+	limit := len(lhs)
+
+	if len(rhs) > limit {
+		limit = len(rhs)
+	}
+
+	for i := 0; i < limit; i++ {
+		l, r := gemSegment{Numeric: true}, gemSegment{Numeric: true}
+
+		if i < len(lhs) {
+			l = lhs[i]
+		}
+
+		if i < len(rhs) {
+			r = rhs[i]
+		}
+
+		if l == r {
+			continue
+		}
+
+		if !l.Numeric && r.Numeric {
+			return -1
+		}
+
+		if l.Numeric && !r.Numeric {
+			return 1
+		}
+
+		if l.Numeric {
+			if l.Number < r.Number {
+				return -1
+			}
+
+			return 1
+		}
+
+		if l.Text < r.Text {
+			return -1
+		}
+
+		return 1
+	}
+
+	return 0
+}
+
+// preReleaseLabel returns the last string segment, e.g. rc for 1.3.0.rc1
+func (v *GemVersion) preReleaseLabel() string {
+	for i := len(v.segments) - 1; i >= 0; i-- {
+		if !v.segments[i].Numeric {
+			return v.segments[i].Text
+		}
+	}
+
+	return ""
+}
+
+// releaseSegments returns a copy of the numeric segments before the first string segment
+func (v *GemVersion) releaseSegments() []gemSegment {
+	var segments []gemSegment
+
+	for _, s := range v.segments {
+		if !s.Numeric {
+			break
+		}
+
+		segments = append(segments, s)
+	}
+
+	return segments
+}
+
+// canonicalSegments mirrors Gem::Version#canonical_segments, which drops trailing zeros
+// from both of the numeric part and the string part
+func (v *GemVersion) canonicalSegments() []gemSegment {
+	numeric := v.releaseSegments()
+	str := v.segments[len(numeric):]
+
+	return append(dropTrailingZeros(numeric), dropTrailingZeros(str)...)
+}
+
+func dropTrailingZeros(segments []gemSegment) []gemSegment {
+	i := len(segments)
+
+	for i > 0 && segments[i-1].Numeric && segments[i-1].Number == 0 {
+		i--
+	}
+
+	return append([]gemSegment(nil), segments[:i]...)
+}
+
+func newGemVersion(segments []gemSegment) *GemVersion {
+	var parts []string
+
+	for _, s := range segments {
+		if s.Numeric {
+			parts = append(parts, strconv.FormatUint(s.Number, 10))
+			continue
+		}
+
+		parts = append(parts, s.Text)
+	}
+
+	return &GemVersion{version: strings.Join(parts, "."), segments: segments}
+}
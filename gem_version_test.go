@@ -0,0 +1,209 @@
+package main
+
+import (
+	"testing"
+)
+
+// The cases below are taken from test_gem_version.rb of rubygems
+
+func TestParseGemVersionFail(t *testing.T) {
+	cases := []string{"junk", "1.0\n2.0", "1..2", "1.2 3.4", "1.2.3-", "1.-2"}
+
+	for i, tc := range cases {
+		if _, err := ParseGemVersion(tc); err == nil {
+			t.Fatalf("#%d ParseGemVersion is supposed to fail: version: %q", i, tc)
+		}
+	}
+}
+
+func TestParseGemVersionSuccess(t *testing.T) {
+	cases := []struct {
+		version, want string
+	}{
+		{version: "1.2.3", want: "1.2.3"},
+		{version: "1.2", want: "1.2"},
+		{version: "1.2.3.4", want: "1.2.3.4"},
+		{version: "2.0.0.beta1", want: "2.0.0.beta1"},
+		{version: " 1.0 ", want: "1.0"},
+		{version: "", want: "0"},
+		{version: "1.0-rc1", want: "1.0-rc1"},
+		{version: "1-1", want: "1-1"},
+	}
+
+	for i, tc := range cases {
+		v, err := ParseGemVersion(tc.version)
+
+		if err != nil {
+			t.Fatalf("#%d ParseGemVersion failed: %s", i, err)
+		}
+
+		if v.String() != tc.want {
+			t.Fatalf("#%d invalid version: want: %s, got: %s", i, tc.want, v)
+		}
+	}
+}
+
+func TestGemVersionCompare(t *testing.T) {
+	cases := []struct {
+		lhs, rhs string
+		want     int
+	}{
+		{lhs: "1.0", rhs: "1.0.0", want: 0},
+		{lhs: "1.0", rhs: "1.0.a", want: 1},
+		{lhs: "1.8.2", rhs: "0.0.0", want: 1},
+		{lhs: "1.8.2", rhs: "1.8.2.a", want: 1},
+		{lhs: "1.8.2.b", rhs: "1.8.2.a", want: 1},
+		{lhs: "1.8.2.a", rhs: "1.8.2", want: -1},
+		{lhs: "1.8.2.a10", rhs: "1.8.2.a9", want: 1},
+		{lhs: "", rhs: "0", want: 0},
+		{lhs: "0.beta.1", rhs: "0.0.beta.1", want: 0},
+		{lhs: "0.0.beta", rhs: "0.0.beta.1", want: -1},
+		{lhs: "0.0.beta", rhs: "0.beta.1", want: -1},
+		{lhs: "5.a", rhs: "5.0.0.rc2", want: -1},
+		{lhs: "5.x", rhs: "5.0.0.rc2", want: 1},
+		{lhs: "1.2.b1", rhs: "1.2.b.1", want: 0},
+		{lhs: "1.0.a", rhs: "1.0.0.a", want: 0},
+		{lhs: "1.3.0.rc1", rhs: "1.3.0.beta2", want: 1},
+		{lhs: "1.3.0.alpha1", rhs: "1.3.0.beta1", want: -1},
+		{lhs: "1.2.3.4", rhs: "1.2.3", want: 1},
+		{lhs: "1.10", rhs: "1.9", want: 1},
+	}
+
+	for i, tc := range cases {
+		lhs, err := ParseGemVersion(tc.lhs)
+
+		if err != nil {
+			t.Fatalf("#%d ParseGemVersion failed: %s", i, err)
+		}
+
+		rhs, err := ParseGemVersion(tc.rhs)
+
+		if err != nil {
+			t.Fatalf("#%d ParseGemVersion failed: %s", i, err)
+		}
+
+		if got := lhs.Compare(rhs); got != tc.want {
+			t.Fatalf("#%d %s <=> %s: want: %d, got: %d", i, tc.lhs, tc.rhs, tc.want, got)
+		}
+	}
+}
+
+func TestGemVersionIsPrerelease(t *testing.T) {
+	cases := []struct {
+		version string
+		want    bool
+	}{
+		{version: "1.2.0.a", want: true},
+		{version: "2.9.b", want: true},
+		{version: "22.1.50.0.d", want: true},
+		{version: "1.2.d.42", want: true},
+		{version: "1.A", want: true},
+		{version: "1-1", want: true},
+		{version: "1-a", want: true},
+		{version: "1.2.0", want: false},
+		{version: "2.9", want: false},
+		{version: "22.1.50.0", want: false},
+	}
+
+	for i, tc := range cases {
+		v, err := ParseGemVersion(tc.version)
+
+		if err != nil {
+			t.Fatalf("#%d ParseGemVersion failed: %s", i, err)
+		}
+
+		if got := v.IsPrerelease(); got != tc.want {
+			t.Fatalf("#%d %s: want: %t, got: %t", i, tc.version, tc.want, got)
+		}
+	}
+}
+
+func TestGemVersionRelease(t *testing.T) {
+	cases := []struct {
+		version, want string
+	}{
+		{version: "1.2.0.a", want: "1.2.0"},
+		{version: "1.1.rc10", want: "1.1"},
+		{version: "1.9.3.alpha.5", want: "1.9.3"},
+		{version: "1.9.3", want: "1.9.3"},
+	}
+
+	for i, tc := range cases {
+		v, err := ParseGemVersion(tc.version)
+
+		if err != nil {
+			t.Fatalf("#%d ParseGemVersion failed: %s", i, err)
+		}
+
+		if got := v.Release().String(); got != tc.want {
+			t.Fatalf("#%d invalid release: want: %s, got: %s", i, tc.want, got)
+		}
+	}
+}
+
+func TestGemVersionSemver(t *testing.T) {
+	cases := []struct {
+		version, want string
+	}{
+		{version: "1.2.3", want: "1.2.3"},
+		{version: "1.2", want: "1.2.0"},
+		{version: "1.3.0.rc1", want: "1.3.0-rc.1"},
+		{version: "2.0.0.beta", want: "2.0.0-beta"},
+		{version: "1.0.0-rc.1", want: "1.0.0-rc.1"},
+		{version: "1.0.0.pre.rc1", want: "1.0.0-pre.rc.1"},
+	}
+
+	for i, tc := range cases {
+		v, err := ParseGemVersion(tc.version)
+
+		if err != nil {
+			t.Fatalf("#%d ParseGemVersion failed: %s", i, err)
+		}
+
+		got, err := v.Semver()
+
+		if err != nil {
+			t.Fatalf("#%d Semver failed: %s", i, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid semver: want: %s, got: %s", i, tc.want, got)
+		}
+	}
+
+	v, _ := ParseGemVersion("1.2.3.4")
+
+	if _, err := v.Semver(); err == nil {
+		t.Fatalf("Semver is supposed to fail: version: %s", v)
+	}
+}
+
+func TestParseSemver(t *testing.T) {
+	cases := []struct {
+		version, want string
+	}{
+		{version: "1.2.3", want: "1.2.3"},
+		{version: "1.3.0-rc.1", want: "1.3.0.rc1"},
+		{version: "1.3.0-rc", want: "1.3.0.rc"},
+		{version: "1.3.0-beta.2+build.5", want: "1.3.0.beta2"},
+		{version: "1.3.0-0.3.7", want: "1.3.0.0.3.7"},
+	}
+
+	for i, tc := range cases {
+		v, err := ParseSemver(tc.version)
+
+		if err != nil {
+			t.Fatalf("#%d ParseSemver failed: %s", i, err)
+		}
+
+		if v.String() != tc.want {
+			t.Fatalf("#%d invalid version: want: %s, got: %s", i, tc.want, v)
+		}
+	}
+
+	for i, tc := range []string{"1.2", "01.2.3", "1.2.3-", "1.3.0-x-y", "1.3.0.rc1"} {
+		if _, err := ParseSemver(tc); err == nil {
+			t.Fatalf("#%d ParseSemver is supposed to fail: version: %q", i, tc)
+		}
+	}
+}
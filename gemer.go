@@ -1,14 +1,14 @@
 package main
 
 import (
-	"regexp"
-	"strings"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"time"
 	"encoding/base64"
 
 	"github.com/pkg/errors"
-	"github.com/blang/semver"
 	"github.com/google/go-github/github"
 )
 
@@ -16,26 +16,209 @@ const (
 	MajorVersion = iota
 	MinorVersion
 	PatchVersion
+	PromoteVersion
+	AutoVersion
+	LabelVersion
 )
 
-var versionRegex = regexp.MustCompile(`VERSION\s*=\s*['"](\d+\.\d+\.\d+)['"]`)
+// PreReleases lists pre-release labels gemer can bump, from the lowest to the highest
+var PreReleases = []string{"alpha", "beta", "rc"}
 
-// Gemer wraps GithubClient and simplifies interactions with GitHub API
+// Gemer wraps Forge and simplifies interactions with GitHub API
 type Gemer struct {
-	GitHubClient *GitHubClient
+	Forge Forge
 	outStream io.Writer
+
+	// Templates are used for the names and texts of the branch, the pull request and the release, DefaultTemplates is used if nil
+	Templates *Templates
+
+	// ReleaseNoteSections maps labels of pull requests to sections of release notes. If nil, release notes list commits,
+	// or pull requests in DefaultReleaseNoteSections when they are looked up for LabelVersion
+	ReleaseNoteSections []*ReleaseNoteSection
+
+	// JournalDir is the directory to record runs in, so that they can be resumed or rolled back later.
+	// Runs are not recorded if it is empty
+	JournalDir string
+
+	// ZeroBreakingMinor makes breaking changes increment the minor version instead of the major one
+	// while the version is 0.x, when the version is inferred from commits
+	ZeroBreakingMinor bool
+}
+
+// versionBump holds everything calculated before making changes to bump up the version of a gem
+type versionBump struct {
+	Current, Next string
+	Commits *ComparedCommits
+	PullRequests []*github.PullRequest
+	ReleaseNotes string
+	Files map[string][]byte
+	Rendered *RenderedTemplates
 }
 
 type UpdateVersionResult struct {
 	Branch string
+	Tag string
 	PrNumber int
 	ReleaseID int64
 	PrURL string
 	ReleaseURL string
+
+	// ReleasePending is true if the release is created by `gemer resume` after the pull request is merged
+	ReleasePending bool
 }
 
-func (g *Gemer) UpdateVersion(branch, path string, version int) (*UpdateVersionResult, error) {
-	rc, err := g.GitHubClient.GetVersion(branch, path)
+func (g *Gemer) UpdateVersion(branch string, source VersionSource, version int, pre string) (*UpdateVersionResult, error) {
+	b, err := g.prepareVersionBump(branch, source, version, pre)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(g.outStream, "==> Check the repository before making any changes")
+
+	if err := g.preflight(branch, b); err != nil {
+		return nil, err
+	}
+
+	j := newJournal(g.JournalDir, g.Forge, branch, b)
+
+	if err := j.Save(); err != nil {
+		return nil, err
+	}
+
+	return g.runJournal(j)
+}
+
+// Resume resumes a run recorded in the journal from the last completed step
+func (g *Gemer) Resume(j *Journal) (*UpdateVersionResult, error) {
+	fmt.Fprintf(g.outStream, "==> Resume bumping up %s/%s to %s, completed steps: %s\n", j.Owner, j.Repo, j.Next, strings.Join(j.Completed, ", "))
+
+	return g.runJournal(j)
+}
+
+// Rollback undoes what the journal recorded
+func (g *Gemer) Rollback(j *Journal) error {
+	return g.rollbackUpdateVersion(nil, j)
+}
+
+// runJournal runs the steps not completed in the journal, recording each of them, and rolls back if any of them fails
+func (g *Gemer) runJournal(j *Journal) (*UpdateVersionResult, error) {
+	if !j.Done(StepBranch) {
+		fmt.Fprintln(g.outStream, "==> Create a new branch")
+
+		if err := g.Forge.CreateNewBranch(j.Base, j.Templates.Branch); err != nil {
+			return nil, g.rollbackUpdateVersion(err, j)
+		}
+
+		if err := j.Complete(StepBranch); err != nil {
+			return j.Result(), g.rollbackUpdateVersion(err, j)
+		}
+	}
+
+	if !j.Done(StepCommit) {
+		fmt.Fprintf(g.outStream, "==> Update %s\n", joinPaths(j.Files))
+		sha, err := g.Forge.UpdateFiles(j.Templates.Branch, j.Templates.PullRequestTitle, j.Files)
+
+		if err != nil {
+			return j.Result(), g.rollbackUpdateVersion(err, j)
+		}
+
+		j.CommitSHA = sha
+
+		if err := j.Complete(StepCommit); err != nil {
+			return j.Result(), g.rollbackUpdateVersion(err, j)
+		}
+	}
+
+	if !j.Done(StepPullRequest) {
+		fmt.Fprintln(g.outStream, "==> Create a new pull request")
+		pr, err := g.Forge.CreatePullRequest(j.Templates.PullRequestTitle, j.Templates.Branch, j.Base, j.Templates.PullRequestBody)
+
+		if err != nil {
+			return j.Result(), g.rollbackUpdateVersion(err, j)
+		}
+
+		j.PrNumber, j.PrURL = pr.GetNumber(), pr.GetHTMLURL()
+
+		if err := j.Complete(StepPullRequest); err != nil {
+			return j.Result(), g.rollbackUpdateVersion(err, j)
+		}
+	}
+
+	if !j.Done(StepRelease) {
+		target := j.Base
+
+		if _, ok := g.Forge.(releaseTagger); ok {
+			target = j.CommitSHA
+		}
+
+		if m, ok := g.Forge.(releaseOnMerge); ok {
+			sha, err := m.MergeCommit(j.PrNumber)
+
+			// Nothing has to be rolled back, and the journal is kept to try again
+			if err != nil {
+				return j.Result(), err
+			}
+
+			if len(sha) == 0 {
+				fmt.Fprintln(g.outStream, "==> Wait for the pull request to be merged to create a release")
+				return j.Result(), nil
+			}
+
+			target = sha
+		}
+
+		fmt.Fprintln(g.outStream, "==> Create a release")
+
+		release, err := g.Forge.CreateRelease("v"+j.Next, target, j.Templates.ReleaseName, j.Templates.ReleaseBody)
+
+		if err != nil {
+			return j.Result(), g.rollbackUpdateVersion(err, j)
+		}
+
+		j.ReleaseID, j.ReleaseURL = release.GetID(), release.GetHTMLURL()
+
+		if err := j.Complete(StepRelease); err != nil {
+			return j.Result(), g.rollbackUpdateVersion(err, j)
+		}
+	}
+
+	return j.Result(), j.Remove()
+}
+
+func(g *Gemer) DryUpdateVersion(branch string, source VersionSource, version int, pre string) error {
+	b, err := g.prepareVersionBump(branch, source, version, pre)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(g.outStream, "==> Create a branch named `%s`\n", b.Rendered.Branch)
+	fmt.Fprintf(g.outStream, "==> Update the version in `%s` of the branch from `%s` to `%s`\n", source.Path(), b.Current, b.Next)
+
+	if _, ok := b.Files[GemfileLock]; ok {
+		fmt.Fprintf(g.outStream, "==> Update the version of the gem in `%s` in the same commit\n", GemfileLock)
+	}
+
+	if _, ok := b.Files[Changelog]; ok {
+		fmt.Fprintf(g.outStream, "==> Add a section of `%s` to `%s` in the same commit\n", b.Next, Changelog)
+	}
+
+	fmt.Fprintf(g.outStream, "==> Create a pull request from `%s` branch to `%s` branch titled `%s` with the following body\n\n", b.Rendered.Branch, branch, b.Rendered.PullRequestTitle)
+	fmt.Fprintf(g.outStream, "%s\n\n", b.Rendered.PullRequestBody)
+	fmt.Fprintf(g.outStream, "==> Draft a release named `%s` with the following body\n\n", b.Rendered.ReleaseName)
+	fmt.Fprintln(g.outStream, b.Rendered.ReleaseBody)
+	fmt.Fprintln(g.outStream)
+
+	fmt.Fprintln(g.outStream, "==> Check the repository")
+
+	return g.preflight(branch, b)
+}
+
+// prepareVersionBump reads files of the branch, and calculates the next version and contents of files
+// to update, without making any changes on GitHub
+func (g *Gemer) prepareVersionBump(branch string, source VersionSource, version int, pre string) (*versionBump, error) {
+	rc, err := g.Forge.GetFile(branch, source.Path())
 
 	if err != nil {
 		return nil, err
@@ -47,122 +230,293 @@ func (g *Gemer) UpdateVersion(branch, path string, version int) (*UpdateVersionR
 		return nil, err
 	}
 
-	currentV := extractVersion(content)
+	currentV, err := source.Extract(content)
 
-	if len(currentV) == 0 {
-		return nil, errors.Errorf("failed to extract version from version.rb: version.rb content: %s", content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to extract version from %s", source.Path())
 	}
 
-	nextV, err := convertToNext(currentV, version)
+	currentTag := "v" + currentV
+	ccs, err := g.Forge.CompareCommits(currentTag, branch)
 
 	if err != nil {
 		return nil, err
 	}
 
-	newBranchName := "bumps_up_to_" + nextV
-	fmt.Fprintln(g.outStream, "==> Create a new branch")
-	err = g.GitHubClient.CreateNewBranch(branch, newBranchName)
+	if version == AutoVersion {
+		v, drivers, err := inferVersion(ccs, currentV, g.ZeroBreakingMinor)
+
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(g.outStream, "==> Increment %s version because of the commits below\n", versionName(v))
+		fmt.Fprintf(g.outStream, "%s\n\n", &ComparedCommits{Commits: drivers})
+		version = v
+	}
+
+	var prs []*github.PullRequest
+	orphans := ccs.Commits
+
+	// Looking up pull requests costs an API call per commit, so it is done only when their labels matter
+	if version == LabelVersion || g.ReleaseNoteSections != nil {
+		if prs, orphans, err = g.mergedPullRequests(ccs); err != nil {
+			return nil, err
+		}
+	}
+
+	if version == LabelVersion {
+		v, drivers, err := inferVersionFromLabels(prs)
+
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(g.outStream, "==> Increment %s version because of the pull requests below\n", versionName(v))
+		fmt.Fprintf(g.outStream, "%s\n\n", formatPullRequests(drivers))
+		version = v
+	}
+
+	nextV, err := convertToNext(currentV, version, pre)
 
 	if err != nil {
 		return nil, err
 	}
 
-	newContent := strings.Replace(content, currentV, nextV, 1)
-	message := "Bumps up to " + nextV
-
-	fmt.Fprintln(g.outStream, "==> Update version.rb")
-	err = g.GitHubClient.UpdateVersion(path, message, *rc.SHA, newBranchName, []byte(newContent))
-	result := &UpdateVersionResult{Branch: newBranchName}
+	newContent, err := source.Rewrite(content, nextV)
 
 	if err != nil {
-		return result, g.rollbackUpdateVersion(err, result)
+		return nil, errors.Wrapf(err, "failed to rewrite version of %s", source.Path())
 	}
 
-	fmt.Fprintln(g.outStream, "==> Create a new pull request")
-	pr, err := g.GitHubClient.CreatePullRequest(message, newBranchName, branch, message)
-	result = &UpdateVersionResult{Branch: newBranchName, PrNumber: *pr.Number}
+	files := map[string][]byte{source.Path(): []byte(newContent)}
+
+	lockfile, err := g.bumpLockfile(branch, currentV, nextV)
 
 	if err != nil {
-		return result, g.rollbackUpdateVersion(err, result)
+		return nil, err
 	}
 
-	currentTag := "v" + currentV
-	ccs, err := g.GitHubClient.CompareCommits(currentTag, newBranchName)
+	if lockfile != nil {
+		files[GemfileLock] = lockfile
+	}
+
+	date := time.Now().Format("2006-01-02")
+	changelog, err := g.updateChangelog(branch, currentV, nextV, date, ccs)
 
 	if err != nil {
-		return result, g.rollbackUpdateVersion(err, result)
+		return nil, err
 	}
 
-	nextTag := "v" + nextV
-	releaseBody := nextTag + " will include commits below!\n" + ccs.String()
-	fmt.Fprintln(g.outStream, "==> Create a release")
-	release, err := g.GitHubClient.CreateRelease(nextTag, branch, "Release " + nextTag, releaseBody)
-	result = &UpdateVersionResult{Branch: newBranchName, PrNumber: *pr.Number, ReleaseID: *release.ID, PrURL: *pr.HTMLURL, ReleaseURL: *release.HTMLURL}
+	if changelog != nil {
+		files[Changelog] = changelog
+	}
+
+	sections := g.ReleaseNoteSections
+
+	if sections == nil {
+		sections = DefaultReleaseNoteSections
+	}
+
+	templates := g.Templates
+
+	if templates == nil {
+		templates = DefaultTemplates
+	}
+
+	notes := buildReleaseNotes(sections, prs, orphans)
+	rendered, err := templates.Render(&TemplateData{
+		CurrentVersion: currentV,
+		NextVersion:    nextV,
+		CurrentTag:     currentTag,
+		NextTag:        "v" + nextV,
+		Branch:         branch,
+		Commits:        ccs.Commits,
+		PullRequests:   prs,
+		ReleaseNotes:   notes,
+		Date:           date,
+	})
 
 	if err != nil {
-		return result, g.rollbackUpdateVersion(err, result)
+		return nil, err
 	}
 
-	return result, nil
+	return &versionBump{
+		Current: currentV,
+		Next: nextV,
+		Commits: ccs,
+		PullRequests: prs,
+		ReleaseNotes: notes,
+		Files: files,
+		Rendered: rendered,
+	}, nil
 }
 
-func(g *Gemer) DryUpdateVersion(branch, path string, version int) error {
-	rc, err := g.GitHubClient.GetVersion(branch, path)
+// mergedPullRequests maps commits back to the merged pull requests which contain them. Each pull request
+// appears only once, and commits pushed directly to the branch are returned separately as orphans
+func (g *Gemer) mergedPullRequests(ccs *ComparedCommits) ([]*github.PullRequest, []*ComparedCommit, error) {
+	var prs []*github.PullRequest
+	var orphans []*ComparedCommit
+	seen := make(map[int]bool)
+
+	for _, c := range ccs.Commits {
+		found, err := g.Forge.ListMergedPullRequestsWithCommit(c.SHA)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(found) == 0 {
+			orphans = append(orphans, c)
+		}
+
+		for _, pr := range found {
+			if seen[pr.GetNumber()] {
+				continue
+			}
+
+			seen[pr.GetNumber()] = true
+			prs = append(prs, pr)
+		}
+	}
+
+	return prs, orphans, nil
+}
+
+// bumpLockfile returns Gemfile.lock of the branch whose entry of the gem itself is bumped up,
+// or nil if the gem does not have Gemfile.lock or it does not contain the current version of the gem
+func (g *Gemer) bumpLockfile(branch, current, next string) ([]byte, error) {
+	rc, err := g.Forge.GetFile(branch, GemfileLock)
+
+	if isNotFound(err) {
+		return nil, nil
+	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	content, err := decodeContent(rc)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	currentV := extractVersion(content)
+	newContent, changed := rewriteLockfile(content, current, next)
 
-	if len(currentV) == 0 {
-		return errors.Errorf("failed to extract version from version.rb: version.rb content: %s", content)
+	if !changed {
+		return nil, nil
 	}
 
-	nextV, err := convertToNext(currentV, version)
+	return []byte(newContent), nil
+}
+
+// updateChangelog returns CHANGELOG.md of the branch with a new section of the next version,
+// or nil if the gem does not have CHANGELOG.md
+func (g *Gemer) updateChangelog(branch, current, next, date string, ccs *ComparedCommits) ([]byte, error) {
+	rc, err := g.Forge.GetFile(branch, Changelog)
+
+	if isNotFound(err) {
+		return nil, nil
+	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	currentTag := "v" + currentV
-	ccs, err := g.GitHubClient.CompareCommits(currentTag, branch)
+	content, err := decodeContent(rc)
+
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Fprintf(g.outStream, "==> Create a branch named `bumps_up_to_%s`\n", nextV)
-	fmt.Fprintf(g.outStream, "==> Update `Version` constant of the branch from `%s` to `%s`\n", currentV, nextV)
-	fmt.Fprintf(g.outStream, "==> Create a pull request from `bumps_up_to_%s` branch to `%s` branch\n", nextV, branch)
-	fmt.Fprintf(g.outStream, "==> Draft a release which contains the following commits\n\n")
-	fmt.Fprintln(g.outStream, ccs)
+	newContent, err := updateChangelog(content, current, next, date, ccs)
 
-	return nil
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(newContent), nil
 }
 
-func (g *Gemer) rollbackUpdateVersion(err error, ur *UpdateVersionResult) error {
-	if len(ur.Branch) != 0 {
-		if e := g.GitHubClient.DeleteLatestRef(ur.Branch); e != nil {
-			return errors.Wrapf(e, "error occurred while rolling back from UpdateVersion results: original error: %s", err)
+// DetectVersionSource finds a file which declares the version of the gem on a given branch.
+// version.rb is preferred, then a .gemspec which assigns a string literal to spec.version, and then a VERSION file
+func (g *Gemer) DetectVersionSource(branch, constant string) (VersionSource, error) {
+	files, err := g.Forge.ListFiles(branch, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, name := g.Forge.Repository()
+	var gemspec string
+	var hasVersionFile bool
+
+	for _, f := range files {
+		if strings.HasSuffix(f, ".gemspec") && len(gemspec) == 0 {
+			gemspec = f
+			name = strings.TrimSuffix(f, ".gemspec")
+		}
+
+		if f == VersionFile {
+			hasVersionFile = true
 		}
 	}
 
-	if ur.PrNumber != 0 {
-		if e := g.GitHubClient.ClosePullRequest(ur.PrNumber); e != nil {
-			return errors.Wrapf(e, "error occurred while rolling back from UpdateVersion results: original error: %s", err)
+	candidates := versionRBCandidates(name)
+
+	for _, c := range candidates {
+		_, err := g.Forge.GetFile(branch, c)
+
+		if err == nil {
+			return &VersionRBSource{FilePath: c, Constant: constant}, nil
+		}
+
+		if !isNotFound(err) {
+			return nil, err
 		}
 	}
 
-	if ur.ReleaseID != 0 {
-		if e := g.GitHubClient.DeleteRelease(ur.ReleaseID); e != nil {
-			return errors.Wrapf(e, "error occurred while rolling back from UpdateVersion results: original error: %s", err)
+	if len(gemspec) != 0 {
+		candidates = append(candidates, gemspec)
+		source := &GemspecSource{FilePath: gemspec}
+		rc, err := g.Forge.GetFile(branch, gemspec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := decodeContent(rc)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := source.Extract(content); err == nil {
+			return source, nil
 		}
 	}
 
-	return err
+	if hasVersionFile {
+		return &PlainVersionSource{FilePath: VersionFile}, nil
+	}
+
+	candidates = append(candidates, VersionFile)
+
+	return nil, errors.Errorf("failed to detect a version file: tried: %s", strings.Join(candidates, ", "))
+}
+
+// joinPaths joins paths of files to update for messages
+func joinPaths(files map[string][]byte) string {
+	var paths []string
+
+	for p := range files {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	return strings.Join(paths, ", ")
 }
 
 func decodeContent(rc *github.RepositoryContent) (string, error) {
@@ -173,40 +527,86 @@ func decodeContent(rc *github.RepositoryContent) (string, error) {
 	decoded, err := base64.StdEncoding.DecodeString(*rc.Content)
 
 	if err != nil {
-		return "", errors.Wrap(err, "error occurred while decoding version.rb file")
+		return "", errors.Wrap(err, "error occurred while decoding file content")
 	}
 
 	return string(decoded), nil
 }
 
-func extractVersion(c string) string {
-	m := versionRegex.FindStringSubmatch(c)
+// convertToNext calculates the next version from the current one with Gem::Version rules.
+// Both of the current and the returned versions are in Gem::Version form (e.g. 1.3.0.rc1)
+func convertToNext(current string, version int, pre string) (string, error) {
+	v, err := ParseGemVersion(current)
 
-	if m == nil {
-		return ""
+	if err != nil {
+		return "", errors.Wrapf(err, "error occurred while parsing current version: current version: %s", current)
 	}
 
-	return m[1]
-}
+	var next *GemVersion
+
+	switch {
+	case version == PromoteVersion:
+		if !v.IsPrerelease() {
+			return "", errors.Errorf("failed to promote version: current version is not a pre-release: current version: %s", current)
+		}
+
+		next = v.Release()
+	case v.IsPrerelease():
+		if len(pre) == 0 {
+			return "", errors.Errorf("current version is a pre-release, bump it with a pre-release label or promote it: current version: %s", current)
+		}
+
+		// A major or minor bump starts a pre-release of the next version, e.g. 1.3.0.rc1 to 2.0.0.rc1,
+		// while the default patch bump moves the current pre-release forward, e.g. to 1.3.0.rc2
+		if version == MajorVersion || version == MinorVersion {
+			next, err = v.Increment(version)
 
-func convertToNext(current string, version int) (string, error) {
-	v, err := semver.New(current)
+			if err == nil {
+				next, err = next.PreRelease(pre)
+			}
+
+			break
+		}
+
+		next, err = v.PreRelease(pre)
+	default:
+		next, err = v.Increment(version)
+
+		if err == nil && len(pre) != 0 {
+			next, err = next.PreRelease(pre)
+		}
+	}
 
 	if err != nil {
-		return "", errors.Wrapf(err, "error occurred while parsing current version: current version: %s", current)
+		return "", err
 	}
 
-	if version == MajorVersion {
-		v.Major = v.Major + 1
+	if next.Compare(v) <= 0 {
+		return "", errors.Errorf("next version must be greater than current version: current version: %s, next version: %s", current, next)
 	}
 
-	if version == MinorVersion {
-		v.Minor = v.Minor + 1
+	return next.String(), nil
+}
+
+func versionName(version int) string {
+	switch version {
+	case MajorVersion:
+		return "major"
+	case MinorVersion:
+		return "minor"
+	case PatchVersion:
+		return "patch"
 	}
 
-	if version == PatchVersion {
-		v.Patch = v.Patch + 1
+	return "unknown"
+}
+
+func preReleaseIndex(pre string) int {
+	for i, p := range PreReleases {
+		if p == pre {
+			return i
+		}
 	}
 
-	return v.String(), nil
+	return -1
 }
@@ -4,14 +4,23 @@ import (
 	"testing"
 	"fmt"
 	"io/ioutil"
+	"bytes"
+	"strings"
+
+	"github.com/google/go-github/github"
 )
 
-func testGemmer(t *testing.T) *Gemer {
-	c := testGitHubClient(t)
-	return &Gemer{GitHubClient: c, outStream: ioutil.Discard}
+func testGemmer(t *testing.T) (*Gemer, func()) {
+	c, done := testGitHubClient(t)
+
+	// Sections make gemer look up the pull requests of the commits, which the fixtures have
+	return &Gemer{Forge: c, outStream: ioutil.Discard, ReleaseNoteSections: DefaultReleaseNoteSections}, done
 }
 
 func TestGemerUpdateVersionSuccess(t *testing.T) {
+	g, done := testGemmer(t)
+	defer done()
+
 	cases := []struct {
 		branch, path string
 	}{
@@ -19,14 +28,19 @@ func TestGemerUpdateVersionSuccess(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		g := testGemmer(t)
-
-		result, err := g.UpdateVersion(tc.branch, tc.path, PatchVersion)
+		result, err := g.UpdateVersion(tc.branch, &VersionRBSource{FilePath: tc.path, Constant: DefaultConstant}, PatchVersion, "")
 
 		if err != nil {
 			t.Fatalf("#%d error occurred while updating version: %s", i, err)
 		} else {
-			if e := g.rollbackUpdateVersion(nil, result); e != nil {
+			j := &Journal{
+				Templates: &RenderedTemplates{Branch: result.Branch},
+				Completed: []string{StepBranch, StepCommit, StepPullRequest, StepRelease},
+				PrNumber:  result.PrNumber,
+				ReleaseID: result.ReleaseID,
+			}
+
+			if e := g.Rollback(j); e != nil {
 				t.Errorf("#%d error occurred while rolling back: %s", i, e)
 			}
 		}
@@ -34,6 +48,9 @@ func TestGemerUpdateVersionSuccess(t *testing.T) {
 }
 
 func TestGemerUpdateVersionFail(t *testing.T) {
+	g, done := testGemmer(t)
+	defer done()
+
 	cases := []struct {
 		branch, path string
 	}{
@@ -42,9 +59,7 @@ func TestGemerUpdateVersionFail(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		g := testGemmer(t)
-
-		_, err := g.UpdateVersion(tc.branch, tc.path, PatchVersion)
+		_, err := g.UpdateVersion(tc.branch, &VersionRBSource{FilePath: tc.path, Constant: DefaultConstant}, PatchVersion, "")
 
 		if err == nil {
 			t.Fatalf("#%d error is not supposed to be nil", i)
@@ -53,6 +68,9 @@ func TestGemerUpdateVersionFail(t *testing.T) {
 }
 
 func TestGemerDryUpdateVersionSuccess(t *testing.T) {
+	g, done := testGemmer(t)
+	defer done()
+
 	cases := []struct {
 		version int
 	}{
@@ -62,9 +80,7 @@ func TestGemerDryUpdateVersionSuccess(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		g := testGemmer(t)
-
-		err := g.DryUpdateVersion("develop", fmt.Sprintf("lib/%s/version.rb", TestRepo), tc.version)
+		err := g.DryUpdateVersion("develop", &VersionRBSource{FilePath: fmt.Sprintf("lib/%s/version.rb", TestRepo), Constant: DefaultConstant}, tc.version, "")
 
 		if err != nil {
 			t.Fatalf("#%d error occurred while dry updating version: %s", i, err)
@@ -73,6 +89,9 @@ func TestGemerDryUpdateVersionSuccess(t *testing.T) {
 }
 
 func TestGemerDryUpdateVersionFail(t *testing.T) {
+	g, done := testGemmer(t)
+	defer done()
+
 	cases := []struct {
 		branch, path string
 	}{
@@ -81,12 +100,391 @@ func TestGemerDryUpdateVersionFail(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		g := testGemmer(t)
-
-		err := g.DryUpdateVersion(tc.branch, tc.path, PatchVersion)
+		err := g.DryUpdateVersion(tc.branch, &VersionRBSource{FilePath: tc.path, Constant: DefaultConstant}, PatchVersion, "")
 
 		if err == nil {
 			t.Fatalf("#%d error is not supposed to be nil", i)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestGemerDetectVersionSourceSuccess(t *testing.T) {
+	g, done := testGemmer(t)
+	defer done()
+
+	source, err := g.DetectVersionSource("develop", DefaultConstant)
+
+	if err != nil {
+		t.Fatalf("DetectVersionSource failed: %s", err)
+	}
+
+	if want := fmt.Sprintf("lib/%s/version.rb", TestRepo); source.Path() != want {
+		t.Fatalf("invalid version file path: want: %s, got: %s", want, source.Path())
+	}
+}
+
+func TestGemerDetectVersionSourceFail(t *testing.T) {
+	g, done := testGemmer(t)
+	defer done()
+
+	if _, err := g.DetectVersionSource("unknown", DefaultConstant); err == nil {
+		t.Fatalf("error is not supposed to be nil")
+	}
+}
+
+func testFakeForge() *fakeForge {
+	f := newFakeForge(map[string]string{"r.gemspec": "", "lib/r/version.rb": "module R\n  VERSION = '0.1.0'\nend\n"})
+	f.tag("v0.1.0", "master")
+	f.mergePullRequest("Add foo", "master", "feature")
+	f.mergePullRequest("Fix bar", "master", "bug")
+
+	return f
+}
+
+func TestGemerUpdateVersionOffline(t *testing.T) {
+	f := testFakeForge()
+	g := &Gemer{Forge: f, outStream: ioutil.Discard}
+
+	source, err := g.DetectVersionSource("master", DefaultConstant)
+
+	if err != nil {
+		t.Fatalf("DetectVersionSource failed: %s", err)
+	}
+
+	result, err := g.UpdateVersion("master", source, MinorVersion, "")
+
+	if err != nil {
+		t.Fatalf("UpdateVersion failed: %s", err)
+	}
+
+	if result.Branch != "bumps_up_to_0.2.0" || result.PrNumber != 3 || result.ReleaseID == 0 {
+		t.Fatalf("invalid result: %+v", result)
+	}
+
+	rc, err := f.GetFile(result.Branch, "lib/r/version.rb")
+
+	if err != nil {
+		t.Fatalf("the version file is supposed to be on the branch: %s", err)
+	}
+
+	if got, _ := decodeContent(rc); got != "module R\n  VERSION = '0.2.0'\nend\n" {
+		t.Fatalf("invalid version file: %q", got)
+	}
+
+	prs, _ := f.ListOpenPullRequests("master")
+
+	if len(prs) != 1 || prs[0].GetTitle() != "Bumps up to 0.2.0" {
+		t.Fatalf("invalid pull requests: %v", prs)
+	}
+
+	release, _ := f.FindRelease("v0.2.0")
+
+	if release == nil || !release.GetDraft() || !strings.Contains(release.GetBody(), "Add foo") || !strings.Contains(release.GetBody(), "Fix bar") {
+		t.Fatalf("invalid release: %v", release)
+	}
+}
+
+// mergingFakeForge is fakeForge which releases only after the pull request is merged, as GitLab does.
+// merged is the commit the pull request is merged with, which is empty until it is merged
+type mergingFakeForge struct {
+	*fakeForge
+	merged string
+}
+
+func (f *mergingFakeForge) MergeCommit(number int) (string, error) {
+	return f.merged, f.call("MergeCommit")
+}
+
+func TestGemerUpdateVersionOfflineReleaseOnMerge(t *testing.T) {
+	_, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	f := &mergingFakeForge{fakeForge: testFakeForge()}
+	g := &Gemer{Forge: f, outStream: ioutil.Discard, JournalDir: JournalDir()}
+
+	result, err := g.UpdateVersion("master", &VersionRBSource{FilePath: "lib/r/version.rb", Constant: DefaultConstant}, MinorVersion, "")
+
+	if err != nil {
+		t.Fatalf("UpdateVersion failed: %s", err)
+	}
+
+	if !result.ReleasePending || result.PrNumber != 3 || result.ReleaseID != 0 {
+		t.Fatalf("release is supposed to be pending: %+v", result)
+	}
+
+	if release, _ := f.FindRelease("v0.2.0"); release != nil {
+		t.Fatalf("release is not supposed to be created before the pull request is merged: %v", release)
+	}
+
+	path, err := FindJournal(g.JournalDir)
+
+	if err != nil {
+		t.Fatalf("journal is supposed to be kept to resume: %s", err)
+	}
+
+	j, err := LoadJournal(path)
+
+	if err != nil {
+		t.Fatalf("LoadJournal failed: %s", err)
+	}
+
+	// Resuming before the merge keeps waiting
+	if result, err := g.Resume(j); err != nil || !result.ReleasePending {
+		t.Fatalf("release is supposed to be still pending: %+v, %v", result, err)
+	}
+
+	f.merged = f.commit("master", "Merge branch 'bumps_up_to_0.2.0'", nil)
+
+	if result, err = g.Resume(j); err != nil {
+		t.Fatalf("Resume failed: %s", err)
+	}
+
+	release, _ := f.FindRelease("v0.2.0")
+
+	if result.ReleasePending || result.Tag != "v0.2.0" || release == nil || release.GetTargetCommitish() != f.merged {
+		t.Fatalf("release is supposed to target the merge commit: %+v, %v", result, release)
+	}
+
+	if _, err := FindJournal(g.JournalDir); err == nil {
+		t.Fatalf("journal is supposed to be removed")
+	}
+}
+
+func TestGemerUpdateVersionOfflineFail(t *testing.T) {
+	cases := []struct {
+		fail            []string
+		wantCalls       []string
+		wantBranch      bool
+		wantOpenPR      bool
+		wantRollbackErr bool
+	}{
+		{
+			fail:      []string{"CreateNewBranch"},
+			wantCalls: []string{"CreateNewBranch"},
+		},
+		{
+			fail:            []string{"UpdateFiles"},
+			wantCalls:       []string{"CreateNewBranch", "UpdateFiles", "DeleteLatestRef"},
+			wantRollbackErr: true,
+		},
+		{
+			fail:            []string{"CreatePullRequest"},
+			wantCalls:       []string{"CreateNewBranch", "UpdateFiles", "CreatePullRequest", "DeleteLatestRef"},
+			wantRollbackErr: true,
+		},
+		{
+			fail:            []string{"CreateRelease"},
+			wantCalls:       []string{"CreateNewBranch", "UpdateFiles", "CreatePullRequest", "CreateRelease", "ClosePullRequest", "DeleteLatestRef"},
+			wantRollbackErr: true,
+		},
+		{
+			fail:            []string{"CreateRelease", "ClosePullRequest"},
+			wantCalls:       []string{"CreateNewBranch", "UpdateFiles", "CreatePullRequest", "CreateRelease", "ClosePullRequest", "DeleteLatestRef"},
+			wantRollbackErr: true,
+		},
+		{
+			fail:            []string{"CreateRelease", "DeleteLatestRef"},
+			wantCalls:       []string{"CreateNewBranch", "UpdateFiles", "CreatePullRequest", "CreateRelease", "ClosePullRequest", "DeleteLatestRef"},
+			wantBranch:      true,
+			wantRollbackErr: true,
+		},
+		{
+			fail:            []string{"CreateRelease", "ClosePullRequest", "DeleteLatestRef"},
+			wantCalls:       []string{"CreateNewBranch", "UpdateFiles", "CreatePullRequest", "CreateRelease", "ClosePullRequest", "DeleteLatestRef"},
+			wantBranch:      true,
+			wantOpenPR:      true,
+			wantRollbackErr: true,
+		},
+	}
+
+	mutating := map[string]bool{
+		"CreateNewBranch": true, "UpdateFiles": true, "CreatePullRequest": true, "CreateRelease": true,
+		"DeleteLatestRef": true, "ClosePullRequest": true, "DeleteRelease": true,
+	}
+
+	for i, tc := range cases {
+		f := testFakeForge()
+
+		for _, m := range tc.fail {
+			f.fail[m] = true
+		}
+
+		g := &Gemer{Forge: f, outStream: ioutil.Discard}
+		_, err := g.UpdateVersion("master", &VersionRBSource{FilePath: "lib/r/version.rb", Constant: DefaultConstant}, PatchVersion, "")
+
+		if err == nil {
+			t.Fatalf("#%d UpdateVersion is supposed to fail", i)
+		}
+
+		var calls []string
+
+		for _, c := range f.calls {
+			if mutating[c] {
+				calls = append(calls, c)
+			}
+		}
+
+		if fmt.Sprint(calls) != fmt.Sprint(tc.wantCalls) {
+			t.Fatalf("#%d invalid calls: want: %q, got: %q", i, tc.wantCalls, calls)
+		}
+
+		if _, ok := err.(*RollbackError); ok != tc.wantRollbackErr {
+			t.Fatalf("#%d invalid error: %s", i, err)
+		}
+
+		if _, ok := f.branches["bumps_up_to_0.1.1"]; ok != tc.wantBranch {
+			t.Fatalf("#%d invalid branch: want: %t, got: %t", i, tc.wantBranch, ok)
+		}
+
+		if prs, _ := f.ListOpenPullRequests("master"); (len(prs) != 0) != tc.wantOpenPR {
+			t.Fatalf("#%d invalid open pull requests: %v", i, prs)
+		}
+
+		if len(f.releases) != 0 {
+			t.Fatalf("#%d releases are supposed to be rolled back: %v", i, f.releases)
+		}
+	}
+}
+
+func TestGemerUpdateVersionOfflinePreflight(t *testing.T) {
+	f := testFakeForge()
+	f.branches["bumps_up_to_0.1.1"] = f.branches["master"]
+	f.missing = []string{"push access to o/r"}
+
+	g := &Gemer{Forge: f, outStream: ioutil.Discard}
+	_, err := g.UpdateVersion("master", &VersionRBSource{FilePath: "lib/r/version.rb", Constant: DefaultConstant}, PatchVersion, "")
+
+	pe, ok := err.(*PreflightError)
+
+	if !ok || len(pe.Problems) != 2 {
+		t.Fatalf("UpdateVersion is supposed to fail with 2 problems: got: %v", err)
+	}
+
+	for _, c := range f.calls {
+		if c == "CreateNewBranch" {
+			t.Fatalf("nothing is supposed to be changed: %q", f.calls)
+		}
+	}
+}
+
+func TestGemerDryUpdateVersionOffline(t *testing.T) {
+	f := testFakeForge()
+	out := new(bytes.Buffer)
+	g := &Gemer{Forge: f, outStream: out}
+
+	if err := g.DryUpdateVersion("master", &VersionRBSource{FilePath: "lib/r/version.rb", Constant: DefaultConstant}, MajorVersion, ""); err != nil {
+		t.Fatalf("DryUpdateVersion failed: %s", err)
+	}
+
+	if !strings.Contains(out.String(), "bumps_up_to_1.0.0") {
+		t.Fatalf("invalid output: %s", out)
+	}
+
+	if len(f.branches) != 3 || len(f.pullRequests) != 2 || len(f.releases) != 0 {
+		t.Fatalf("nothing is supposed to be changed: branches: %v", f.branches)
+	}
+}
+
+func TestGemerDryUpdateVersionOfflinePullRequests(t *testing.T) {
+	feature := &ReleaseNoteSection{Title: "Features", Labels: []string{"feature"}}
+
+	cases := []struct {
+		version int
+		sections []*ReleaseNoteSection
+		wantLookups int
+		want string
+	}{
+		{version: MinorVersion, wantLookups: 0, want: "## Other\n- Add foo"},
+		{version: MinorVersion, sections: []*ReleaseNoteSection{feature}, wantLookups: 2, want: "## Features\n- Add foo ([#1]"},
+		{version: LabelVersion, wantLookups: 2, want: "## Features\n- Add foo ([#1]"},
+	}
+
+	for i, tc := range cases {
+		f := testFakeForge()
+		f.pullRequests[0].Labels = append(f.pullRequests[0].Labels, &github.Label{Name: github.String("semver:minor")})
+		f.pullRequests[1].Labels = append(f.pullRequests[1].Labels, &github.Label{Name: github.String("semver:patch")})
+
+		out := new(bytes.Buffer)
+		g := &Gemer{Forge: f, outStream: out, ReleaseNoteSections: tc.sections}
+
+		if err := g.DryUpdateVersion("master", &VersionRBSource{FilePath: "lib/r/version.rb", Constant: DefaultConstant}, tc.version, ""); err != nil {
+			t.Fatalf("#%d DryUpdateVersion failed: %s", i, err)
+		}
+
+		lookups := 0
+
+		for _, c := range f.calls {
+			if c == "ListMergedPullRequestsWithCommit" {
+				lookups++
+			}
+		}
+
+		if lookups != tc.wantLookups {
+			t.Fatalf("#%d pull requests are supposed to be looked up %d times, got %d", i, tc.wantLookups, lookups)
+		}
+
+		if !strings.Contains(out.String(), tc.want) {
+			t.Fatalf("#%d invalid release notes: want: %q, got: %s", i, tc.want, out)
+		}
+	}
+}
+
+func TestConvertToNextSuccess(t *testing.T) {
+	cases := []struct {
+		current string
+		version int
+		pre, want string
+	}{
+		{current: "1.2.3", version: PatchVersion, want: "1.2.4"},
+		{current: "1.2.3", version: MinorVersion, want: "1.3.0"},
+		{current: "1.2.3", version: MajorVersion, want: "2.0.0"},
+		{current: "1.2.3", version: MinorVersion, pre: "rc", want: "1.3.0.rc1"},
+		{current: "1.2.3", version: PatchVersion, pre: "alpha", want: "1.2.4.alpha1"},
+		{current: "1.3.0.rc1", version: PatchVersion, pre: "rc", want: "1.3.0.rc2"},
+		{current: "1.3.0.beta2", version: PatchVersion, pre: "rc", want: "1.3.0.rc1"},
+		{current: "1.3.0.rc2", version: PromoteVersion, want: "1.3.0"},
+		{current: "1.2", version: PatchVersion, want: "1.2.1"},
+		{current: "1.2", version: MajorVersion, want: "2.0"},
+		{current: "1.2.3.4", version: PatchVersion, want: "1.2.4.0"},
+		{current: "1.2.3.4", version: MinorVersion, want: "1.3.0.0"},
+		{current: "2.0.0.beta1", version: PatchVersion, pre: "beta", want: "2.0.0.beta2"},
+		{current: "2.0.0.beta.1", version: PatchVersion, pre: "beta", want: "2.0.0.beta.2"},
+		{current: "2.0.0.pre", version: PatchVersion, pre: "rc", want: "2.0.0.rc1"},
+		{current: "2.0.0.beta1", version: PromoteVersion, want: "2.0.0"},
+		{current: "1.0-rc1", version: PatchVersion, pre: "rc", want: "1.0-rc2"},
+		{current: "1.0-rc1", version: PromoteVersion, want: "1.0"},
+		{current: "1.3.0.rc1", version: MajorVersion, pre: "rc", want: "2.0.0.rc1"},
+		{current: "1.3.0.rc1", version: MinorVersion, pre: "alpha", want: "1.4.0.alpha1"},
+	}
+
+	for i, tc := range cases {
+		got, err := convertToNext(tc.current, tc.version, tc.pre)
+
+		if err != nil {
+			t.Fatalf("#%d convertToNext failed: %s", i, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid version: want: %s, got: %s", i, tc.want, got)
+		}
+	}
+}
+
+func TestConvertToNextFail(t *testing.T) {
+	cases := []struct {
+		current string
+		version int
+		pre string
+	}{
+		{current: "invalid", version: PatchVersion},
+		{current: "1.2.3", version: PromoteVersion},
+		{current: "1.3.0.rc1", version: PatchVersion},
+		{current: "1.3.0.rc1", version: PatchVersion, pre: "beta"},
+	}
+
+	for i, tc := range cases {
+		if _, err := convertToNext(tc.current, tc.version, tc.pre); err == nil {
+			t.Fatalf("#%d convertToNext is supposed to fail", i)
+		}
+	}
+}
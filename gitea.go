@@ -0,0 +1,521 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// GiteaAPIURL is the url of Gitea.com API
+const GiteaAPIURL = "https://gitea.com/api/v1/"
+
+// EnvGiteaToken is the environment variable of a Gitea access token
+const EnvGiteaToken = "GITEA_TOKEN"
+
+// giteaPageSize is the number of items Gitea returns in a page at most by default
+const giteaPageSize = 50
+
+// GiteaClient is a client to interact with Gitea API v1, which Forgejo serves as well. Gitea mostly mirrors GitHub API,
+// so responses are decoded into the types of go-github as they are
+type GiteaClient struct {
+	Owner, Repo string
+
+	BaseURL    *url.URL
+	Token      string
+	HTTPClient *http.Client
+}
+
+type giteaBranch struct {
+	Name      string `json:"name"`
+	Protected bool   `json:"protected"`
+	Commit    struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+// NewGiteaClient creates and initializes a new GiteaClient. apiURL is the url of Gitea API, and Gitea.com is used if it is empty
+func NewGiteaClient(owner, repo, token, apiURL string) (*GiteaClient, error) {
+	if len(owner) == 0 {
+		return nil, errors.New("missing Gitea owner name")
+	}
+
+	if len(repo) == 0 {
+		return nil, errors.New("missing Gitea repository name")
+	}
+
+	if len(token) == 0 {
+		return nil, errors.New("missing Gitea access token")
+	}
+
+	base, err := restAPIURL(apiURL, GiteaAPIURL, "api/v1/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &GiteaClient{Owner: owner, Repo: repo, BaseURL: base, Token: token, HTTPClient: http.DefaultClient}, nil
+}
+
+// Repository returns the owner and the name of the repository
+func (c *GiteaClient) Repository() (string, string) {
+	return c.Owner, c.Repo
+}
+
+// MissingPermissions checks that the token can push to the repository, which is needed
+// to push branches, open pull requests and create releases
+func (c *GiteaClient) MissingPermissions() ([]string, error) {
+	var repo github.Repository
+	err := c.do("GET", "", nil, nil, &repo)
+
+	if s := restStatus(err); s == http.StatusNotFound || s == http.StatusUnauthorized {
+		return []string{fmt.Sprintf("access to %s/%s: the repository is not found, or the token cannot read it", c.Owner, c.Repo)}, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get repository %s/%s", c.Owner, c.Repo)
+	}
+
+	if repo.Permissions == nil || !(*repo.Permissions)["push"] {
+		return []string{fmt.Sprintf("push access to %s/%s: to push branches, open pull requests and create releases", c.Owner, c.Repo)}, nil
+	}
+
+	return nil, nil
+}
+
+// GetBranch gets a branch, and returns nil if it does not exist
+func (c *GiteaClient) GetBranch(name string) (*github.Branch, error) {
+	var b giteaBranch
+	err := c.do("GET", "branches/"+giteaPath(name), nil, nil, &b)
+
+	if restStatus(err) == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get branch: %s", name)
+	}
+
+	return &github.Branch{
+		Name:      github.String(b.Name),
+		Protected: github.Bool(b.Protected),
+		Commit:    &github.RepositoryCommit{SHA: github.String(b.Commit.ID)},
+	}, nil
+}
+
+// CreateNewBranch creates a new branch from the head of the origin
+func (c *GiteaClient) CreateNewBranch(origin, new string) error {
+	if len(origin) == 0 {
+		return errors.New("missing Gitea origin branch name")
+	}
+
+	if len(new) == 0 {
+		return errors.New("missing Gitea branch name")
+	}
+
+	in := map[string]string{"new_branch_name": new, "old_branch_name": origin}
+
+	if err := c.do("POST", "branches", nil, in, nil); err != nil {
+		return errors.Wrapf(err, "failed to create a new branch %s from %s", new, origin)
+	}
+
+	return nil
+}
+
+// DeleteLatestRef deletes the branch, intended to be used for rollbacks
+func (c *GiteaClient) DeleteLatestRef(branch string) error {
+	if len(branch) == 0 {
+		return errors.New("missing Gitea branch name")
+	}
+
+	if err := c.do("DELETE", "branches/"+giteaPath(branch), nil, nil, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete branch %s", branch)
+	}
+
+	return nil
+}
+
+// GetFile gets the latest file of the branch
+func (c *GiteaClient) GetFile(branch, path string) (*github.RepositoryContent, error) {
+	if len(branch) == 0 {
+		return nil, errors.New("missing Gitea branch name")
+	}
+
+	if len(path) == 0 {
+		return nil, errors.New("missing Gitea file path")
+	}
+
+	var rc github.RepositoryContent
+	err := c.do("GET", "contents/"+giteaPath(path), url.Values{"ref": {branch}}, nil, &rc)
+
+	if restStatus(err) == http.StatusNotFound {
+		return nil, &NotFoundError{What: fmt.Sprintf("%s of branch %s", path, branch)}
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get file: path: %s", path)
+	}
+
+	return &rc, nil
+}
+
+// ListFiles lists names of files and directories in a directory of the branch, dir is empty for the root
+func (c *GiteaClient) ListFiles(branch, dir string) ([]string, error) {
+	if len(branch) == 0 {
+		return nil, errors.New("missing Gitea branch name")
+	}
+
+	path := "contents"
+
+	if len(dir) != 0 {
+		path += "/" + giteaPath(dir)
+	}
+
+	var entries []*github.RepositoryContent
+
+	if err := c.do("GET", path, url.Values{"ref": {branch}}, nil, &entries); err != nil {
+		return nil, errors.Wrapf(err, "failed to list files: directory: %s", dir)
+	}
+
+	var names []string
+
+	for _, e := range entries {
+		names = append(names, e.GetName())
+	}
+
+	return names, nil
+}
+
+// UpdateFiles updates several files of the branch in a single commit. Files which do not exist are created.
+// Each update carries the SHA of the file, so Gitea rejects it with ErrRefMoved if the file has changed in the meantime
+func (c *GiteaClient) UpdateFiles(branch, message string, files map[string][]byte) (string, error) {
+	if len(branch) == 0 {
+		return "", errors.New("missing Gitea branch name")
+	}
+
+	if len(message) == 0 {
+		return "", errors.New("missing Gitea commit message")
+	}
+
+	if len(files) == 0 {
+		return "", errors.New("missing Gitea files to update")
+	}
+
+	var paths []string
+
+	for p := range files {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	var changes []map[string]string
+
+	for _, p := range paths {
+		change := map[string]string{"operation": "create", "path": p, "content": base64.StdEncoding.EncodeToString(files[p])}
+		rc, err := c.GetFile(branch, p)
+
+		if err != nil && !isNotFound(err) {
+			return "", err
+		}
+
+		if err == nil {
+			change["operation"], change["sha"] = "update", rc.GetSHA()
+		}
+
+		changes = append(changes, change)
+	}
+
+	in := map[string]interface{}{"branch": branch, "message": message, "files": changes}
+
+	var res struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+
+	err := c.do("POST", "contents", nil, in, &res)
+
+	if e, ok := errors.Cause(err).(*RESTError); ok && e.StatusCode == http.StatusUnprocessableEntity && strings.Contains(e.Message, "sha does not match") {
+		return "", errors.Wrapf(ErrRefMoved, "branch name: %s: %s", branch, e.Message)
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create a new commit: branch name: %s", branch)
+	}
+
+	return res.Commit.SHA, nil
+}
+
+// CreatePullRequest opens a pull request from head to base
+func (c *GiteaClient) CreatePullRequest(title, head, base, body string) (*github.PullRequest, error) {
+	if len(title) == 0 {
+		return nil, errors.New("missing Gitea pull request title")
+	}
+
+	if len(head) == 0 {
+		return nil, errors.New("missing Gitea pull request head branch")
+	}
+
+	if len(base) == 0 {
+		return nil, errors.New("missing Gitea pull request base branch")
+	}
+
+	if len(body) == 0 {
+		return nil, errors.New("missing Gitea pull request body")
+	}
+
+	in := map[string]string{"title": title, "head": head, "base": base, "body": body}
+
+	var pr github.PullRequest
+
+	if err := c.do("POST", "pulls", nil, in, &pr); err != nil {
+		return nil, errors.Wrap(err, "failed to create a new pull request")
+	}
+
+	return &pr, nil
+}
+
+// ClosePullRequest closes a pull request with a given number
+func (c *GiteaClient) ClosePullRequest(number int) error {
+	in := map[string]string{"state": "closed"}
+
+	if err := c.do("PATCH", "pulls/"+strconv.Itoa(number), nil, in, nil); err != nil {
+		return errors.Wrapf(err, "failed to close pull request #%d", number)
+	}
+
+	return nil
+}
+
+// ListOpenPullRequests lists open pull requests to the base branch. Gitea cannot filter pull requests by the base,
+// so it goes through every page of open pull requests
+func (c *GiteaClient) ListOpenPullRequests(base string) ([]*github.PullRequest, error) {
+	prs, err := c.listPullRequests("open")
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list open pull requests: base: %s", base)
+	}
+
+	var found []*github.PullRequest
+
+	for _, pr := range prs {
+		if pr.GetBase().GetRef() == base {
+			found = append(found, pr)
+		}
+	}
+
+	return found, nil
+}
+
+// ListMergedPullRequestsWithCommit lists the merged pull request which is merged with a given commit. Gitea only finds
+// a pull request by its merge or squash commit, so the other commits of a pull request are not found with it
+func (c *GiteaClient) ListMergedPullRequestsWithCommit(sha string) ([]*github.PullRequest, error) {
+	if len(sha) == 0 {
+		return nil, errors.New("missing Gitea commit sha")
+	}
+
+	var pr github.PullRequest
+	err := c.do("GET", "commits/"+url.PathEscape(sha)+"/pull", nil, nil, &pr)
+
+	if restStatus(err) == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the pull request merged with commit %s", sha)
+	}
+
+	return []*github.PullRequest{&pr}, nil
+}
+
+// listPullRequests lists pull requests in the state, going through every page
+func (c *GiteaClient) listPullRequests(state string) ([]*github.PullRequest, error) {
+	var prs []*github.PullRequest
+
+	for page := 1; ; page++ {
+		q := url.Values{"state": {state}, "page": {strconv.Itoa(page)}, "limit": {strconv.Itoa(giteaPageSize)}}
+
+		var found []*github.PullRequest
+
+		if err := c.do("GET", "pulls", q, nil, &found); err != nil {
+			return nil, err
+		}
+
+		prs = append(prs, found...)
+
+		if len(found) < giteaPageSize {
+			break
+		}
+	}
+
+	return prs, nil
+}
+
+// CreateRelease drafts a new release
+func (c *GiteaClient) CreateRelease(tagName, targetCommitish, name, body string) (*github.RepositoryRelease, error) {
+	if len(tagName) == 0 {
+		return nil, errors.New("missing Gitea release tag name")
+	}
+
+	if len(targetCommitish) == 0 {
+		return nil, errors.New("missing Gitea target commitish")
+	}
+
+	if len(name) == 0 {
+		return nil, errors.New("missing Gitea release name")
+	}
+
+	if len(body) == 0 {
+		return nil, errors.New("missing Gitea release body")
+	}
+
+	in := map[string]interface{}{"tag_name": tagName, "target_commitish": targetCommitish, "name": name, "body": body, "draft": true}
+
+	var r github.RepositoryRelease
+
+	if err := c.do("POST", "releases", nil, in, &r); err != nil {
+		return nil, errors.Wrap(err, "failed to create a new release")
+	}
+
+	return &r, nil
+}
+
+// DeleteRelease deletes a release by its ID, the tag is not needed on Gitea
+func (c *GiteaClient) DeleteRelease(id int64, tag string) error {
+	if err := c.do("DELETE", "releases/"+strconv.FormatInt(id, 10), nil, nil, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete release %d", id)
+	}
+
+	return nil
+}
+
+// FindRelease finds a release of the tag including drafts in the latest releases, and returns nil if there is none
+func (c *GiteaClient) FindRelease(tag string) (*github.RepositoryRelease, error) {
+	var releases []*github.RepositoryRelease
+
+	if err := c.do("GET", "releases", url.Values{"limit": {strconv.Itoa(giteaPageSize)}}, nil, &releases); err != nil {
+		return nil, errors.Wrap(err, "failed to list releases")
+	}
+
+	for _, r := range releases {
+		if r.GetTagName() == tag {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// LatestReleaseTag returns the tag of the latest published release, or an empty string if there is none
+func (c *GiteaClient) LatestReleaseTag() (string, error) {
+	var r github.RepositoryRelease
+	err := c.do("GET", "releases/latest", nil, nil, &r)
+
+	if restStatus(err) == http.StatusNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the latest release")
+	}
+
+	return r.GetTagName(), nil
+}
+
+// TagExists returns true if the tag exists
+func (c *GiteaClient) TagExists(tag string) (bool, error) {
+	err := c.do("GET", "tags/"+giteaPath(tag), nil, nil, nil)
+
+	if restStatus(err) == http.StatusNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get tag: %s", tag)
+	}
+
+	return true, nil
+}
+
+// CompareCommits lists commits from base, which is a tag or a branch, to head
+func (c *GiteaClient) CompareCommits(base, head string) (*ComparedCommits, error) {
+	if len(base) == 0 {
+		return nil, errors.New("missing Gitea base commit")
+	}
+
+	if len(head) == 0 {
+		return nil, errors.New("missing Gitea head commit")
+	}
+
+	var compare struct {
+		Commits []*github.RepositoryCommit `json:"commits"`
+	}
+
+	if err := c.do("GET", "compare/"+giteaPath(base)+"..."+giteaPath(head), nil, nil, &compare); err != nil {
+		return nil, errors.Wrapf(err, "failed to compare %s with %s", head, base)
+	}
+
+	var ccs []*ComparedCommit
+
+	for _, cm := range compare.Commits {
+		author := cm.GetAuthor().GetLogin()
+
+		// Commits whose author email is not linked to any Gitea account do not have the author
+		if len(author) == 0 {
+			author = cm.GetCommit().GetAuthor().GetName()
+		}
+
+		ccs = append(ccs, &ComparedCommit{SHA: cm.GetSHA(), Author: author, Message: cm.GetCommit().GetMessage(), HTMLURL: cm.GetHTMLURL(), Merge: len(cm.Parents) > 1})
+	}
+
+	return &ComparedCommits{Commits: ccs}, nil
+}
+
+// do sends a request to an endpoint of the repository, e.g. `branches`, see restClient
+func (c *GiteaClient) do(method, path string, query url.Values, in, out interface{}) error {
+	rc := &restClient{
+		HTTPClient:   c.HTTPClient,
+		Prefix:       c.BaseURL.String() + "repos/" + url.PathEscape(c.Owner) + "/" + url.PathEscape(c.Repo),
+		AuthHeader:   "Authorization",
+		AuthValue:    "token " + c.Token,
+		ErrorMessage: giteaErrorMessage,
+	}
+
+	return rc.do(method, path, query, in, out)
+}
+
+// giteaErrorMessage reads an error response, whose message comes with the errors of fields on validation failures
+func giteaErrorMessage(content []byte) string {
+	var body struct {
+		Message string   `json:"message"`
+		Errors  []string `json:"errors"`
+	}
+
+	if json.Unmarshal(content, &body) != nil || len(body.Message) == 0 {
+		return ""
+	}
+
+	if len(body.Errors) != 0 {
+		return body.Message + ": " + strings.Join(body.Errors, ", ")
+	}
+
+	return body.Message
+}
+
+// giteaPath escapes each segment of a path, e.g. a file path or a branch name, keeping the slashes
+func giteaPath(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+
+	return strings.Join(segments, "/")
+}
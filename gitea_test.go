@@ -0,0 +1,553 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// The samples below follow the example responses of Gitea API v1 docs, trimmed and renamed to repository o/r
+
+const giteaBranchSample = `{
+  "name": "master",
+  "commit": {
+    "id": "7b5c3cc8be40ee161ae89a06bba6229da1032a0c",
+    "message": "Fix bar\n",
+    "url": "https://gitea.example.com/o/r/commit/7b5c3cc8be40ee161ae89a06bba6229da1032a0c"
+  },
+  "protected": true,
+  "required_approvals": 0,
+  "enable_status_check": false,
+  "status_check_contexts": [],
+  "user_can_push": true,
+  "user_can_merge": true,
+  "effective_branch_protection_name": "master"
+}`
+
+const giteaContentsSample = `{
+  "name": "version.rb",
+  "path": "lib/r/version.rb",
+  "sha": "ab267fa25ca94b339de06d44806b69c9667aa354",
+  "last_commit_sha": "7b5c3cc8be40ee161ae89a06bba6229da1032a0c",
+  "type": "file",
+  "size": 34,
+  "encoding": "base64",
+  "content": "bW9kdWxlIFIKICBWRVJTSU9OID0gJzAuMS4wJwplbmQK",
+  "target": null,
+  "url": "https://gitea.example.com/api/v1/repos/o/r/contents/lib/r/version.rb?ref=master",
+  "html_url": "https://gitea.example.com/o/r/src/branch/master/lib/r/version.rb",
+  "download_url": "https://gitea.example.com/o/r/raw/branch/master/lib/r/version.rb",
+  "submodule_git_url": null
+}`
+
+const giteaDirectorySample = `[
+  {"name": "lib", "path": "lib", "sha": "a1e8f8d745cc87e3a9248358d9352bb7f9a0aeba", "type": "dir", "size": 0, "encoding": null, "content": null},
+  {"name": "r.gemspec", "path": "r.gemspec", "sha": "4535904260b1082e14f867f7a24fd8c21495bde3", "type": "file", "size": 0, "encoding": null, "content": null}
+]`
+
+const giteaFilesSample = `{
+  "files": [],
+  "commit": {
+    "url": "https://gitea.example.com/api/v1/repos/o/r/git/commits/ed899a2f4b50b4370feeea94676502b42383c746",
+    "sha": "ed899a2f4b50b4370feeea94676502b42383c746",
+    "author": {"name": "Shuhei Kitagawa", "email": "shuheiktgw@example.com", "date": "2024-01-30T12:00:00Z"},
+    "message": "Bumps up to 0.1.1\n",
+    "parents": [{"url": "https://gitea.example.com/api/v1/repos/o/r/git/commits/7b5c3cc8be40ee161ae89a06bba6229da1032a0c", "sha": "7b5c3cc8be40ee161ae89a06bba6229da1032a0c"}]
+  },
+  "verification": {"verified": false, "reason": "gpg.error.not_signed_commit", "signature": "", "payload": ""}
+}`
+
+// giteaPullRequestSample is a pull request to base in state, whose merged_at and merge_commit_sha are given as JSON values
+const giteaPullRequestSample = `{
+  "id": 84,
+  "url": "https://gitea.example.com/o/r/pulls/%[1]d",
+  "number": %[1]d,
+  "user": {"id": 1, "login": "shuheiktgw", "full_name": "Shuhei Kitagawa"},
+  "title": "%[2]s",
+  "body": "",
+  "labels": [{"id": 1, "name": "bug", "color": "ee0701", "description": ""}],
+  "state": "%[3]s",
+  "html_url": "https://gitea.example.com/o/r/pulls/%[1]d",
+  "mergeable": true,
+  "merged": %[4]t,
+  "merged_at": %[5]s,
+  "merge_commit_sha": %[6]s,
+  "base": {"label": "%[7]s", "ref": "%[7]s", "sha": "7b5c3cc8be40ee161ae89a06bba6229da1032a0c", "repo_id": 1},
+  "head": {"label": "bump", "ref": "bump", "sha": "8888888888888888888888888888888888888888", "repo_id": 1}
+}`
+
+func testGiteaPullRequest(number int, title, state, base string) string {
+	merged, mergedAt, mergeCommit := false, "null", "null"
+
+	if state == "merged" {
+		state, merged, mergedAt, mergeCommit = "closed", true, `"2018-09-07T11:16:17Z"`, `"9999999999999999999999999999999999999999"`
+	}
+
+	return fmt.Sprintf(giteaPullRequestSample, number, title, state, merged, mergedAt, mergeCommit, base)
+}
+
+// giteaReleaseSample is a release, which is a draft unless it is v0.1.0
+const giteaReleaseSample = `{
+  "id": %[1]d,
+  "tag_name": "%[2]s",
+  "target_commitish": "master",
+  "name": "%[2]s",
+  "body": "Bumps up to 0.1.1",
+  "url": "https://gitea.example.com/api/v1/repos/o/r/releases/%[1]d",
+  "html_url": "https://gitea.example.com/o/r/releases/tag/%[2]s",
+  "draft": %[3]t,
+  "prerelease": false,
+  "created_at": "2019-01-03T01:56:19Z",
+  "published_at": "2019-01-03T01:56:19Z",
+  "assets": []
+}`
+
+func testGiteaRelease(id int, tag string) string {
+	return fmt.Sprintf(giteaReleaseSample, id, tag, tag != "v0.1.0")
+}
+
+const giteaCompareSample = `{
+  "total_commits": 2,
+  "commits": [
+    {
+      "url": "https://gitea.example.com/api/v1/repos/o/r/git/commits/12d65c8dd2b2676fa3ac47d955accc085a37a9c1",
+      "sha": "12d65c8dd2b2676fa3ac47d955accc085a37a9c1",
+      "html_url": "https://gitea.example.com/o/r/commit/12d65c8dd2b2676fa3ac47d955accc085a37a9c1",
+      "commit": {
+        "author": {"name": "Shuhei Kitagawa", "email": "shuheiktgw@example.com", "date": "2024-01-30T12:00:00Z"},
+        "message": "Add foo\n"
+      },
+      "author": null,
+      "parents": [{"sha": "7b5c3cc8be40ee161ae89a06bba6229da1032a0c"}]
+    },
+    {
+      "url": "https://gitea.example.com/api/v1/repos/o/r/git/commits/9999999999999999999999999999999999999999",
+      "sha": "9999999999999999999999999999999999999999",
+      "html_url": "https://gitea.example.com/o/r/commit/9999999999999999999999999999999999999999",
+      "commit": {
+        "author": {"name": "Shuhei Kitagawa", "email": "shuheiktgw@example.com", "date": "2024-01-30T12:00:00Z"},
+        "message": "Merge pull request 'Fix bar' (#2) from fix-bar into master\n"
+      },
+      "author": {"id": 1, "login": "shuheiktgw", "full_name": "Shuhei Kitagawa"},
+      "parents": [{"sha": "12d65c8dd2b2676fa3ac47d955accc085a37a9c1"}, {"sha": "8888888888888888888888888888888888888888"}]
+    }
+  ]
+}`
+
+// testGiteaClient returns a client of repository o/r on a server serving routes, see testRESTServer
+func testGiteaClient(routes map[string]restResponse) (*GiteaClient, *[]*restRequest, func()) {
+	server, requests := testRESTServer("/api/v1/repos/o/r", routes)
+	c, _ := NewGiteaClient("o", "r", "gitea-test", server.URL)
+
+	return c, requests, server.Close
+}
+
+func TestNewGiteaClientFail(t *testing.T) {
+	cases := []struct {
+		owner, repo, token, apiURL string
+	}{
+		{owner: "", repo: "r", token: "gitea-test"},
+		{owner: "o", repo: "", token: "gitea-test"},
+		{owner: "o", repo: "r", token: ""},
+		{owner: "o", repo: "r", token: "gitea-test", apiURL: "gitea.example.com"},
+	}
+
+	for i, tc := range cases {
+		if _, err := NewGiteaClient(tc.owner, tc.repo, tc.token, tc.apiURL); err == nil {
+			t.Fatalf("#%d NewGiteaClient is supposed to fail", i)
+		}
+	}
+}
+
+func TestGiteaAPIURL(t *testing.T) {
+	cases := []struct {
+		apiURL, want, wantHost string
+	}{
+		{apiURL: "", want: "https://gitea.com/api/v1/", wantHost: "gitea.com"},
+		{apiURL: "https://codeberg.org", want: "https://codeberg.org/api/v1/", wantHost: "codeberg.org"},
+		{apiURL: "https://gitea.example.com/api/v1", want: "https://gitea.example.com/api/v1/", wantHost: "gitea.example.com"},
+		{apiURL: "http://example.com/gitea/", want: "http://example.com/gitea/api/v1/", wantHost: "example.com"},
+	}
+
+	for i, tc := range cases {
+		u, err := restAPIURL(tc.apiURL, GiteaAPIURL, "api/v1/")
+
+		if err != nil {
+			t.Fatalf("#%d restAPIURL failed: %s", i, err)
+		}
+
+		if u.String() != tc.want {
+			t.Fatalf("#%d invalid url: want: %s, got: %s", i, tc.want, u)
+		}
+
+		if got := restWebHost(tc.apiURL, GiteaHost); got != tc.wantHost {
+			t.Fatalf("#%d invalid host: want: %s, got: %s", i, tc.wantHost, got)
+		}
+	}
+}
+
+func TestGiteaErrorMessage(t *testing.T) {
+	cases := []struct {
+		body, want string
+	}{
+		{body: `{"message": "Branch not found", "url": "https://gitea.example.com/api/swagger"}`, want: "Branch not found"},
+		{body: `{"message": "Validation failed", "errors": ["Title cannot be empty", "Head cannot be empty"]}`, want: "Validation failed: Title cannot be empty, Head cannot be empty"},
+		{body: `<html>Bad Gateway</html>`, want: "502 Bad Gateway"},
+	}
+
+	for i, tc := range cases {
+		res := &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Body: ioutil.NopCloser(strings.NewReader(tc.body))}
+
+		if e := (&restClient{ErrorMessage: giteaErrorMessage}).newError("GET", "branches", res); e.Message != tc.want {
+			t.Fatalf("#%d invalid message: want: %q, got: %q", i, tc.want, e.Message)
+		}
+	}
+}
+
+func TestGiteaClientMissingPermissions(t *testing.T) {
+	cases := []struct {
+		repo restResponse
+		want string
+	}{
+		{repo: restResponse{body: `{"id": 1, "name": "r", "full_name": "o/r", "permissions": {"admin": false, "push": true, "pull": true}}`}},
+		{repo: restResponse{body: `{"id": 1, "name": "r", "full_name": "o/r", "permissions": {"admin": false, "push": false, "pull": true}}`}, want: "push access to o/r"},
+		{repo: restResponse{status: http.StatusNotFound, body: `{"errors": ["repository does not exist"], "message": "The target couldn't be found."}`}, want: "access to o/r"},
+	}
+
+	for i, tc := range cases {
+		c, _, done := testGiteaClient(map[string]restResponse{"GET ": tc.repo})
+		missing, err := c.MissingPermissions()
+		done()
+
+		if err != nil {
+			t.Fatalf("#%d MissingPermissions failed: %s", i, err)
+		}
+
+		if got := strings.Join(missing, "\n"); !strings.HasPrefix(got, tc.want) || (len(tc.want) == 0) != (len(got) == 0) {
+			t.Fatalf("#%d invalid missing permissions: want: %q, got: %q", i, tc.want, missing)
+		}
+	}
+}
+
+func TestGiteaClientBranches(t *testing.T) {
+	c, requests, done := testGiteaClient(map[string]restResponse{
+		"GET /branches/master":         {body: giteaBranchSample},
+		"POST /branches":               {status: http.StatusCreated, body: giteaBranchSample},
+		"DELETE /branches/feature/new": {status: http.StatusNoContent},
+	})
+	defer done()
+
+	b, err := c.GetBranch("master")
+
+	if err != nil || b.GetName() != "master" || !b.GetProtected() || b.GetCommit().GetSHA() != "7b5c3cc8be40ee161ae89a06bba6229da1032a0c" {
+		t.Fatalf("invalid branch: %+v, %v", b, err)
+	}
+
+	if b, err := c.GetBranch("unknown"); err != nil || b != nil {
+		t.Fatalf("GetBranch is supposed to return nil for an unknown branch: %+v, %v", b, err)
+	}
+
+	if err := c.CreateNewBranch("master", "feature/new"); err != nil {
+		t.Fatalf("CreateNewBranch failed: %s", err)
+	}
+
+	if body := (*requests)[2].body; body["new_branch_name"] != "feature/new" || body["old_branch_name"] != "master" {
+		t.Fatalf("invalid branch: %v", body)
+	}
+
+	if err := c.DeleteLatestRef("feature/new"); err != nil {
+		t.Fatalf("DeleteLatestRef failed: %s", err)
+	}
+
+	failures := []struct {
+		origin, new string
+	}{
+		{origin: "", new: "new"},
+		{origin: "master", new: ""},
+	}
+
+	for i, tc := range failures {
+		if err := c.CreateNewBranch(tc.origin, tc.new); err == nil {
+			t.Fatalf("#%d CreateNewBranch is supposed to fail", i)
+		}
+	}
+
+	if err := c.DeleteLatestRef("unknown"); err == nil {
+		t.Fatalf("DeleteLatestRef is supposed to fail for an unknown branch")
+	}
+}
+
+func TestGiteaClientFiles(t *testing.T) {
+	c, requests, done := testGiteaClient(map[string]restResponse{
+		"GET /contents/lib/r/version.rb": {body: giteaContentsSample},
+		"GET /contents":                  {body: giteaDirectorySample},
+	})
+	defer done()
+
+	rc, err := c.GetFile("master", "lib/r/version.rb")
+
+	if err != nil {
+		t.Fatalf("GetFile failed: %s", err)
+	}
+
+	content, err := decodeContent(rc)
+
+	if err != nil || content != "module R\n  VERSION = '0.1.0'\nend\n" || rc.GetName() != "version.rb" || rc.GetSHA() != "ab267fa25ca94b339de06d44806b69c9667aa354" {
+		t.Fatalf("invalid file: %+v: %q", rc, content)
+	}
+
+	if q := (*requests)[0].query; q.Get("ref") != "master" {
+		t.Fatalf("invalid query: %v", q)
+	}
+
+	if _, err := c.GetFile("master", "unknown"); !isNotFound(err) {
+		t.Fatalf("GetFile is supposed to fail with not found: %v", err)
+	}
+
+	names, err := c.ListFiles("master", "")
+
+	if want := []string{"lib", "r.gemspec"}; err != nil || fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("invalid files: want: %q, got: %q, %v", want, names, err)
+	}
+
+	if _, err := c.ListFiles("master", "lib/r"); err == nil {
+		t.Fatalf("ListFiles is supposed to fail for an unknown directory")
+	}
+
+	if want := "GET /contents/lib/r"; (*requests)[3].route != want {
+		t.Fatalf("invalid request: want: %s, got: %s", want, (*requests)[3].route)
+	}
+}
+
+func TestGiteaClientUpdateFiles(t *testing.T) {
+	routes := map[string]restResponse{
+		"GET /contents/lib/r/version.rb": {body: giteaContentsSample},
+		"POST /contents":                 {status: http.StatusCreated, body: giteaFilesSample},
+	}
+	c, requests, done := testGiteaClient(routes)
+	defer done()
+
+	files := map[string][]byte{
+		"lib/r/version.rb": []byte("module R\n  VERSION = '0.1.1'\nend\n"),
+		"CHANGELOG.md":     []byte("# Changelog\n"),
+	}
+
+	sha, err := c.UpdateFiles("bump", "Bumps up to 0.1.1", files)
+
+	if err != nil {
+		t.Fatalf("UpdateFiles failed: %s", err)
+	}
+
+	if sha != "ed899a2f4b50b4370feeea94676502b42383c746" {
+		t.Fatalf("invalid commit: %s", sha)
+	}
+
+	// The files are looked up to tell creations from updates, which carry the SHAs of them
+	want := fmt.Sprintf("[map[content:%s operation:create path:CHANGELOG.md] map[content:%s operation:update path:lib/r/version.rb sha:ab267fa25ca94b339de06d44806b69c9667aa354]]",
+		base64.StdEncoding.EncodeToString(files["CHANGELOG.md"]), base64.StdEncoding.EncodeToString(files["lib/r/version.rb"]))
+
+	if body := (*requests)[2].body; fmt.Sprint(body["files"]) != want || body["branch"] != "bump" || body["message"] != "Bumps up to 0.1.1" {
+		t.Fatalf("invalid commit: %v", body)
+	}
+
+	routes["POST /contents"] = restResponse{
+		status: http.StatusUnprocessableEntity,
+		body:   `{"message": "sha does not match [given: ab267fa25ca94b339de06d44806b69c9667aa354, expected: 3f4a0e8fb0dc4a3c9a0b0c4a1d1e2f3a4b5c6d7e]", "url": "https://gitea.example.com/api/swagger"}`,
+	}
+
+	if _, err := c.UpdateFiles("bump", "Bumps up to 0.1.1", files); errors.Cause(err) != ErrRefMoved {
+		t.Fatalf("UpdateFiles is supposed to fail with ErrRefMoved: %v", err)
+	}
+
+	failures := []struct {
+		branch, message string
+		files           map[string][]byte
+	}{
+		{branch: "", message: "Bumps up to 0.1.1", files: files},
+		{branch: "bump", message: "", files: files},
+		{branch: "bump", message: "Bumps up to 0.1.1", files: nil},
+	}
+
+	for i, tc := range failures {
+		if _, err := c.UpdateFiles(tc.branch, tc.message, tc.files); err == nil {
+			t.Fatalf("#%d UpdateFiles is supposed to fail", i)
+		}
+	}
+}
+
+func TestGiteaClientPullRequests(t *testing.T) {
+	// Open pull requests to another branch fill the first page
+	var page []string
+
+	for i := 0; i < giteaPageSize; i++ {
+		page = append(page, testGiteaPullRequest(i+10, "WIP", "open", "develop"))
+	}
+
+	c, requests, done := testGiteaClient(map[string]restResponse{
+		"POST /pulls":       {status: http.StatusCreated, body: testGiteaPullRequest(3, "Bumps up to 0.1.1", "open", "master")},
+		"GET /pulls?page=1": {body: "[" + strings.Join(page, ",") + "]"},
+		"GET /pulls?page=2": {body: "[" + testGiteaPullRequest(3, "Bumps up to 0.1.1", "open", "master") + "]"},
+		"PATCH /pulls/3":    {status: http.StatusCreated, body: testGiteaPullRequest(3, "Bumps up to 0.1.1", "closed", "master")},
+	})
+	defer done()
+
+	pr, err := c.CreatePullRequest("Bumps up to 0.1.1", "bump", "master", "Bumps up to 0.1.1")
+
+	if err != nil {
+		t.Fatalf("CreatePullRequest failed: %s", err)
+	}
+
+	if pr.GetNumber() != 3 || pr.GetHead().GetRef() != "bump" || pr.GetHTMLURL() != "https://gitea.example.com/o/r/pulls/3" || pr.MergedAt != nil {
+		t.Fatalf("invalid pull request: %+v", pr)
+	}
+
+	if body := (*requests)[0].body; body["head"] != "bump" || body["base"] != "master" || body["title"] != "Bumps up to 0.1.1" {
+		t.Fatalf("invalid pull request: %v", body)
+	}
+
+	prs, err := c.ListOpenPullRequests("master")
+
+	if err != nil || len(prs) != 1 || prs[0].GetNumber() != 3 {
+		t.Fatalf("invalid open pull requests: %+v, %v", prs, err)
+	}
+
+	if q := (*requests)[2].query; q.Get("state") != "open" || q.Get("page") != "2" {
+		t.Fatalf("invalid query: %v", q)
+	}
+
+	if err := c.ClosePullRequest(3); err != nil {
+		t.Fatalf("ClosePullRequest failed: %s", err)
+	}
+
+	if body := (*requests)[3].body; body["state"] != "closed" {
+		t.Fatalf("invalid update: %v", body)
+	}
+
+	if err := c.ClosePullRequest(1000); err == nil {
+		t.Fatalf("ClosePullRequest is supposed to fail for an unknown pull request")
+	}
+}
+
+func TestGiteaClientListMergedPullRequestsWithCommit(t *testing.T) {
+	c, _, done := testGiteaClient(map[string]restResponse{
+		"GET /commits/9999999999999999999999999999999999999999/pull": {body: testGiteaPullRequest(2, "Fix bar", "merged", "master")},
+	})
+	defer done()
+
+	prs, err := c.ListMergedPullRequestsWithCommit("9999999999999999999999999999999999999999")
+
+	if err != nil || len(prs) != 1 || prs[0].GetNumber() != 2 || prs[0].MergedAt == nil || prs[0].GetUser().GetLogin() != "shuheiktgw" {
+		t.Fatalf("invalid merged pull requests: %+v, %v", prs, err)
+	}
+
+	if len(prs[0].Labels) != 1 || prs[0].Labels[0].GetName() != "bug" {
+		t.Fatalf("invalid labels: %+v", prs[0].Labels)
+	}
+
+	// The other commits of a pull request are not found, as Gitea looks up only merge commits
+	if prs, err := c.ListMergedPullRequestsWithCommit("12d65c8dd2b2676fa3ac47d955accc085a37a9c1"); err != nil || len(prs) != 0 {
+		t.Fatalf("no pull request is supposed to be found: %+v, %v", prs, err)
+	}
+
+	if _, err := c.ListMergedPullRequestsWithCommit(""); err == nil {
+		t.Fatalf("ListMergedPullRequestsWithCommit is supposed to fail without a commit")
+	}
+}
+
+func TestGiteaClientReleases(t *testing.T) {
+	c, requests, done := testGiteaClient(map[string]restResponse{
+		"GET /releases/latest": {body: testGiteaRelease(1, "v0.1.0")},
+		"GET /releases":        {body: "[" + testGiteaRelease(2, "v0.1.1") + "," + testGiteaRelease(1, "v0.1.0") + "]"},
+		"POST /releases":       {status: http.StatusCreated, body: testGiteaRelease(2, "v0.1.1")},
+		"DELETE /releases/2":   {status: http.StatusNoContent},
+		"GET /tags/v0.1.0":     {body: `{"name": "v0.1.0", "message": "", "id": "7b5c3cc8be40ee161ae89a06bba6229da1032a0c", "commit": {"sha": "7b5c3cc8be40ee161ae89a06bba6229da1032a0c"}}`},
+	})
+	defer done()
+
+	if latest, err := c.LatestReleaseTag(); err != nil || latest != "v0.1.0" {
+		t.Fatalf("invalid latest release: %s, %v", latest, err)
+	}
+
+	r, err := c.CreateRelease("v0.1.1", "master", "v0.1.1", "Bumps up to 0.1.1")
+
+	if err != nil {
+		t.Fatalf("CreateRelease failed: %s", err)
+	}
+
+	if r.GetID() != 2 || r.GetTagName() != "v0.1.1" || !r.GetDraft() || r.GetHTMLURL() != "https://gitea.example.com/o/r/releases/tag/v0.1.1" {
+		t.Fatalf("invalid release: %+v", r)
+	}
+
+	if body := (*requests)[1].body; body["draft"] != true || body["target_commitish"] != "master" {
+		t.Fatalf("invalid release: %v", body)
+	}
+
+	if exists, err := c.TagExists("v0.1.0"); err != nil || !exists {
+		t.Fatalf("tag is supposed to exist: %v", err)
+	}
+
+	if exists, err := c.TagExists("v0.1.1"); err != nil || exists {
+		t.Fatalf("tag is not supposed to exist: %v", err)
+	}
+
+	// Drafts are found in the list of releases
+	if found, err := c.FindRelease("v0.1.1"); err != nil || found.GetID() != 2 || !found.GetDraft() {
+		t.Fatalf("invalid release: %+v, %v", found, err)
+	}
+
+	if found, err := c.FindRelease("v0.1.2"); err != nil || found != nil {
+		t.Fatalf("FindRelease is supposed to return nil for an unknown release: %+v, %v", found, err)
+	}
+
+	if err := c.DeleteRelease(2, "v0.1.1"); err != nil {
+		t.Fatalf("DeleteRelease failed: %s", err)
+	}
+
+	if err := c.DeleteRelease(3, "v0.1.2"); err == nil {
+		t.Fatalf("DeleteRelease is supposed to fail for an unknown release")
+	}
+}
+
+func TestGiteaClientLatestReleaseTagNone(t *testing.T) {
+	c, _, done := testGiteaClient(nil)
+	defer done()
+
+	if latest, err := c.LatestReleaseTag(); err != nil || len(latest) != 0 {
+		t.Fatalf("LatestReleaseTag is supposed to return an empty string without releases: %s, %v", latest, err)
+	}
+}
+
+func TestGiteaClientCompareCommits(t *testing.T) {
+	c, requests, done := testGiteaClient(map[string]restResponse{"GET /compare/v0.1.0...master": {body: giteaCompareSample}})
+	defer done()
+
+	ccs, err := c.CompareCommits("v0.1.0", "master")
+
+	if err != nil {
+		t.Fatalf("CompareCommits failed: %s", err)
+	}
+
+	// The author of a commit falls back on its name if it is not linked to any account
+	if len(ccs.Commits) != 2 || ccs.Commits[0].Message != "Add foo\n" || ccs.Commits[0].Author != "Shuhei Kitagawa" || ccs.Commits[1].Author != "shuheiktgw" {
+		t.Fatalf("invalid commits: %s", ccs)
+	}
+
+	if ccs.Commits[0].Merge || !ccs.Commits[1].Merge || ccs.Commits[1].HTMLURL != "https://gitea.example.com/o/r/commit/9999999999999999999999999999999999999999" {
+		t.Fatalf("invalid commits: %s", ccs)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("invalid requests: %q", restRoutes(*requests))
+	}
+
+	for i, tc := range []struct{ base, head string }{{base: "", head: "master"}, {base: "v0.1.0", head: ""}} {
+		if _, err := c.CompareCommits(tc.base, tc.head); err == nil {
+			t.Fatalf("#%d CompareCommits is supposed to fail", i)
+		}
+	}
+}
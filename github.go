@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 		"net/http"
+	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/google/go-github/github"
@@ -15,11 +17,20 @@ import (
 type GitHubClient struct {
 	Owner, Repo string
 	Client *github.Client
+
+	// App is the GitHub App the client authenticates as, or nil if it uses a token
+	App *AppTokenSource
+}
+
+// Repository returns the owner and the name of the repository
+func (c *GitHubClient) Repository() (string, string) {
+	return c.Owner, c.Repo
 }
 
 // ComparedCommit represents one commit and mainly used for formatting purpose
 type ComparedCommit struct {
-	Author, Message, HTMLURL string
+	SHA, Author, Message, HTMLURL string
+	Merge bool
 }
 
 // ComparedCommits represents a series of commits
@@ -44,15 +55,99 @@ func NewGitHubClient(owner, repo, token string) (*GitHubClient, error) {
 	ts := oauth2.StaticTokenSource(&oauth2.Token{
 		AccessToken: token,
 		})
-	tc := oauth2.NewClient(context.Background(), ts)
 
-	client := github.NewClient(tc)
+	return NewGitHubClientWithTokenSource(owner, repo, ts)
+}
+
+// NewGitHubClientWithTokenSource creates and initializes a new GitHubClient authenticated by ts, e.g. AppTokenSource
+func NewGitHubClientWithTokenSource(owner, repo string, ts oauth2.TokenSource) (*GitHubClient, error) {
+	if len(owner) == 0 {
+		return nil, errors.New("missing Github owner name")
+	}
+
+	if len(repo) == 0 {
+		return nil, errors.New("missing Github repository name")
+	}
+
+	tc := oauth2.NewClient(context.Background(), ts)
+	app, _ := ts.(*AppTokenSource)
 
 	return &GitHubClient{
 		Owner: owner,
 		Repo: repo,
-		Client: client,
-		}, nil
+		Client: github.NewClient(tc),
+		App: app,
+	}, nil
+}
+
+// SetEnterpriseURLs points the client to GitHub Enterprise Server. uploadURL is derived from baseURL if it is empty
+func (c *GitHubClient) SetEnterpriseURLs(baseURL, uploadURL string) error {
+	base, upload, err := enterpriseURLs(baseURL, uploadURL)
+
+	if err != nil {
+		return err
+	}
+
+	c.Client.BaseURL, c.Client.UploadURL = base, upload
+
+	return nil
+}
+
+// enterpriseURLs normalizes the urls of GitHub Enterprise Server API, e.g. https://ghe.example.com/ to
+// https://ghe.example.com/api/v3/ and https://ghe.example.com/api/uploads/
+func enterpriseURLs(baseURL, uploadURL string) (*url.URL, *url.URL, error) {
+	base, err := parseAPIURL(baseURL)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !strings.HasSuffix(base.Path, "/api/v3/") {
+		base.Path += "api/v3/"
+	}
+
+	if len(uploadURL) == 0 {
+		upload := *base
+		upload.Path = strings.TrimSuffix(base.Path, "v3/") + "uploads/"
+		return base, &upload, nil
+	}
+
+	upload, err := parseAPIURL(uploadURL)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return base, upload, nil
+}
+
+func parseAPIURL(rawurl string) (*url.URL, error) {
+	u, err := url.Parse(rawurl)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid API url: %s", rawurl)
+	}
+
+	if (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return nil, errors.Errorf("invalid API url: url must start with https:// or http://: %s", rawurl)
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return u, nil
+}
+
+// WebHost returns the host of the web pages of GitHub, whose API is served on apiURL
+func WebHost(apiURL string) string {
+	u, err := url.Parse(apiURL)
+
+	if len(apiURL) == 0 || err != nil || u.Hostname() == "api.github.com" {
+		return GitHubHost
+	}
+
+	return u.Hostname()
 }
 
 // CreateNewBranch creates a new branch from the heads of the origin
@@ -64,7 +159,7 @@ func (c *GitHubClient) CreateNewBranch(origin, new string) error {
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return errors.Errorf("get ref: branch name: %s invalid: status: %s", res.Status)
+		return errors.Errorf("get ref: branch name: %s invalid: status: %s", origin, res.Status)
 	}
 
 	newRef := &github.Reference{
@@ -87,18 +182,14 @@ func (c *GitHubClient) CreateNewBranch(origin, new string) error {
 	return nil
 }
 
-// GetVersion gets the latest version.rb file
-func (c *GitHubClient) GetVersion(branch, path string) (*github.RepositoryContent, error) {
+// GetFile gets the latest file of the branch
+func (c *GitHubClient) GetFile(branch, path string) (*github.RepositoryContent, error) {
 	if len(branch) == 0 {
 		return nil, errors.New("missing Github branch name")
 	}
 
 	if len(path) == 0 {
-		return nil, errors.New("missing Github version.rb path")
-	}
-
-	if !strings.HasSuffix(path, "version.rb") {
-		return nil, errors.Errorf("invalid version file path: version file path must ends with version.rb: invalid path: %s", path)
+		return nil, errors.New("missing Github file path")
 	}
 
 	opt := &github.RepositoryContentGetOptions{Ref: branch}
@@ -106,51 +197,171 @@ func (c *GitHubClient) GetVersion(branch, path string) (*github.RepositoryConten
 	file, _, res, err := c.Client.Repositories.GetContents(context.TODO(), c.Owner, c.Repo, path, opt)
 
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get version file")
+		return nil, errors.Wrapf(err, "failed to get file: path: %s", path)
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("get version: invalid status: %s", res.Status)
+		return nil, errors.Errorf("get file: invalid status: %s", res.Status)
+	}
+
+	if file == nil {
+		return nil, errors.Errorf("get file: path is not a file: path: %s", path)
 	}
 
 	return file, nil
 }
 
-// UpdateVersion updates a version.rb file with a given content
-func (c *GitHubClient) UpdateVersion(path, message, sha, branch string, content []byte) error {
-	if len(path) == 0 {
-		return errors.New("missing Github version.rb path")
+// ListFiles lists names of files and directories in a directory of the branch, dir is empty for the root
+func (c *GitHubClient) ListFiles(branch, dir string) ([]string, error) {
+	if len(branch) == 0 {
+		return nil, errors.New("missing Github branch name")
 	}
 
-	if len(message) == 0 {
-		return errors.New("missing Github commit message")
+	opt := &github.RepositoryContentGetOptions{Ref: branch}
+
+	_, contents, res, err := c.Client.Repositories.GetContents(context.TODO(), c.Owner, c.Repo, dir, opt)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list files: directory: %s", dir)
 	}
 
-	if len(content) == 0 {
-		return errors.New("missing Github content")
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("list files: invalid status: %s", res.Status)
 	}
 
-	if len(sha) == 0 {
-		return errors.New("missing Github file sha")
+	var names []string
+
+	for _, rc := range contents {
+		names = append(names, rc.GetName())
 	}
 
+	return names, nil
+}
+
+// ErrRefMoved is returned when a branch moves while a new commit is being built on top of it
+var ErrRefMoved = errors.New("branch has been updated while creating a new commit")
+
+// UpdateFiles updates several files of the branch in a single commit, using Git Data API.
+// It builds a new tree and a new commit on top of the head of the branch, and then fast-forwards
+// the branch to the commit. It returns ErrRefMoved if the branch has moved in the meantime
+func (c *GitHubClient) UpdateFiles(branch, message string, files map[string][]byte) (string, error) {
 	if len(branch) == 0 {
-		return errors.New("missing Github branch name")
+		return "", errors.New("missing Github branch name")
 	}
 
-	opt := &github.RepositoryContentFileOptions{Message: &message, Content: content, SHA: &sha, Branch: &branch}
+	if len(message) == 0 {
+		return "", errors.New("missing Github commit message")
+	}
+
+	if len(files) == 0 {
+		return "", errors.New("missing Github files to update")
+	}
 
-	_, res, err := c.Client.Repositories.UpdateFile(context.TODO(), c.Owner, c.Repo, path, opt)
+	head, err := c.getHead(branch)
 
 	if err != nil {
-		return errors.Wrap(err, "failed to update version file")
+		return "", err
+	}
+
+	parent, res, err := c.Client.Git.GetCommit(context.TODO(), c.Owner, c.Repo, head)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the head commit: branch name: %s", branch)
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return errors.Errorf("create version: invalid status: %s", res.Status)
+		return "", errors.Errorf("get commit: invalid status: %s", res.Status)
 	}
 
-	return nil
+	var paths []string
+
+	for p := range files {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	var entries []github.TreeEntry
+
+	for _, p := range paths {
+		entries = append(entries, github.TreeEntry{
+			Path: github.String(p),
+			Mode: github.String("100644"),
+			Type: github.String("blob"),
+			Content: github.String(string(files[p])),
+		})
+	}
+
+	tree, res, err := c.Client.Git.CreateTree(context.TODO(), c.Owner, c.Repo, parent.Tree.GetSHA(), entries)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create a new tree")
+	}
+
+	if res.StatusCode != http.StatusCreated {
+		return "", errors.Errorf("create tree: invalid status: %s", res.Status)
+	}
+
+	commit, res, err := c.Client.Git.CreateCommit(context.TODO(), c.Owner, c.Repo, &github.Commit{
+		Message: &message,
+		Tree: tree,
+		Parents: []github.Commit{{SHA: github.String(head)}},
+	})
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create a new commit")
+	}
+
+	if res.StatusCode != http.StatusCreated {
+		return "", errors.Errorf("create commit: invalid status: %s", res.Status)
+	}
+
+	current, err := c.getHead(branch)
+
+	if err != nil {
+		return "", err
+	}
+
+	if current != head {
+		return "", errors.Wrapf(ErrRefMoved, "branch name: %s: expected head: %s, actual head: %s", branch, head, current)
+	}
+
+	newRef := &github.Reference{
+		Ref: github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}
+
+	// force is false, so GitHub rejects the update unless it is a fast-forward from the head
+	_, res, err = c.Client.Git.UpdateRef(context.TODO(), c.Owner, c.Repo, newRef, false)
+
+	if isUnprocessable(err) {
+		return "", errors.Wrapf(ErrRefMoved, "branch name: %s: %s", branch, err)
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to update ref: branch name: %s", branch)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("update ref: invalid status: %s", res.Status)
+	}
+
+	return commit.GetSHA(), nil
+}
+
+// getHead gets the SHA of the head commit of the branch
+func (c *GitHubClient) getHead(branch string) (string, error) {
+	ref, res, err := c.Client.Git.GetRef(context.TODO(), c.Owner, c.Repo, "heads/" + branch)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get ref: branch name: %s", branch)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("get ref: invalid status: %s", res.Status)
+	}
+
+	return ref.Object.GetSHA(), nil
 }
 
 // TODO Enable to add custom labels to PR
@@ -243,8 +454,8 @@ func (c *GitHubClient) CreateRelease(tagName, targetCommitish, name, body string
 	return rr, nil
 }
 
-// DeleteRelease deletes a release
-func (c *GitHubClient) DeleteRelease(id int64) (error) {
+// DeleteRelease deletes a release by its ID, the tag is not needed on GitHub
+func (c *GitHubClient) DeleteRelease(id int64, tag string) (error) {
 	res, err := c.Client.Repositories.DeleteRelease(context.TODO(), c.Owner, c.Repo, id)
 
 	if err != nil {
@@ -281,12 +492,135 @@ func (c *GitHubClient) CompareCommits(base, head string) (*ComparedCommits, erro
 	var ccs []*ComparedCommit
 
 	for _, c := range cc.Commits {
-		ccs = append(ccs, &ComparedCommit{Author: *c.Author.Login, Message: *c.Commit.Message, HTMLURL: *c.HTMLURL})
+		author := c.GetAuthor().GetLogin()
+
+		// Commits whose author email is not linked to any GitHub account do not have the author
+		if len(author) == 0 {
+			author = c.GetCommit().GetAuthor().GetName()
+		}
+
+		ccs = append(ccs, &ComparedCommit{SHA: c.GetSHA(), Author: author, Message: c.GetCommit().GetMessage(), HTMLURL: c.GetHTMLURL(), Merge: len(c.Parents) > 1})
 	}
 
 	return &ComparedCommits{Commits: ccs}, nil
 }
 
+// ListMergedPullRequestsWithCommit lists merged pull requests which contain a given commit
+func (c *GitHubClient) ListMergedPullRequestsWithCommit(sha string) ([]*github.PullRequest, error) {
+	if len(sha) == 0 {
+		return nil, errors.New("missing GitHub commit sha")
+	}
+
+	req, err := c.Client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/commits/%s/pulls", c.Owner, c.Repo, sha), nil)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build a request to list pull requests")
+	}
+
+	// Listing pull requests associated with a commit is a preview feature
+	req.Header.Set("Accept", "application/vnd.github.groot-preview+json")
+
+	var prs []*github.PullRequest
+	res, err := c.Client.Do(context.TODO(), req, &prs)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pull requests: commit: %s", sha)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("list pull requests: invalid status: %s", res.Status)
+	}
+
+	var merged []*github.PullRequest
+
+	for _, pr := range prs {
+		if pr.MergedAt != nil {
+			merged = append(merged, pr)
+		}
+	}
+
+	return merged, nil
+}
+
+// GetBranch gets a branch, and returns nil if it does not exist
+func (c *GitHubClient) GetBranch(name string) (*github.Branch, error) {
+	b, _, err := c.Client.Repositories.GetBranch(context.TODO(), c.Owner, c.Repo, name)
+
+	if isNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get branch: %s", name)
+	}
+
+	return b, nil
+}
+
+// TagExists returns true if the tag exists
+func (c *GitHubClient) TagExists(tag string) (bool, error) {
+	ref, _, err := c.Client.Git.GetRef(context.TODO(), c.Owner, c.Repo, "tags/" + tag)
+
+	if isNotFound(err) {
+		return false, nil
+	}
+
+	// GitHub returns tags starting with the name if the tag itself does not exist
+	if err != nil && strings.Contains(err.Error(), "multiple matches") {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get tag: %s", tag)
+	}
+
+	return ref.GetRef() == "refs/tags/" + tag, nil
+}
+
+// FindRelease finds a release of the tag among the latest 100 releases including drafts, and returns nil if there is none
+func (c *GitHubClient) FindRelease(tag string) (*github.RepositoryRelease, error) {
+	releases, _, err := c.Client.Repositories.ListReleases(context.TODO(), c.Owner, c.Repo, &github.ListOptions{PerPage: 100})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list releases")
+	}
+
+	for _, r := range releases {
+		if r.GetTagName() == tag {
+			return r, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// LatestReleaseTag returns the tag of the latest published release, or an empty string if there is none
+func (c *GitHubClient) LatestReleaseTag() (string, error) {
+	r, _, err := c.Client.Repositories.GetLatestRelease(context.TODO(), c.Owner, c.Repo)
+
+	if isNotFound(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the latest release")
+	}
+
+	return r.GetTagName(), nil
+}
+
+// ListOpenPullRequests lists open pull requests to the base branch
+func (c *GitHubClient) ListOpenPullRequests(base string) ([]*github.PullRequest, error) {
+	opt := &github.PullRequestListOptions{State: "open", Base: base, ListOptions: github.ListOptions{PerPage: 100}}
+	prs, _, err := c.Client.PullRequests.List(context.TODO(), c.Owner, c.Repo, opt)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list open pull requests: base: %s", base)
+	}
+
+	return prs, nil
+}
+
 // DeleteLatestRef deletes the latest Ref of the given branch, intended to be used for rollbacks
 func (c *GitHubClient) DeleteLatestRef(branch string) error {
 	if len(branch) == 0 {
@@ -306,6 +640,33 @@ func (c *GitHubClient) DeleteLatestRef(branch string) error {
 	return nil
 }
 
+// isNotFound returns true if err is a 404 response from GitHub API, or a NotFoundError of other forges
+func isNotFound(err error) bool {
+	if _, ok := errors.Cause(err).(*NotFoundError); ok {
+		return true
+	}
+
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// isUnprocessable returns true if err is a 422 response from GitHub API
+func isUnprocessable(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity)
+}
+
+func hasStatus(err error, status int) bool {
+	if er, ok := errors.Cause(err).(*github.ErrorResponse); ok {
+		return er.Response != nil && er.Response.StatusCode == status
+	}
+
+	return false
+}
+
+// Title returns the first line of the commit message
+func (cc *ComparedCommit) Title() string {
+	return strings.TrimSpace(strings.SplitN(cc.Message, "\n", 2)[0])
+}
+
 func (cc *ComparedCommit) String() string {
 	return fmt.Sprintf("@%s [%s](%s)", cc.Author, cc.Message, cc.HTMLURL)
 }
@@ -2,8 +2,13 @@ package main
 
 import (
 	"testing"
-	"os"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -11,15 +16,6 @@ const (
 	TestRepo = "github-api-test"
 )
 
-func testGitHubClient(t *testing.T) *GitHubClient {
-	token := os.Getenv(EnvGitHubToken)
-	client, err := NewGitHubClient(TestOwner, TestRepo, token)
-	if err != nil {
-		t.Fatal("NewGitHubClient failed:", err)
-	}
-	return client
-}
-
 func TestNewGitHubClientFail(t *testing.T) {
 	cases := []struct {
 		owner, repo, token string
@@ -43,6 +39,9 @@ func TestNewGitHubClientSuccess(t *testing.T) {
 }
 
 func TestCreateNewBranchFail(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		origin, new string
 	}{
@@ -53,8 +52,6 @@ func TestCreateNewBranchFail(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
 		err := c.CreateNewBranch(tc.origin, tc.new)
 
 		if err == nil {
@@ -70,6 +67,9 @@ func TestCreateNewBranchFail(t *testing.T) {
 }
 
 func TestCreateNewBranchSuccess(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		origin, new string
 	}{
@@ -77,8 +77,6 @@ func TestCreateNewBranchSuccess(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
 		err := c.CreateNewBranch(tc.origin, tc.new)
 
 		if err != nil {
@@ -93,7 +91,10 @@ func TestCreateNewBranchSuccess(t *testing.T) {
 	}
 }
 
-func TestGetVersionFail(t *testing.T) {
+func TestGetFileFail(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		branch, path string
 	}{
@@ -103,74 +104,169 @@ func TestGetVersionFail(t *testing.T) {
 		{branch: "unknown", path: "unknown"},
 		{branch: "unknown", path: fmt.Sprintf("lib/%s/version.rb", TestRepo)},
 		{branch: "develop", path: "unknown"},
-		{branch: "develop", path: "README.md"},
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
-		_ , err := c.GetVersion(tc.branch, tc.path)
+		_ , err := c.GetFile(tc.branch, tc.path)
 
 		if err == nil {
-			t.Fatalf("#%d GetVersion: error is not supposed to be nil", i)
+			t.Fatalf("#%d GetFile: error is not supposed to be nil", i)
 		}
 	}
 }
 
-func TestGetVersionSuccess(t *testing.T) {
+func TestGetFileSuccess(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		branch, path string
 	}{
 		{branch: "master", path: fmt.Sprintf("lib/%s/version.rb", TestRepo)},
 		{branch: "develop", path: fmt.Sprintf("lib/%s/version.rb", TestRepo)},
+		{branch: "develop", path: "README.md"},
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
-		_, err := c.GetVersion(tc.branch, tc.path)
+		_, err := c.GetFile(tc.branch, tc.path)
 
 		if err != nil {
-			t.Fatalf("#%d GetVersion failed: %s", i, err)
+			t.Fatalf("#%d GetFile failed: %s", i, err)
 		}
 	}
 }
 
-// TODO: Move this test to integration tests folder and add error patterns tests
-func TestUpdateVersionSuccess(t *testing.T) {
+func TestUpdateFilesFail(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
-		path, message, sha, branch string
-		content []byte
+		branch, message string
+		files map[string][]byte
 	}{
-		{path: fmt.Sprintf("lib/%s/version.rb", TestRepo), message: "Bumps up to 0.1.1", content: []byte("module GithubAPITest\n  VERSION = '0.1.1'\nend")},
+		{branch: "", message: "Bumps up to 0.1.1", files: map[string][]byte{"VERSION": []byte("0.1.1")}},
+		{branch: "develop", message: "", files: map[string][]byte{"VERSION": []byte("0.1.1")}},
+		{branch: "develop", message: "Bumps up to 0.1.1", files: nil},
+		{branch: "unknown", message: "Bumps up to 0.1.1", files: map[string][]byte{"VERSION": []byte("0.1.1")}},
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
+		if _, err := c.UpdateFiles(tc.branch, tc.message, tc.files); err == nil {
+			t.Fatalf("#%d UpdateFiles is supposed to fail", i)
+		}
+	}
+}
+
+// TODO: Move this test to integration tests folder
+func TestUpdateFilesSuccess(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
 
+	cases := []struct {
+		message string
+		files map[string][]byte
+	}{
+		{
+			message: "Bumps up to 0.1.1",
+			files: map[string][]byte{
+				fmt.Sprintf("lib/%s/version.rb", TestRepo): []byte("module GithubAPITest\n  VERSION = '0.1.1'\nend"),
+				"Gemfile.lock": []byte("PATH\n  remote: .\n  specs:\n    github-api-test (0.1.1)\n"),
+			},
+		},
+	}
+
+	for i, tc := range cases {
 		if err := c.CreateNewBranch("develop", "test"); err != nil {
 			t.Fatalf("#%d CreateNewBranch failed: %s", i, err)
 		}
 
-		content, err := c.GetVersion("test", tc.path)
+		if _, err := c.UpdateFiles("test", tc.message, tc.files); err != nil {
+			t.Errorf("#%d UpdateFiles failed: %s", i, err)
+		}
 
-		if err != nil {
-			t.Fatalf("#%d GetVersion failed: %s", i, err)
+		if err := c.DeleteLatestRef("test"); err != nil {
+			t.Fatalf("#%d DeleteLatestRef failed: %s", i, err)
 		}
+	}
+}
+
+func TestUpdateFilesRefMoved(t *testing.T) {
+	heads := []string{"aaa", "bbb"}
 
-		if err := c.UpdateVersion(tc.path, tc.message, *content.SHA, "test", tc.content); err != nil {
-			t.Fatalf("#%d UpdateVersion failed: %s", i, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/o/r/git/refs/heads/test", func(w http.ResponseWriter, r *http.Request) {
+		head := heads[0]
+
+		if len(heads) > 1 {
+			heads = heads[1:]
 		}
 
-		err = c.DeleteLatestRef("test")
+		fmt.Fprintf(w, `{"ref": "refs/heads/test", "object": {"sha": "%s"}}`, head)
+	})
+	mux.HandleFunc("/repos/o/r/git/commits/aaa", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sha": "aaa", "tree": {"sha": "tree"}}`)
+	})
+	mux.HandleFunc("/repos/o/r/git/trees", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"sha": "newtree"}`)
+	})
+	mux.HandleFunc("/repos/o/r/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"sha": "ccc"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	c := &GitHubClient{Owner: "o", Repo: "r", Client: client}
+
+	_, err := c.UpdateFiles("test", "Bumps up to 0.1.1", map[string][]byte{"VERSION": []byte("0.1.1")})
+
+	if errors.Cause(err) != ErrRefMoved {
+		t.Fatalf("UpdateFiles is supposed to fail with ErrRefMoved: got: %v", err)
+	}
+}
+
+func TestListFilesSuccess(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
 
-		if err != nil {
-			t.Fatalf("#d DeleteLatestRef failed: %s", err)
+	files, err := c.ListFiles("develop", "")
+
+	if err != nil {
+		t.Fatalf("ListFiles failed: %s", err)
+	}
+
+	if len(files) == 0 {
+		t.Fatalf("ListFiles returned no files")
+	}
+}
+
+func TestListFilesFail(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
+	cases := []struct {
+		branch, dir string
+	}{
+		{branch: "", dir: ""},
+		{branch: "unknown", dir: ""},
+		{branch: "develop", dir: "unknown"},
+	}
+
+	for i, tc := range cases {
+		if _, err := c.ListFiles(tc.branch, tc.dir); err == nil {
+			t.Fatalf("#%d ListFiles: error is not supposed to be nil", i)
 		}
 	}
 }
 
 func TestCreatePullRequestFail(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		title, head, base, body string
 	}{
@@ -184,8 +280,6 @@ func TestCreatePullRequestFail(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
 		if pr, err := c.CreatePullRequest(tc.title, tc.head, tc.base, tc.body); err == nil {
 			if e := c.ClosePullRequest(*pr.Number); e != nil {
 				t.Errorf("%d ClosePullRequest failed: might need to close a PR manually: %s", i, e)
@@ -197,6 +291,9 @@ func TestCreatePullRequestFail(t *testing.T) {
 
 // TODO: Move this test to integration tests folder
 func TestCreatePullRequestSuccess(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		title, head, base, body string
 	}{
@@ -204,8 +301,6 @@ func TestCreatePullRequestSuccess(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
 		pr, err := c.CreatePullRequest(tc.title, tc.head, tc.base, tc.body)
 
 		if err != nil {
@@ -219,7 +314,8 @@ func TestCreatePullRequestSuccess(t *testing.T) {
 }
 
 func TestClosePullRequestFail(t *testing.T) {
-	c := testGitHubClient(t)
+	c, done := testGitHubClient(t)
+	defer done()
 
 	if err := c.ClosePullRequest(0); err == nil {
 		t.Fatalf("ClosePullRequest is supposed to fail")
@@ -227,6 +323,9 @@ func TestClosePullRequestFail(t *testing.T) {
 }
 
 func TestCreateReleaseFail(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		tagName, targetCommitish, name, body string
 	}{
@@ -238,8 +337,6 @@ func TestCreateReleaseFail(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
 		if _, err := c.CreateRelease(tc.tagName, tc.targetCommitish, tc.name, tc.body); err == nil {
 			t.Fatalf("#%d CreateRelease is supposed to fail", i)
 		}
@@ -247,6 +344,9 @@ func TestCreateReleaseFail(t *testing.T) {
 }
 
 func TestCreateReleaseSuccess(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		tagName, targetCommitish, name, body string
 	}{
@@ -254,21 +354,22 @@ func TestCreateReleaseSuccess(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
 		rr, err := c.CreateRelease(tc.tagName, tc.targetCommitish, tc.name, tc.body)
 
 		if err != nil {
 			t.Fatalf("#%d CreateRelease failed: %s", i, err)
 		}
 
-		if e := c.DeleteRelease(*rr.ID); e != nil {
+		if e := c.DeleteRelease(*rr.ID, tc.tagName); e != nil {
 			t.Errorf("%d DeleteRelease failed: might need to delete a Release manually: %s", i, e)
 		}
 	}
 }
 
 func TestCompareCommitsFail(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		base, head string
 	}{
@@ -279,8 +380,6 @@ func TestCompareCommitsFail(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
 		_, err := c.CompareCommits(tc.head, tc.base)
 
 		if err == nil {
@@ -290,6 +389,9 @@ func TestCompareCommitsFail(t *testing.T) {
 }
 
 func TestCompareCommitsSuccess(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
 	cases := []struct {
 		base, head string
 	}{
@@ -300,8 +402,6 @@ func TestCompareCommitsSuccess(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		c := testGitHubClient(t)
-
 		_, err := c.CompareCommits(tc.head, tc.base)
 
 		if err != nil {
@@ -310,6 +410,19 @@ func TestCompareCommitsSuccess(t *testing.T) {
 	}
 }
 
+func TestListMergedPullRequestsWithCommitFail(t *testing.T) {
+	c, done := testGitHubClient(t)
+	defer done()
+
+	cases := []string{"", "unknown"}
+
+	for i, tc := range cases {
+		if _, err := c.ListMergedPullRequestsWithCommit(tc); err == nil {
+			t.Fatalf("#%d ListMergedPullRequestsWithCommit is supposed to fail", i)
+		}
+	}
+}
+
 func TestComparedCommitString(t *testing.T) {
 	cc := &ComparedCommit{Author: "shuheiktgw", Message: "The Best Commit Ever!", HTMLURL: "https://github.com/shuheiktgw/github-api-test/commit/d6ed804c9bbaefef1832702db562a3b1e98e1291"}
 	want := "@shuheiktgw [The Best Commit Ever!](https://github.com/shuheiktgw/github-api-test/commit/d6ed804c9bbaefef1832702db562a3b1e98e1291)"
@@ -333,4 +446,79 @@ func TestComparedCommitsString(t *testing.T) {
 	if ccs.String() != want {
 		t.Fatalf("invalid string: want: %s got: %s", want, ccs.String())
 	}
-}
\ No newline at end of file
+}
+func TestEnterpriseURLs(t *testing.T) {
+	cases := []struct {
+		baseURL, uploadURL string
+		wantBase, wantUpload string
+	}{
+		{baseURL: "https://ghe.example.com", wantBase: "https://ghe.example.com/api/v3/", wantUpload: "https://ghe.example.com/api/uploads/"},
+		{baseURL: "https://ghe.example.com/api/v3", wantBase: "https://ghe.example.com/api/v3/", wantUpload: "https://ghe.example.com/api/uploads/"},
+		{baseURL: "http://ghe.example.com:8080/github/", wantBase: "http://ghe.example.com:8080/github/api/v3/", wantUpload: "http://ghe.example.com:8080/github/api/uploads/"},
+		{baseURL: "https://ghe.example.com/api/v3/", uploadURL: "https://uploads.example.com", wantBase: "https://ghe.example.com/api/v3/", wantUpload: "https://uploads.example.com/"},
+	}
+
+	for i, tc := range cases {
+		base, upload, err := enterpriseURLs(tc.baseURL, tc.uploadURL)
+
+		if err != nil {
+			t.Fatalf("#%d enterpriseURLs failed: %s", i, err)
+		}
+
+		if base.String() != tc.wantBase || upload.String() != tc.wantUpload {
+			t.Fatalf("#%d invalid urls: want: %s and %s, got: %s and %s", i, tc.wantBase, tc.wantUpload, base, upload)
+		}
+	}
+
+	for i, tc := range []string{"", "ghe.example.com", "ftp://ghe.example.com", "https://"} {
+		if _, _, err := enterpriseURLs(tc, ""); err == nil {
+			t.Fatalf("#%d enterpriseURLs is supposed to fail: %q", i, tc)
+		}
+	}
+}
+
+func TestWebHost(t *testing.T) {
+	cases := []struct {
+		apiURL, want string
+	}{
+		{apiURL: "", want: "github.com"},
+		{apiURL: "https://api.github.com", want: "github.com"},
+		{apiURL: "https://api.github.com/", want: "github.com"},
+		{apiURL: "https://ghe.example.com/api/v3/", want: "ghe.example.com"},
+		{apiURL: "https://ghe.example.com:8443/api/v3/", want: "ghe.example.com"},
+	}
+
+	for i, tc := range cases {
+		if got := WebHost(tc.apiURL); got != tc.want {
+			t.Fatalf("#%d invalid host: want: %s, got: %s", i, tc.want, got)
+		}
+	}
+}
+
+func TestSetEnterpriseURLs(t *testing.T) {
+	var path string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		fmt.Fprint(w, `{"type": "file", "path": "VERSION", "content": "MC4xLjA="}`)
+	}))
+	defer server.Close()
+
+	c, err := NewGitHubClient("o", "r", "testToken")
+
+	if err != nil {
+		t.Fatalf("NewGitHubClient failed: %s", err)
+	}
+
+	if err := c.SetEnterpriseURLs(server.URL, ""); err != nil {
+		t.Fatalf("SetEnterpriseURLs failed: %s", err)
+	}
+
+	if _, err := c.GetFile("master", "VERSION"); err != nil {
+		t.Fatalf("GetFile failed: %s", err)
+	}
+
+	if want := "/api/v3/repos/o/r/contents/VERSION"; path != want {
+		t.Fatalf("invalid request path: want: %s, got: %s", want, path)
+	}
+}
@@ -0,0 +1,645 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// GitLabAPIURL is the url of GitLab.com API
+const GitLabAPIURL = "https://gitlab.com/api/v4/"
+
+// EnvGitLabToken is the environment variable of a GitLab access token
+const EnvGitLabToken = "GITLAB_TOKEN"
+
+// gitLabDeveloper is the access level of Developer role, which can push branches, open merge requests and create releases
+const gitLabDeveloper = 30
+
+// GitLabClient is a client to interact with GitLab API v4. Merge requests stand for pull requests of GitHub
+type GitLabClient struct {
+	// Namespace is the group or the user the project belongs to, which can be a subgroup, e.g. `group/subgroup`
+	Namespace, Project string
+
+	BaseURL    *url.URL
+	Token      string
+	HTTPClient *http.Client
+}
+
+type gitLabBranch struct {
+	Name      string `json:"name"`
+	Protected bool   `json:"protected"`
+	Commit    struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+type gitLabFile struct {
+	FileName     string `json:"file_name"`
+	FilePath     string `json:"file_path"`
+	Size         int    `json:"size"`
+	Encoding     string `json:"encoding"`
+	Content      string `json:"content"`
+	BlobID       string `json:"blob_id"`
+	LastCommitID string `json:"last_commit_id"`
+}
+
+type gitLabMergeRequest struct {
+	IID             int        `json:"iid"`
+	Title           string     `json:"title"`
+	State           string     `json:"state"`
+	WebURL          string     `json:"web_url"`
+	SourceBranch    string     `json:"source_branch"`
+	TargetBranch    string     `json:"target_branch"`
+	SHA             string     `json:"sha"`
+	MergeCommitSHA  string     `json:"merge_commit_sha"`
+	SquashCommitSHA string     `json:"squash_commit_sha"`
+	Labels          []string   `json:"labels"`
+	MergedAt        *time.Time `json:"merged_at"`
+	Author          struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+type gitLabRelease struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Links       struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+type gitLabCommit struct {
+	ID         string   `json:"id"`
+	Message    string   `json:"message"`
+	AuthorName string   `json:"author_name"`
+	WebURL     string   `json:"web_url"`
+	ParentIDs  []string `json:"parent_ids"`
+}
+
+// NewGitLabClient creates and initializes a new GitLabClient. apiURL is the url of GitLab API, and GitLab.com is used if it is empty
+func NewGitLabClient(namespace, project, token, apiURL string) (*GitLabClient, error) {
+	if len(namespace) == 0 {
+		return nil, errors.New("missing GitLab namespace")
+	}
+
+	if len(project) == 0 {
+		return nil, errors.New("missing GitLab project name")
+	}
+
+	if len(token) == 0 {
+		return nil, errors.New("missing GitLab access token")
+	}
+
+	base, err := restAPIURL(apiURL, GitLabAPIURL, "api/v4/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitLabClient{Namespace: namespace, Project: project, BaseURL: base, Token: token, HTTPClient: http.DefaultClient}, nil
+}
+
+// Repository returns the namespace and the name of the project
+func (c *GitLabClient) Repository() (string, string) {
+	return c.Namespace, c.Project
+}
+
+// MissingPermissions checks that the token has Developer role or higher on the project, which is needed
+// to push branches, open merge requests and create releases
+func (c *GitLabClient) MissingPermissions() ([]string, error) {
+	var project struct {
+		Permissions struct {
+			ProjectAccess *struct {
+				AccessLevel int `json:"access_level"`
+			} `json:"project_access"`
+			GroupAccess *struct {
+				AccessLevel int `json:"access_level"`
+			} `json:"group_access"`
+		} `json:"permissions"`
+	}
+
+	err := c.do("GET", "", nil, nil, &project)
+
+	if s := restStatus(err); s == http.StatusNotFound || s == http.StatusUnauthorized {
+		return []string{fmt.Sprintf("access to %s/%s: the project is not found, or the token cannot read it", c.Namespace, c.Project)}, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get project %s/%s", c.Namespace, c.Project)
+	}
+
+	var level int
+
+	if a := project.Permissions.ProjectAccess; a != nil && a.AccessLevel > level {
+		level = a.AccessLevel
+	}
+
+	if a := project.Permissions.GroupAccess; a != nil && a.AccessLevel > level {
+		level = a.AccessLevel
+	}
+
+	if level < gitLabDeveloper {
+		return []string{fmt.Sprintf("Developer role on %s/%s: to push branches, open merge requests and create releases", c.Namespace, c.Project)}, nil
+	}
+
+	return nil, nil
+}
+
+// GetBranch gets a branch, and returns nil if it does not exist
+func (c *GitLabClient) GetBranch(name string) (*github.Branch, error) {
+	var b gitLabBranch
+	err := c.do("GET", "repository/branches/"+url.PathEscape(name), nil, nil, &b)
+
+	if restStatus(err) == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get branch: %s", name)
+	}
+
+	return &github.Branch{
+		Name:      github.String(b.Name),
+		Protected: github.Bool(b.Protected),
+		Commit:    &github.RepositoryCommit{SHA: github.String(b.Commit.ID)},
+	}, nil
+}
+
+// CreateNewBranch creates a new branch from the head of the origin
+func (c *GitLabClient) CreateNewBranch(origin, new string) error {
+	if len(origin) == 0 {
+		return errors.New("missing GitLab origin branch name")
+	}
+
+	if len(new) == 0 {
+		return errors.New("missing GitLab branch name")
+	}
+
+	q := url.Values{"branch": {new}, "ref": {origin}}
+
+	if err := c.do("POST", "repository/branches", q, nil, nil); err != nil {
+		return errors.Wrapf(err, "failed to create a new branch %s from %s", new, origin)
+	}
+
+	return nil
+}
+
+// DeleteLatestRef deletes the branch, intended to be used for rollbacks
+func (c *GitLabClient) DeleteLatestRef(branch string) error {
+	if len(branch) == 0 {
+		return errors.New("missing GitLab branch name")
+	}
+
+	if err := c.do("DELETE", "repository/branches/"+url.PathEscape(branch), nil, nil, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete branch %s", branch)
+	}
+
+	return nil
+}
+
+// GetFile gets the latest file of the branch. The blob ID of the file stands for the SHA
+func (c *GitLabClient) GetFile(branch, path string) (*github.RepositoryContent, error) {
+	f, err := c.getFile(branch, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &github.RepositoryContent{
+		Type:     github.String("file"),
+		Name:     github.String(f.FileName),
+		Path:     github.String(f.FilePath),
+		Size:     github.Int(f.Size),
+		Encoding: github.String(f.Encoding),
+		Content:  github.String(f.Content),
+		SHA:      github.String(f.BlobID),
+	}, nil
+}
+
+func (c *GitLabClient) getFile(branch, path string) (*gitLabFile, error) {
+	if len(branch) == 0 {
+		return nil, errors.New("missing GitLab branch name")
+	}
+
+	if len(path) == 0 {
+		return nil, errors.New("missing GitLab file path")
+	}
+
+	var f gitLabFile
+	err := c.do("GET", "repository/files/"+url.PathEscape(path), url.Values{"ref": {branch}}, nil, &f)
+
+	if restStatus(err) == http.StatusNotFound {
+		return nil, &NotFoundError{What: fmt.Sprintf("%s of branch %s", path, branch)}
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get file: path: %s", path)
+	}
+
+	return &f, nil
+}
+
+// ListFiles lists names of files and directories in a directory of the branch, dir is empty for the root
+func (c *GitLabClient) ListFiles(branch, dir string) ([]string, error) {
+	if len(branch) == 0 {
+		return nil, errors.New("missing GitLab branch name")
+	}
+
+	q := url.Values{"ref": {branch}, "per_page": {"100"}}
+
+	if len(dir) != 0 {
+		q.Set("path", dir)
+	}
+
+	var entries []struct {
+		Name string `json:"name"`
+	}
+
+	if err := c.do("GET", "repository/tree", q, nil, &entries); err != nil {
+		return nil, errors.Wrapf(err, "failed to list files: directory: %s", dir)
+	}
+
+	var names []string
+
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+
+	return names, nil
+}
+
+// UpdateFiles updates several files of the branch in a single commit. Files which do not exist are created.
+// Each update carries the last commit of the file, so GitLab rejects it with ErrRefMoved if the file has changed in the meantime
+func (c *GitLabClient) UpdateFiles(branch, message string, files map[string][]byte) (string, error) {
+	if len(branch) == 0 {
+		return "", errors.New("missing GitLab branch name")
+	}
+
+	if len(message) == 0 {
+		return "", errors.New("missing GitLab commit message")
+	}
+
+	if len(files) == 0 {
+		return "", errors.New("missing GitLab files to update")
+	}
+
+	var paths []string
+
+	for p := range files {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	var actions []map[string]string
+
+	for _, p := range paths {
+		action := map[string]string{"action": "create", "file_path": p, "content": string(files[p])}
+		f, err := c.getFile(branch, p)
+
+		if err != nil && !isNotFound(err) {
+			return "", err
+		}
+
+		if err == nil {
+			action["action"], action["last_commit_id"] = "update", f.LastCommitID
+		}
+
+		actions = append(actions, action)
+	}
+
+	in := map[string]interface{}{"branch": branch, "commit_message": message, "actions": actions}
+
+	var commit gitLabCommit
+	err := c.do("POST", "repository/commits", nil, in, &commit)
+
+	if e, ok := errors.Cause(err).(*RESTError); ok && e.StatusCode == http.StatusBadRequest && strings.Contains(e.Message, "changed since") {
+		return "", errors.Wrapf(ErrRefMoved, "branch name: %s: %s", branch, e.Message)
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create a new commit: branch name: %s", branch)
+	}
+
+	return commit.ID, nil
+}
+
+// CreatePullRequest opens a merge request from head to base
+func (c *GitLabClient) CreatePullRequest(title, head, base, body string) (*github.PullRequest, error) {
+	if len(title) == 0 {
+		return nil, errors.New("missing GitLab merge request title")
+	}
+
+	if len(head) == 0 {
+		return nil, errors.New("missing GitLab merge request source branch")
+	}
+
+	if len(base) == 0 {
+		return nil, errors.New("missing GitLab merge request target branch")
+	}
+
+	if len(body) == 0 {
+		return nil, errors.New("missing GitLab merge request description")
+	}
+
+	in := map[string]interface{}{
+		"title":                title,
+		"source_branch":        head,
+		"target_branch":        base,
+		"description":          body,
+		"remove_source_branch": true,
+	}
+
+	var mr gitLabMergeRequest
+
+	if err := c.do("POST", "merge_requests", nil, in, &mr); err != nil {
+		return nil, errors.Wrap(err, "failed to create a new merge request")
+	}
+
+	return mr.pullRequest(), nil
+}
+
+// ClosePullRequest closes a merge request with a given IID
+func (c *GitLabClient) ClosePullRequest(number int) error {
+	in := map[string]string{"state_event": "close"}
+
+	if err := c.do("PUT", "merge_requests/"+strconv.Itoa(number), nil, in, nil); err != nil {
+		return errors.Wrapf(err, "failed to close merge request !%d", number)
+	}
+
+	return nil
+}
+
+// MergeCommit returns the commit a merge request is merged with, that is the merge commit, the squashed commit, or
+// the head of the merge request if it is fast-forwarded. It returns an empty string if the merge request is still open
+func (c *GitLabClient) MergeCommit(number int) (string, error) {
+	var mr gitLabMergeRequest
+
+	if err := c.do("GET", "merge_requests/"+strconv.Itoa(number), nil, nil, &mr); err != nil {
+		return "", errors.Wrapf(err, "failed to get merge request !%d", number)
+	}
+
+	switch mr.State {
+	case "merged":
+	case "closed":
+		return "", errors.Errorf("merge request !%d is closed without being merged", number)
+	default:
+		return "", nil
+	}
+
+	for _, sha := range []string{mr.MergeCommitSHA, mr.SquashCommitSHA, mr.SHA} {
+		if len(sha) != 0 {
+			return sha, nil
+		}
+	}
+
+	return "", errors.Errorf("merge request !%d is merged without a commit", number)
+}
+
+// ListOpenPullRequests lists open merge requests to the base branch
+func (c *GitLabClient) ListOpenPullRequests(base string) ([]*github.PullRequest, error) {
+	q := url.Values{"state": {"opened"}, "target_branch": {base}, "per_page": {"100"}}
+
+	var mrs []*gitLabMergeRequest
+
+	if err := c.do("GET", "merge_requests", q, nil, &mrs); err != nil {
+		return nil, errors.Wrapf(err, "failed to list open merge requests: target branch: %s", base)
+	}
+
+	return pullRequests(mrs, ""), nil
+}
+
+// ListMergedPullRequestsWithCommit lists merged merge requests which contain a given commit
+func (c *GitLabClient) ListMergedPullRequestsWithCommit(sha string) ([]*github.PullRequest, error) {
+	if len(sha) == 0 {
+		return nil, errors.New("missing GitLab commit sha")
+	}
+
+	var mrs []*gitLabMergeRequest
+
+	if err := c.do("GET", "repository/commits/"+url.PathEscape(sha)+"/merge_requests", nil, nil, &mrs); err != nil {
+		return nil, errors.Wrapf(err, "failed to list merge requests: commit: %s", sha)
+	}
+
+	return pullRequests(mrs, "merged"), nil
+}
+
+// CreateRelease creates a new release, which tags targetCommitish right away as GitLab cannot draft releases.
+// gemer calls it only after the merge request is merged, see releaseOnMerge
+func (c *GitLabClient) CreateRelease(tagName, targetCommitish, name, body string) (*github.RepositoryRelease, error) {
+	if len(tagName) == 0 {
+		return nil, errors.New("missing GitLab release tag name")
+	}
+
+	if len(targetCommitish) == 0 {
+		return nil, errors.New("missing GitLab release ref")
+	}
+
+	if len(name) == 0 {
+		return nil, errors.New("missing GitLab release name")
+	}
+
+	if len(body) == 0 {
+		return nil, errors.New("missing GitLab release description")
+	}
+
+	in := map[string]string{"tag_name": tagName, "ref": targetCommitish, "name": name, "description": body}
+
+	var r gitLabRelease
+
+	if err := c.do("POST", "releases", nil, in, &r); err != nil {
+		return nil, errors.Wrap(err, "failed to create a new release")
+	}
+
+	return c.release(&r), nil
+}
+
+// DeleteRelease deletes the release of the tag and the tag itself, which GitLab has created with the release.
+// GitLab has no release IDs, so id is not used
+func (c *GitLabClient) DeleteRelease(id int64, tag string) error {
+	if len(tag) == 0 {
+		return errors.New("missing GitLab release tag name")
+	}
+
+	if err := c.do("DELETE", "releases/"+url.PathEscape(tag), nil, nil, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete release %s", tag)
+	}
+
+	if err := c.do("DELETE", "repository/tags/"+url.PathEscape(tag), nil, nil, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete tag %s", tag)
+	}
+
+	return nil
+}
+
+// FindRelease finds a release of the tag, and returns nil if there is none
+func (c *GitLabClient) FindRelease(tag string) (*github.RepositoryRelease, error) {
+	var r gitLabRelease
+	err := c.do("GET", "releases/"+url.PathEscape(tag), nil, nil, &r)
+
+	if restStatus(err) == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get release %s", tag)
+	}
+
+	return c.release(&r), nil
+}
+
+// LatestReleaseTag returns the tag of the latest release, or an empty string if there is none
+func (c *GitLabClient) LatestReleaseTag() (string, error) {
+	q := url.Values{"order_by": {"released_at"}, "sort": {"desc"}, "per_page": {"1"}}
+
+	var releases []*gitLabRelease
+
+	if err := c.do("GET", "releases", q, nil, &releases); err != nil {
+		return "", errors.Wrap(err, "failed to list releases")
+	}
+
+	if len(releases) == 0 {
+		return "", nil
+	}
+
+	return releases[0].TagName, nil
+}
+
+// TagExists returns true if the tag exists
+func (c *GitLabClient) TagExists(tag string) (bool, error) {
+	err := c.do("GET", "repository/tags/"+url.PathEscape(tag), nil, nil, nil)
+
+	if restStatus(err) == http.StatusNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get tag: %s", tag)
+	}
+
+	return true, nil
+}
+
+// CompareCommits lists commits from base, which is a tag or a branch, to head
+func (c *GitLabClient) CompareCommits(base, head string) (*ComparedCommits, error) {
+	if len(base) == 0 {
+		return nil, errors.New("missing GitLab base commit")
+	}
+
+	if len(head) == 0 {
+		return nil, errors.New("missing GitLab head commit")
+	}
+
+	var compare struct {
+		Commits []*gitLabCommit `json:"commits"`
+	}
+
+	if err := c.do("GET", "repository/compare", url.Values{"from": {base}, "to": {head}}, nil, &compare); err != nil {
+		return nil, errors.Wrapf(err, "failed to compare %s with %s", head, base)
+	}
+
+	var ccs []*ComparedCommit
+
+	for _, cm := range compare.Commits {
+		ccs = append(ccs, &ComparedCommit{SHA: cm.ID, Author: cm.AuthorName, Message: cm.Message, HTMLURL: cm.WebURL, Merge: len(cm.ParentIDs) > 1})
+	}
+
+	return &ComparedCommits{Commits: ccs}, nil
+}
+
+// do sends a request to an endpoint of the project, e.g. `repository/branches`, see restClient
+func (c *GitLabClient) do(method, path string, query url.Values, in, out interface{}) error {
+	rc := &restClient{
+		HTTPClient:   c.HTTPClient,
+		Prefix:       c.BaseURL.String() + "projects/" + url.PathEscape(c.Namespace+"/"+c.Project),
+		AuthHeader:   "PRIVATE-TOKEN",
+		AuthValue:    c.Token,
+		ErrorMessage: gitLabErrorMessage,
+	}
+
+	return rc.do(method, path, query, in, out)
+}
+
+// gitLabErrorMessage reads an error response, whose message is a string, or an object of fields and their errors
+func gitLabErrorMessage(content []byte) string {
+	var body struct {
+		Message interface{} `json:"message"`
+		Error   string      `json:"error"`
+	}
+
+	if json.Unmarshal(content, &body) != nil {
+		return ""
+	}
+
+	switch m := body.Message.(type) {
+	case string:
+		return m
+	case nil:
+		return body.Error
+	default:
+		b, _ := json.Marshal(m)
+		return string(b)
+	}
+}
+
+// pullRequest converts the merge request into a pull request, whose number is the IID of the merge request
+func (mr *gitLabMergeRequest) pullRequest() *github.PullRequest {
+	pr := &github.PullRequest{
+		Number:   github.Int(mr.IID),
+		Title:    github.String(mr.Title),
+		State:    github.String(mr.State),
+		HTMLURL:  github.String(mr.WebURL),
+		Head:     &github.PullRequestBranch{Ref: github.String(mr.SourceBranch), SHA: github.String(mr.SHA)},
+		Base:     &github.PullRequestBranch{Ref: github.String(mr.TargetBranch)},
+		User:     &github.User{Login: github.String(mr.Author.Username)},
+		MergedAt: mr.MergedAt,
+	}
+
+	for _, l := range mr.Labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(l)})
+	}
+
+	return pr
+}
+
+// pullRequests converts the merge requests in the state into pull requests, state is empty for any state
+func pullRequests(mrs []*gitLabMergeRequest, state string) []*github.PullRequest {
+	var prs []*github.PullRequest
+
+	for _, mr := range mrs {
+		if len(state) == 0 || mr.State == state {
+			prs = append(prs, mr.pullRequest())
+		}
+	}
+
+	return prs
+}
+
+// release converts a GitLab release into a published release, as GitLab has no drafts
+func (c *GitLabClient) release(r *gitLabRelease) *github.RepositoryRelease {
+	htmlURL := r.Links.Self
+
+	// Old versions of GitLab do not have links of releases
+	if len(htmlURL) == 0 {
+		web := *c.BaseURL
+		web.Path = strings.TrimSuffix(web.Path, "api/v4/") + c.Namespace + "/" + c.Project + "/-/releases/" + r.TagName
+		htmlURL = web.String()
+	}
+
+	return &github.RepositoryRelease{
+		TagName: github.String(r.TagName),
+		Name:    github.String(r.Name),
+		Body:    github.String(r.Description),
+		Draft:   github.Bool(false),
+		HTMLURL: github.String(htmlURL),
+	}
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// The samples below follow the example responses of GitLab API v4 docs, trimmed and renamed to project o/r
+
+const gitLabBranchSample = `{
+  "name": "master",
+  "merged": false,
+  "protected": true,
+  "default": true,
+  "developers_can_push": false,
+  "developers_can_merge": false,
+  "can_push": true,
+  "web_url": "https://gitlab.example.com/o/r/-/tree/master",
+  "commit": {
+    "id": "7b5c3cc8be40ee161ae89a06bba6229da1032a0c",
+    "short_id": "7b5c3cc",
+    "title": "add projects API",
+    "message": "add projects API",
+    "parent_ids": ["4ad91d3c1144c406e50c7b33bae684bd6837faf8"]
+  }
+}`
+
+const gitLabFileSample = `{
+  "file_name": "version.rb",
+  "file_path": "lib/r/version.rb",
+  "size": 34,
+  "encoding": "base64",
+  "content": "bW9kdWxlIFIKICBWRVJTSU9OID0gJzAuMS4wJwplbmQK",
+  "ref": "master",
+  "blob_id": "79f7bbd25901e8334750839545a9bd021f0e4c83",
+  "commit_id": "d5a3ff139356ce33e37e73add446f16869741b50",
+  "last_commit_id": "570e7b2abdd848b95f2f578043fc23bd6f6fd24d"
+}`
+
+const gitLabTreeSample = `[
+  {"id": "a1e8f8d745cc87e3a9248358d9352bb7f9a0aeba", "name": "lib", "type": "tree", "path": "lib", "mode": "040000"},
+  {"id": "4535904260b1082e14f867f7a24fd8c21495bde3", "name": "r.gemspec", "type": "blob", "path": "r.gemspec", "mode": "100644"}
+]`
+
+const gitLabCommitSample = `{
+  "id": "ed899a2f4b50b4370feeea94676502b42383c746",
+  "short_id": "ed899a2f4b5",
+  "title": "Bumps up to 0.1.1",
+  "author_name": "Shuhei Kitagawa",
+  "author_email": "shuheiktgw@example.com",
+  "message": "Bumps up to 0.1.1",
+  "parent_ids": ["7b5c3cc8be40ee161ae89a06bba6229da1032a0c"],
+  "web_url": "https://gitlab.example.com/o/r/-/commit/ed899a2f4b50b4370feeea94676502b42383c746"
+}`
+
+// gitLabMergeRequestSample is a merge request in state, whose merge_commit_sha is given as a JSON value
+const gitLabMergeRequestSample = `{
+  "id": 84,
+  "iid": %d,
+  "project_id": 3,
+  "title": "%s",
+  "description": "",
+  "state": "%s",
+  "merged_at": %s,
+  "target_branch": "master",
+  "source_branch": "%s",
+  "author": {"id": 1, "name": "Shuhei Kitagawa", "username": "shuheiktgw", "state": "active"},
+  "labels": ["bug"],
+  "sha": "8888888888888888888888888888888888888888",
+  "merge_commit_sha": %s,
+  "squash_commit_sha": null,
+  "web_url": "https://gitlab.example.com/o/r/-/merge_requests/%[1]d"
+}`
+
+func testGitLabMergeRequest(iid int, title, state, source string) string {
+	mergedAt, mergeCommit := "null", "null"
+
+	if state == "merged" {
+		mergedAt, mergeCommit = `"2018-09-07T11:16:17.520Z"`, `"9999999999999999999999999999999999999999"`
+	}
+
+	return fmt.Sprintf(gitLabMergeRequestSample, iid, title, state, mergedAt, source, mergeCommit)
+}
+
+const gitLabReleaseSample = `{
+  "tag_name": "v0.1.1",
+  "description": "Bumps up to 0.1.1",
+  "name": "v0.1.1",
+  "created_at": "2019-01-03T01:56:19.539Z",
+  "released_at": "2019-01-03T01:56:19.539Z",
+  "upcoming_release": false,
+  "_links": {"self": "https://gitlab.example.com/o/r/-/releases/v0.1.1"}
+}`
+
+const gitLabCompareSample = `{
+  "commit": {"id": "12d65c8dd2b2676fa3ac47d955accc085a37a9c1", "title": "Fix bar"},
+  "commits": [
+    {
+      "id": "12d65c8dd2b2676fa3ac47d955accc085a37a9c1",
+      "short_id": "12d65c8dd2b",
+      "title": "Add foo",
+      "author_name": "Shuhei Kitagawa",
+      "message": "Add foo",
+      "parent_ids": ["7b5c3cc8be40ee161ae89a06bba6229da1032a0c"],
+      "web_url": "https://gitlab.example.com/o/r/-/commit/12d65c8dd2b2676fa3ac47d955accc085a37a9c1"
+    },
+    {
+      "id": "9999999999999999999999999999999999999999",
+      "short_id": "99999999999",
+      "title": "Merge branch 'fix-bar' into 'master'",
+      "author_name": "Shuhei Kitagawa",
+      "message": "Merge branch 'fix-bar' into 'master'",
+      "parent_ids": ["12d65c8dd2b2676fa3ac47d955accc085a37a9c1", "8888888888888888888888888888888888888888"],
+      "web_url": "https://gitlab.example.com/o/r/-/commit/9999999999999999999999999999999999999999"
+    }
+  ],
+  "diffs": [],
+  "compare_timeout": false,
+  "compare_same_ref": false
+}`
+
+// testGitLabClient returns a client of project o/r on a server serving routes, see testRESTServer
+func testGitLabClient(routes map[string]restResponse) (*GitLabClient, *[]*restRequest, func()) {
+	server, requests := testRESTServer("/api/v4/projects/o%2Fr", routes)
+	c, _ := NewGitLabClient("o", "r", "glpat-test", server.URL)
+
+	return c, requests, server.Close
+}
+
+func TestNewGitLabClientFail(t *testing.T) {
+	cases := []struct {
+		namespace, project, token, apiURL string
+	}{
+		{namespace: "", project: "r", token: "glpat-test"},
+		{namespace: "o", project: "", token: "glpat-test"},
+		{namespace: "o", project: "r", token: ""},
+		{namespace: "o", project: "r", token: "glpat-test", apiURL: "gitlab.example.com"},
+	}
+
+	for i, tc := range cases {
+		if _, err := NewGitLabClient(tc.namespace, tc.project, tc.token, tc.apiURL); err == nil {
+			t.Fatalf("#%d NewGitLabClient is supposed to fail", i)
+		}
+	}
+}
+
+func TestGitLabAPIURL(t *testing.T) {
+	cases := []struct {
+		apiURL, want, wantHost string
+	}{
+		{apiURL: "", want: "https://gitlab.com/api/v4/", wantHost: "gitlab.com"},
+		{apiURL: "https://gitlab.example.com", want: "https://gitlab.example.com/api/v4/", wantHost: "gitlab.example.com"},
+		{apiURL: "https://gitlab.example.com/api/v4", want: "https://gitlab.example.com/api/v4/", wantHost: "gitlab.example.com"},
+		{apiURL: "http://example.com/gitlab/", want: "http://example.com/gitlab/api/v4/", wantHost: "example.com"},
+	}
+
+	for i, tc := range cases {
+		u, err := restAPIURL(tc.apiURL, GitLabAPIURL, "api/v4/")
+
+		if err != nil {
+			t.Fatalf("#%d restAPIURL failed: %s", i, err)
+		}
+
+		if u.String() != tc.want {
+			t.Fatalf("#%d invalid url: want: %s, got: %s", i, tc.want, u)
+		}
+
+		if got := restWebHost(tc.apiURL, GitLabHost); got != tc.wantHost {
+			t.Fatalf("#%d invalid host: want: %s, got: %s", i, tc.wantHost, got)
+		}
+	}
+}
+
+func TestGitLabErrorMessage(t *testing.T) {
+	cases := []struct {
+		body, want string
+	}{
+		{body: `{"message": "404 Branch Not Found"}`, want: "404 Branch Not Found"},
+		{body: `{"message": {"source_branch": ["is invalid"]}}`, want: `{"source_branch":["is invalid"]}`},
+		{body: `{"error": "insufficient_scope"}`, want: "insufficient_scope"},
+		{body: `<html>Bad Gateway</html>`, want: "502 Bad Gateway"},
+	}
+
+	for i, tc := range cases {
+		res := &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Body: ioutil.NopCloser(strings.NewReader(tc.body))}
+
+		if e := (&restClient{ErrorMessage: gitLabErrorMessage}).newError("GET", "repository/branches", res); e.Message != tc.want {
+			t.Fatalf("#%d invalid message: want: %q, got: %q", i, tc.want, e.Message)
+		}
+	}
+}
+
+func TestGitLabClientMissingPermissions(t *testing.T) {
+	cases := []struct {
+		project restResponse
+		want    string
+	}{
+		{project: restResponse{body: `{"id": 3, "permissions": {"project_access": {"access_level": 40, "notification_level": 3}, "group_access": null}}`}},
+		{project: restResponse{body: `{"id": 3, "permissions": {"project_access": {"access_level": 10, "notification_level": 3}, "group_access": {"access_level": 30, "notification_level": 3}}}`}},
+		{project: restResponse{body: `{"id": 3, "permissions": {"project_access": {"access_level": 20, "notification_level": 3}, "group_access": null}}`}, want: "Developer role on o/r"},
+		{project: restResponse{status: http.StatusNotFound, body: `{"message": "404 Project Not Found"}`}, want: "access to o/r"},
+	}
+
+	for i, tc := range cases {
+		c, _, done := testGitLabClient(map[string]restResponse{"GET ": tc.project})
+		missing, err := c.MissingPermissions()
+		done()
+
+		if err != nil {
+			t.Fatalf("#%d MissingPermissions failed: %s", i, err)
+		}
+
+		if got := strings.Join(missing, "\n"); !strings.HasPrefix(got, tc.want) || (len(tc.want) == 0) != (len(got) == 0) {
+			t.Fatalf("#%d invalid missing permissions: want: %q, got: %q", i, tc.want, missing)
+		}
+	}
+}
+
+func TestGitLabClientBranches(t *testing.T) {
+	c, requests, done := testGitLabClient(map[string]restResponse{
+		"GET /repository/branches/master":           {body: gitLabBranchSample},
+		"POST /repository/branches":                 {status: http.StatusCreated, body: gitLabBranchSample},
+		"DELETE /repository/branches/feature%2Fnew": {status: http.StatusNoContent},
+	})
+	defer done()
+
+	b, err := c.GetBranch("master")
+
+	if err != nil || b.GetName() != "master" || !b.GetProtected() || b.GetCommit().GetSHA() != "7b5c3cc8be40ee161ae89a06bba6229da1032a0c" {
+		t.Fatalf("invalid branch: %+v, %v", b, err)
+	}
+
+	if b, err := c.GetBranch("unknown"); err != nil || b != nil {
+		t.Fatalf("GetBranch is supposed to return nil for an unknown branch: %+v, %v", b, err)
+	}
+
+	if err := c.CreateNewBranch("master", "feature/new"); err != nil {
+		t.Fatalf("CreateNewBranch failed: %s", err)
+	}
+
+	if q := (*requests)[2].query; q.Get("branch") != "feature/new" || q.Get("ref") != "master" {
+		t.Fatalf("invalid query: %v", q)
+	}
+
+	if err := c.DeleteLatestRef("feature/new"); err != nil {
+		t.Fatalf("DeleteLatestRef failed: %s", err)
+	}
+
+	failures := []struct {
+		origin, new string
+	}{
+		{origin: "", new: "new"},
+		{origin: "master", new: ""},
+	}
+
+	for i, tc := range failures {
+		if err := c.CreateNewBranch(tc.origin, tc.new); err == nil {
+			t.Fatalf("#%d CreateNewBranch is supposed to fail", i)
+		}
+	}
+
+	if err := c.DeleteLatestRef("unknown"); err == nil {
+		t.Fatalf("DeleteLatestRef is supposed to fail for an unknown branch")
+	}
+}
+
+func TestGitLabClientFiles(t *testing.T) {
+	c, requests, done := testGitLabClient(map[string]restResponse{
+		"GET /repository/files/lib%2Fr%2Fversion.rb": {body: gitLabFileSample},
+		"GET /repository/tree":                       {body: gitLabTreeSample},
+	})
+	defer done()
+
+	rc, err := c.GetFile("master", "lib/r/version.rb")
+
+	if err != nil {
+		t.Fatalf("GetFile failed: %s", err)
+	}
+
+	content, err := decodeContent(rc)
+
+	if err != nil || content != "module R\n  VERSION = '0.1.0'\nend\n" || rc.GetName() != "version.rb" || rc.GetSHA() != "79f7bbd25901e8334750839545a9bd021f0e4c83" {
+		t.Fatalf("invalid file: %+v: %q", rc, content)
+	}
+
+	if q := (*requests)[0].query; q.Get("ref") != "master" {
+		t.Fatalf("invalid query: %v", q)
+	}
+
+	if _, err := c.GetFile("master", "unknown"); !isNotFound(err) {
+		t.Fatalf("GetFile is supposed to fail with not found: %v", err)
+	}
+
+	names, err := c.ListFiles("master", "")
+
+	if want := []string{"lib", "r.gemspec"}; err != nil || fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("invalid files: want: %q, got: %q, %v", want, names, err)
+	}
+
+	if _, err := c.ListFiles("master", "lib/r"); err != nil {
+		t.Fatalf("ListFiles failed: %s", err)
+	}
+
+	if q := (*requests)[3].query; q.Get("path") != "lib/r" || q.Get("ref") != "master" {
+		t.Fatalf("invalid query: %v", q)
+	}
+}
+
+func TestGitLabClientUpdateFiles(t *testing.T) {
+	routes := map[string]restResponse{
+		"GET /repository/files/lib%2Fr%2Fversion.rb": {body: gitLabFileSample},
+		"POST /repository/commits":                   {status: http.StatusCreated, body: gitLabCommitSample},
+	}
+	c, requests, done := testGitLabClient(routes)
+	defer done()
+
+	files := map[string][]byte{
+		"lib/r/version.rb": []byte("module R\n  VERSION = '0.1.1'\nend\n"),
+		"CHANGELOG.md":     []byte("# Changelog\n"),
+	}
+
+	sha, err := c.UpdateFiles("bump", "Bumps up to 0.1.1", files)
+
+	if err != nil {
+		t.Fatalf("UpdateFiles failed: %s", err)
+	}
+
+	if sha != "ed899a2f4b50b4370feeea94676502b42383c746" {
+		t.Fatalf("invalid commit: %s", sha)
+	}
+
+	// The files are looked up to tell creations from updates, which carry the last commits of them
+	want := `[map[action:create content:# Changelog
+ file_path:CHANGELOG.md] map[action:update content:module R
+  VERSION = '0.1.1'
+end
+ file_path:lib/r/version.rb last_commit_id:570e7b2abdd848b95f2f578043fc23bd6f6fd24d]]`
+
+	if body := (*requests)[2].body; fmt.Sprint(body["actions"]) != want || body["branch"] != "bump" || body["commit_message"] != "Bumps up to 0.1.1" {
+		t.Fatalf("invalid commit: %v", body)
+	}
+
+	routes["POST /repository/commits"] = restResponse{status: http.StatusBadRequest, body: `{"message": "You are attempting to update a file that has changed since you started editing it."}`}
+
+	if _, err := c.UpdateFiles("bump", "Bumps up to 0.1.1", files); errors.Cause(err) != ErrRefMoved {
+		t.Fatalf("UpdateFiles is supposed to fail with ErrRefMoved: %v", err)
+	}
+
+	failures := []struct {
+		branch, message string
+		files           map[string][]byte
+	}{
+		{branch: "", message: "Bumps up to 0.1.1", files: files},
+		{branch: "bump", message: "", files: files},
+		{branch: "bump", message: "Bumps up to 0.1.1", files: nil},
+	}
+
+	for i, tc := range failures {
+		if _, err := c.UpdateFiles(tc.branch, tc.message, tc.files); err == nil {
+			t.Fatalf("#%d UpdateFiles is supposed to fail", i)
+		}
+	}
+}
+
+func TestGitLabClientMergeRequests(t *testing.T) {
+	c, requests, done := testGitLabClient(map[string]restResponse{
+		"POST /merge_requests":  {status: http.StatusCreated, body: testGitLabMergeRequest(3, "Bumps up to 0.1.1", "opened", "bump")},
+		"GET /merge_requests":   {body: "[" + testGitLabMergeRequest(3, "Bumps up to 0.1.1", "opened", "bump") + "]"},
+		"PUT /merge_requests/3": {body: testGitLabMergeRequest(3, "Bumps up to 0.1.1", "closed", "bump")},
+		"GET /repository/commits/9999999999999999999999999999999999999999/merge_requests": {
+			body: "[" + testGitLabMergeRequest(2, "Fix bar", "merged", "fix-bar") + "," + testGitLabMergeRequest(4, "Fix baz", "opened", "fix-baz") + "]",
+		},
+	})
+	defer done()
+
+	pr, err := c.CreatePullRequest("Bumps up to 0.1.1", "bump", "master", "Bumps up to 0.1.1")
+
+	if err != nil {
+		t.Fatalf("CreatePullRequest failed: %s", err)
+	}
+
+	if pr.GetNumber() != 3 || pr.GetHead().GetRef() != "bump" || pr.GetHTMLURL() != "https://gitlab.example.com/o/r/-/merge_requests/3" || pr.MergedAt != nil {
+		t.Fatalf("invalid merge request: %+v", pr)
+	}
+
+	if body := (*requests)[0].body; body["source_branch"] != "bump" || body["target_branch"] != "master" || body["remove_source_branch"] != true {
+		t.Fatalf("invalid merge request: %v", body)
+	}
+
+	prs, err := c.ListOpenPullRequests("master")
+
+	if err != nil || len(prs) != 1 || prs[0].GetNumber() != 3 {
+		t.Fatalf("invalid open merge requests: %+v, %v", prs, err)
+	}
+
+	if q := (*requests)[1].query; q.Get("state") != "opened" || q.Get("target_branch") != "master" {
+		t.Fatalf("invalid query: %v", q)
+	}
+
+	if err := c.ClosePullRequest(3); err != nil {
+		t.Fatalf("ClosePullRequest failed: %s", err)
+	}
+
+	if body := (*requests)[2].body; body["state_event"] != "close" {
+		t.Fatalf("invalid update: %v", body)
+	}
+
+	if err := c.ClosePullRequest(100); err == nil {
+		t.Fatalf("ClosePullRequest is supposed to fail for an unknown merge request")
+	}
+
+	// Only the merged ones of the merge requests containing the commit are listed
+	merged, err := c.ListMergedPullRequestsWithCommit("9999999999999999999999999999999999999999")
+
+	if err != nil || len(merged) != 1 || merged[0].GetTitle() != "Fix bar" || merged[0].MergedAt == nil || merged[0].GetUser().GetLogin() != "shuheiktgw" {
+		t.Fatalf("invalid merged merge requests: %+v, %v", merged, err)
+	}
+
+	if len(merged[0].Labels) != 1 || merged[0].Labels[0].GetName() != "bug" {
+		t.Fatalf("invalid labels: %+v", merged[0].Labels)
+	}
+
+	if _, err := c.ListMergedPullRequestsWithCommit(""); err == nil {
+		t.Fatalf("ListMergedPullRequestsWithCommit is supposed to fail without a commit")
+	}
+}
+
+func TestGitLabClientMergeCommit(t *testing.T) {
+	cases := []struct {
+		body, want string
+		wantErr    bool
+	}{
+		{body: testGitLabMergeRequest(3, "Bumps up to 0.1.1", "opened", "bump")},
+		{body: testGitLabMergeRequest(3, "Bumps up to 0.1.1", "merged", "bump"), want: "9999999999999999999999999999999999999999"},
+		{body: `{"iid": 3, "state": "merged", "sha": "8888888888", "merge_commit_sha": null, "squash_commit_sha": "7777777777"}`, want: "7777777777"},
+		{body: `{"iid": 3, "state": "merged", "sha": "8888888888", "merge_commit_sha": null, "squash_commit_sha": null}`, want: "8888888888"},
+		{body: testGitLabMergeRequest(3, "Bumps up to 0.1.1", "closed", "bump"), wantErr: true},
+	}
+
+	for i, tc := range cases {
+		c, _, done := testGitLabClient(map[string]restResponse{"GET /merge_requests/3": {body: tc.body}})
+		sha, err := c.MergeCommit(3)
+		done()
+
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("#%d unexpected error: %v", i, err)
+		}
+
+		if sha != tc.want {
+			t.Fatalf("#%d invalid merge commit: want: %q, got: %q", i, tc.want, sha)
+		}
+	}
+}
+
+func TestGitLabClientReleases(t *testing.T) {
+	c, requests, done := testGitLabClient(map[string]restResponse{
+		"GET /releases":                  {body: "[" + gitLabReleaseSample + "]"},
+		"GET /releases/v0.1.1":           {body: gitLabReleaseSample},
+		"POST /releases":                 {status: http.StatusCreated, body: gitLabReleaseSample},
+		"DELETE /releases/v0.1.1":        {body: gitLabReleaseSample},
+		"GET /repository/tags/v0.1.1":    {body: `{"name": "v0.1.1", "target": "9999999999999999999999999999999999999999", "protected": false}`},
+		"DELETE /repository/tags/v0.1.1": {status: http.StatusNoContent},
+	})
+	defer done()
+
+	if latest, err := c.LatestReleaseTag(); err != nil || latest != "v0.1.1" {
+		t.Fatalf("invalid latest release: %s, %v", latest, err)
+	}
+
+	if exists, err := c.TagExists("v0.1.1"); err != nil || !exists {
+		t.Fatalf("tag is supposed to exist: %v", err)
+	}
+
+	if exists, err := c.TagExists("v0.1.2"); err != nil || exists {
+		t.Fatalf("tag is not supposed to exist: %v", err)
+	}
+
+	r, err := c.CreateRelease("v0.1.1", "9999999999999999999999999999999999999999", "v0.1.1", "Bumps up to 0.1.1")
+
+	if err != nil {
+		t.Fatalf("CreateRelease failed: %s", err)
+	}
+
+	if r.GetTagName() != "v0.1.1" || r.GetDraft() || r.GetHTMLURL() != "https://gitlab.example.com/o/r/-/releases/v0.1.1" {
+		t.Fatalf("invalid release: %+v", r)
+	}
+
+	if body := (*requests)[3].body; body["ref"] != "9999999999999999999999999999999999999999" || body["description"] != "Bumps up to 0.1.1" {
+		t.Fatalf("invalid release: %v", body)
+	}
+
+	if found, err := c.FindRelease("v0.1.1"); err != nil || found.GetName() != "v0.1.1" {
+		t.Fatalf("invalid release: %+v, %v", found, err)
+	}
+
+	if found, err := c.FindRelease("v0.1.2"); err != nil || found != nil {
+		t.Fatalf("FindRelease is supposed to return nil for an unknown release: %+v, %v", found, err)
+	}
+
+	*requests = nil
+
+	// The tag which GitLab has created with the release is deleted along with it
+	if err := c.DeleteRelease(0, "v0.1.1"); err != nil {
+		t.Fatalf("DeleteRelease failed: %s", err)
+	}
+
+	if want := []string{"DELETE /releases/v0.1.1", "DELETE /repository/tags/v0.1.1"}; fmt.Sprint(restRoutes(*requests)) != fmt.Sprint(want) {
+		t.Fatalf("invalid requests: want: %q, got: %q", want, restRoutes(*requests))
+	}
+
+	if err := c.DeleteRelease(0, "v0.1.2"); err == nil {
+		t.Fatalf("DeleteRelease is supposed to fail for an unknown release")
+	}
+}
+
+func TestGitLabClientReleaseWithoutLinks(t *testing.T) {
+	// GitLab older than 12.0 does not have links of releases
+	c, _, done := testGitLabClient(map[string]restResponse{"GET /releases/v0.1.1": {body: `{"tag_name": "v0.1.1", "name": "v0.1.1", "description": "Bumps up to 0.1.1"}`}})
+	defer done()
+
+	if r, err := c.FindRelease("v0.1.1"); err != nil || r.GetHTMLURL() != c.BaseURL.Scheme+"://"+c.BaseURL.Host+"/o/r/-/releases/v0.1.1" {
+		t.Fatalf("invalid release: %+v, %v", r, err)
+	}
+}
+
+func TestGitLabClientCompareCommits(t *testing.T) {
+	c, requests, done := testGitLabClient(map[string]restResponse{"GET /repository/compare": {body: gitLabCompareSample}})
+	defer done()
+
+	ccs, err := c.CompareCommits("v0.1.0", "master")
+
+	if err != nil {
+		t.Fatalf("CompareCommits failed: %s", err)
+	}
+
+	if len(ccs.Commits) != 2 || ccs.Commits[0].Message != "Add foo" || ccs.Commits[0].Author != "Shuhei Kitagawa" || ccs.Commits[0].Merge || !ccs.Commits[1].Merge {
+		t.Fatalf("invalid commits: %s", ccs)
+	}
+
+	if q := (*requests)[0].query; q.Get("from") != "v0.1.0" || q.Get("to") != "master" {
+		t.Fatalf("invalid query: %v", q)
+	}
+
+	for i, tc := range []struct{ base, head string }{{base: "", head: "master"}, {base: "v0.1.0", head: ""}} {
+		if _, err := c.CompareCommits(tc.base, tc.head); err == nil {
+			t.Fatalf("#%d CompareCommits is supposed to fail", i)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Steps of a run recorded in a journal, in the order they are done
+const (
+	StepBranch      = "branch"
+	StepCommit      = "commit"
+	StepPullRequest = "pull_request"
+	StepRelease     = "release"
+)
+
+// Journal records a run of gemer step by step, so that it can be resumed or rolled back even after the process is killed.
+// It holds everything the remaining steps need as well as what the completed steps created
+type Journal struct {
+	Owner   string `json:"owner"`
+	Repo    string `json:"repo"`
+	Base    string `json:"base"`
+	Current string `json:"current_version"`
+	Next    string `json:"next_version"`
+
+	// Local tells that the run works on the local repository with LocalClient, which pushes to the remote if Push is set
+	Local bool `json:"local,omitempty"`
+	Push  bool `json:"push,omitempty"`
+
+	// Forge and APIURL tell where the repository is hosted, so that the run is resumed or rolled back on the same forge
+	// wherever gemer runs. They are empty in journals of local runs
+	Forge  string `json:"forge,omitempty"`
+	APIURL string `json:"api_url,omitempty"`
+
+	Templates *RenderedTemplates `json:"templates"`
+	Files     map[string][]byte  `json:"files"`
+
+	// Completed lists the steps done so far
+	Completed []string `json:"completed"`
+
+	CommitSHA  string `json:"commit_sha,omitempty"`
+	PrNumber   int    `json:"pr_number,omitempty"`
+	PrURL      string `json:"pr_url,omitempty"`
+	ReleaseID  int64  `json:"release_id,omitempty"`
+	ReleaseURL string `json:"release_url,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// path is the file of the journal, and the journal is kept only in memory if it is empty
+	path string
+}
+
+// newJournal creates a journal of a version bump, which is saved in dir unless dir is empty
+func newJournal(dir string, f Forge, base string, b *versionBump) *Journal {
+	owner, repo := f.Repository()
+	j := &Journal{Owner: owner, Repo: repo, Base: base, Current: b.Current, Next: b.Next, Templates: b.Rendered, Files: b.Files}
+
+	switch c := f.(type) {
+	case *LocalClient:
+		j.Local, j.Push = true, c.Push
+	case *GitHubClient:
+		j.Forge = ForgeGitHub
+
+		if c.Client != nil {
+			j.APIURL = c.Client.BaseURL.String()
+		}
+	case *GitLabClient:
+		j.Forge, j.APIURL = ForgeGitLab, c.BaseURL.String()
+	case *GiteaClient:
+		j.Forge, j.APIURL = ForgeGitea, c.BaseURL.String()
+	}
+
+	if len(dir) != 0 {
+		j.path = filepath.Join(dir, journalFile(owner, repo, b.Next))
+	}
+
+	return j
+}
+
+// JournalDir returns the directory gemer records journals of runs in, that is $XDG_STATE_HOME/gemer or
+// ~/.local/state/gemer, which is out of the working tree. It returns an empty string if neither is set
+func JournalDir() string {
+	if d := os.Getenv("XDG_STATE_HOME"); len(d) != 0 {
+		return filepath.Join(d, Name)
+	}
+
+	if h := os.Getenv("HOME"); len(h) != 0 {
+		return filepath.Join(h, ".local", "state", Name)
+	}
+
+	return ""
+}
+
+// journalFile returns the name of the journal of a version bump, e.g. shuheiktgw%2Fgemer-0.2.0.json, which escapes
+// the slashes of the owner and the repository so that no two repositories share it
+func journalFile(owner, repo, next string) string {
+	return url.QueryEscape(owner+"/"+repo) + "-" + next + ".json"
+}
+
+// LoadJournal loads a journal from a file
+func LoadJournal(path string) (*Journal, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read a journal")
+	}
+
+	var j Journal
+
+	if err := json.Unmarshal(content, &j); err != nil {
+		return nil, errors.Wrapf(err, "invalid journal %s", path)
+	}
+
+	if len(j.Owner) == 0 || len(j.Repo) == 0 || len(j.Base) == 0 || len(j.Next) == 0 || j.Templates == nil {
+		return nil, errors.Errorf("invalid journal %s: owner, repo, base, next_version and templates are required", path)
+	}
+
+	j.path = path
+
+	return &j, nil
+}
+
+// FindJournal returns the only journal in dir, and fails if there is none or more than one
+func FindJournal(dir string) (string, error) {
+	if len(dir) == 0 {
+		return "", errors.New("no journal is found, as neither XDG_STATE_HOME nor HOME is set")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to look for journals")
+	}
+
+	switch len(paths) {
+	case 0:
+		return "", errors.Errorf("no journal is found in %s", dir)
+	case 1:
+		return paths[0], nil
+	}
+
+	return "", errors.Errorf("more than one journal is found in %s, please choose one of them: %s", dir, strings.Join(paths, ", "))
+}
+
+// Path returns the file of the journal
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Done returns true if the step is completed
+func (j *Journal) Done(step string) bool {
+	for _, s := range j.Completed {
+		if s == step {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Complete marks the step completed and saves the journal
+func (j *Journal) Complete(step string) error {
+	j.Completed = append(j.Completed, step)
+
+	return j.Save()
+}
+
+// Undo marks the step not completed and saves the journal, after what the step created is removed
+func (j *Journal) Undo(step string) error {
+	var completed []string
+
+	for _, s := range j.Completed {
+		if s != step {
+			completed = append(completed, s)
+		}
+	}
+
+	j.Completed = completed
+
+	return j.Save()
+}
+
+// Save writes the journal to its file atomically
+func (j *Journal) Save() error {
+	if len(j.path) == 0 {
+		return nil
+	}
+
+	j.UpdatedAt = time.Now()
+	content, err := json.MarshalIndent(j, "", "  ")
+
+	if err != nil {
+		return errors.Wrap(err, "failed to encode a journal")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create a directory of journals")
+	}
+
+	tmp := j.path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return errors.Wrap(err, "failed to write a journal")
+	}
+
+	return errors.Wrap(os.Rename(tmp, j.path), "failed to write a journal")
+}
+
+// Remove removes the file of the journal, which is called when the run finishes or is rolled back entirely
+func (j *Journal) Remove() error {
+	if len(j.path) == 0 {
+		return nil
+	}
+
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove a journal")
+	}
+
+	return nil
+}
+
+// Result returns what the completed steps created
+func (j *Journal) Result() *UpdateVersionResult {
+	r := &UpdateVersionResult{PrNumber: j.PrNumber, ReleaseID: j.ReleaseID, PrURL: j.PrURL, ReleaseURL: j.ReleaseURL}
+
+	if j.Done(StepBranch) {
+		r.Branch = j.Templates.Branch
+	}
+
+	if j.Done(StepRelease) {
+		r.Tag = "v" + j.Next
+	} else if j.Done(StepPullRequest) {
+		r.ReleasePending = true
+	}
+
+	return r
+}
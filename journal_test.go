@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func testJournal(dir string) *Journal {
+	b := &versionBump{
+		Current:  "0.1.0",
+		Next:     "0.1.1",
+		Files:    map[string][]byte{"lib/r/version.rb": []byte("VERSION = '0.1.1'\n")},
+		Rendered: &RenderedTemplates{Branch: "bumps_up_to_0.1.1", PullRequestTitle: "Bumps up to 0.1.1", PullRequestBody: "Bumps up to 0.1.1", ReleaseName: "v0.1.1", ReleaseBody: "Bumps up to 0.1.1"},
+	}
+
+	return newJournal(dir, &GitHubClient{Owner: "o", Repo: "r"}, "master", b)
+}
+
+// testJournalServer stands in for GitHub API, and records the requests it receives. It fails the requests in fail,
+// which are given as "METHOD /path"
+func testJournalServer(requests *[]string, fail map[string]bool) *httptest.Server {
+	mux := http.NewServeMux()
+	record := func(r *http.Request) {
+		*requests = append(*requests, r.Method+" "+r.URL.Path)
+	}
+
+	mux.HandleFunc("/repos/o/r/pulls", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"number": 3, "html_url": "https://github.com/o/r/pull/3"}`)
+	})
+
+	mux.HandleFunc("/repos/o/r/releases", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 5, "html_url": "https://github.com/o/r/releases/v0.1.1"}`)
+	})
+
+	mux.HandleFunc("/repos/o/r/pulls/3", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		fmt.Fprint(w, `{"number": 3, "state": "closed"}`)
+	})
+
+	deleted := func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	mux.HandleFunc("/repos/o/r/releases/5", deleted)
+	mux.HandleFunc("/repos/o/r/git/refs/heads/bumps_up_to_0.1.1", deleted)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail[r.Method+" "+r.URL.Path] {
+			record(r)
+			http.Error(w, `{"message": "Server Error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func testJournalGemer(server *httptest.Server) *Gemer {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	return &Gemer{Forge: &GitHubClient{Owner: "o", Repo: "r", Client: client}, outStream: new(bytes.Buffer)}
+}
+
+func TestJournalSaveAndLoad(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	j := testJournal(JournalDir())
+
+	if want := filepath.Join(dir, "home", ".local", "state", Name, "o%2Fr-0.1.1.json"); j.Path() != want {
+		t.Fatalf("invalid path: want: %s, got: %s", want, j.Path())
+	}
+
+	j.PrNumber = 3
+
+	if err := j.Complete(StepBranch); err != nil {
+		t.Fatalf("Complete failed: %s", err)
+	}
+
+	if err := j.Complete(StepPullRequest); err != nil {
+		t.Fatalf("Complete failed: %s", err)
+	}
+
+	path, err := FindJournal(JournalDir())
+
+	if err != nil {
+		t.Fatalf("FindJournal failed: %s", err)
+	}
+
+	got, err := LoadJournal(path)
+
+	if err != nil {
+		t.Fatalf("LoadJournal failed: %s", err)
+	}
+
+	if !got.Done(StepBranch) || got.Done(StepCommit) || !got.Done(StepPullRequest) || got.PrNumber != 3 {
+		t.Fatalf("invalid journal: completed: %q, pr: %d", got.Completed, got.PrNumber)
+	}
+
+	if string(got.Files["lib/r/version.rb"]) != "VERSION = '0.1.1'\n" || got.Templates.Branch != "bumps_up_to_0.1.1" {
+		t.Fatalf("invalid journal: files: %q, templates: %+v", got.Files, got.Templates)
+	}
+
+	if r := got.Result(); r.Branch != "bumps_up_to_0.1.1" || r.PrNumber != 3 {
+		t.Fatalf("invalid result: %+v", r)
+	}
+
+	if err := got.Undo(StepBranch); err != nil {
+		t.Fatalf("Undo failed: %s", err)
+	}
+
+	if r := got.Result(); len(r.Branch) != 0 {
+		t.Fatalf("branch is supposed to be undone: %+v", r)
+	}
+
+	if err := got.Remove(); err != nil {
+		t.Fatalf("Remove failed: %s", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("journal is supposed to be removed: %v", err)
+	}
+}
+
+func TestNewJournalForge(t *testing.T) {
+	gitLab, _ := NewGitLabClient("o", "r", "glpat-test", "https://gitlab.example.com")
+	gitea, _ := NewGiteaClient("o", "r", "gitea-test", "")
+	gitHub, _ := NewGitHubClient("o", "r", "ghp_test")
+
+	cases := []struct {
+		forge        Forge
+		kind, apiURL string
+	}{
+		{forge: gitHub, kind: ForgeGitHub, apiURL: "https://api.github.com/"},
+		{forge: gitLab, kind: ForgeGitLab, apiURL: "https://gitlab.example.com/api/v4/"},
+		{forge: gitea, kind: ForgeGitea, apiURL: "https://gitea.com/api/v1/"},
+		{forge: &LocalClient{Dir: "/src/r"}},
+	}
+
+	for i, tc := range cases {
+		j := newJournal("", tc.forge, "master", &versionBump{Next: "0.1.1", Rendered: &RenderedTemplates{}})
+
+		if j.Forge != tc.kind || j.APIURL != tc.apiURL {
+			t.Fatalf("#%d invalid forge: want: %s %s, got: %s %s", i, tc.kind, tc.apiURL, j.Forge, j.APIURL)
+		}
+	}
+}
+
+func TestFindJournalFail(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	testWriteFile(t, filepath.Join(dir, "many", "r-0.1.1.json"), "{}")
+	testWriteFile(t, filepath.Join(dir, "many", "r-0.2.0.json"), "{}")
+
+	cases := []string{
+		filepath.Join(dir, "none"),
+		filepath.Join(dir, "many"),
+	}
+
+	for i, tc := range cases {
+		if _, err := FindJournal(tc); err == nil {
+			t.Fatalf("#%d FindJournal is supposed to fail", i)
+		}
+	}
+}
+
+func TestLoadJournalFail(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	cases := []string{
+		"",
+		"{",
+		`{"owner": "o", "repo": "r"}`,
+	}
+
+	for i, tc := range cases {
+		path := filepath.Join(dir, fmt.Sprintf("%d.json", i))
+
+		if len(tc) != 0 {
+			testWriteFile(t, path, tc)
+		}
+
+		if _, err := LoadJournal(path); err == nil {
+			t.Fatalf("#%d LoadJournal is supposed to fail", i)
+		}
+	}
+}
+
+func TestGemerResume(t *testing.T) {
+	var requests []string
+	server := testJournalServer(&requests, nil)
+	defer server.Close()
+
+	_, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	j := testJournal(JournalDir())
+	j.Completed = []string{StepBranch, StepCommit}
+
+	if err := j.Save(); err != nil {
+		t.Fatalf("Save failed: %s", err)
+	}
+
+	result, err := testJournalGemer(server).Resume(j)
+
+	if err != nil {
+		t.Fatalf("Resume failed: %s", err)
+	}
+
+	if want := []string{"POST /repos/o/r/pulls", "POST /repos/o/r/releases"}; fmt.Sprint(requests) != fmt.Sprint(want) {
+		t.Fatalf("invalid requests: want: %q, got: %q", want, requests)
+	}
+
+	if result.Branch != "bumps_up_to_0.1.1" || result.PrNumber != 3 || result.ReleaseID != 5 {
+		t.Fatalf("invalid result: %+v", result)
+	}
+
+	if _, err := os.Stat(j.Path()); !os.IsNotExist(err) {
+		t.Fatalf("journal is supposed to be removed: %v", err)
+	}
+}
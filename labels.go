@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// SemverLabels maps labels of pull requests to versions to increment
+var SemverLabels = map[string]int{
+	"semver:major": MajorVersion,
+	"semver:minor": MinorVersion,
+	"semver:patch": PatchVersion,
+}
+
+// inferVersionFromLabels infers the version to increment from semver labels of pull requests, and returns
+// the pull requests which drove the decision as well. It fails if any pull request does not have a semver label
+func inferVersionFromLabels(prs []*github.PullRequest) (int, []*github.PullRequest, error) {
+	if len(prs) == 0 {
+		return 0, nil, errors.New("failed to infer version: no merged pull request found since the last release")
+	}
+
+	version := -1
+	var drivers []*github.PullRequest
+	var unlabeled []string
+
+	for _, pr := range prs {
+		v := pullRequestVersion(pr)
+
+		switch {
+		case v < 0:
+			unlabeled = append(unlabeled, fmt.Sprintf("#%d", pr.GetNumber()))
+		case version < 0 || v < version:
+			version = v
+			drivers = []*github.PullRequest{pr}
+		case v == version:
+			drivers = append(drivers, pr)
+		}
+	}
+
+	if len(unlabeled) != 0 {
+		return 0, nil, errors.Errorf("failed to infer version: pull requests without a semver label: %s", strings.Join(unlabeled, ", "))
+	}
+
+	return version, drivers, nil
+}
+
+// pullRequestVersion returns the highest version of semver labels of a pull request, or -1 if it has none
+func pullRequestVersion(pr *github.PullRequest) int {
+	version := -1
+
+	for _, l := range pr.Labels {
+		v, ok := SemverLabels[strings.ToLower(l.GetName())]
+
+		if ok && (version < 0 || v < version) {
+			version = v
+		}
+	}
+
+	return version
+}
+
+// formatPullRequests formats pull requests one per line for messages
+func formatPullRequests(prs []*github.PullRequest) string {
+	var lines []string
+
+	for _, pr := range prs {
+		lines = append(lines, fmt.Sprintf("#%d [%s](%s)", pr.GetNumber(), pr.GetTitle(), pr.GetHTMLURL()))
+	}
+
+	return strings.Join(lines, "\n")
+}
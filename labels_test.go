@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func testPullRequest(number int, labels ...string) *github.PullRequest {
+	pr := &github.PullRequest{Number: github.Int(number), Title: github.String("PR"), HTMLURL: github.String("https://github.com/o/r/pull/1")}
+
+	for _, l := range labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(l)})
+	}
+
+	return pr
+}
+
+func TestInferVersionFromLabelsSuccess(t *testing.T) {
+	cases := []struct {
+		prs           []*github.PullRequest
+		want, drivers int
+	}{
+		{prs: []*github.PullRequest{testPullRequest(1, "semver:patch")}, want: PatchVersion, drivers: 1},
+		{prs: []*github.PullRequest{testPullRequest(1, "semver:patch"), testPullRequest(2, "bug", "semver:minor"), testPullRequest(3, "semver:minor")}, want: MinorVersion, drivers: 2},
+		{prs: []*github.PullRequest{testPullRequest(1, "semver:minor"), testPullRequest(2, "Semver:Major")}, want: MajorVersion, drivers: 1},
+		{prs: []*github.PullRequest{testPullRequest(1, "semver:patch", "semver:major")}, want: MajorVersion, drivers: 1},
+	}
+
+	for i, tc := range cases {
+		got, drivers, err := inferVersionFromLabels(tc.prs)
+
+		if err != nil {
+			t.Fatalf("#%d inferVersionFromLabels failed: %s", i, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid version: want: %s, got: %s", i, versionName(tc.want), versionName(got))
+		}
+
+		if len(drivers) != tc.drivers {
+			t.Fatalf("#%d invalid number of drivers: want: %d, got: %d", i, tc.drivers, len(drivers))
+		}
+	}
+}
+
+func TestInferVersionFromLabelsFail(t *testing.T) {
+	cases := [][]*github.PullRequest{
+		nil,
+		{testPullRequest(1, "semver:minor"), testPullRequest(2, "bug")},
+		{testPullRequest(1)},
+	}
+
+	for i, tc := range cases {
+		if _, _, err := inferVersionFromLabels(tc); err == nil {
+			t.Fatalf("#%d inferVersionFromLabels is supposed to fail", i)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// LocalOwner is the owner of the repository of LocalClient, which has no owner
+const LocalOwner = "local"
+
+// LocalClient works on a local git repository with the git command instead of the API of a forge, so that a release
+// can be cut offline or against a mirror. It commits without touching the working copy. There are no pull requests,
+// so opening one pushes the branch to Remote if Push is set, and a release is an annotated tag on the bump commit
+type LocalClient struct {
+	// Dir is the top directory of the working copy
+	Dir string
+
+	// Remote is the git remote the branch and the tag are pushed to if Push is set
+	Remote string
+	Push   bool
+}
+
+var _ releaseTagger = (*LocalClient)(nil)
+
+// NewLocalClient creates and initializes a new LocalClient of the git repository dir belongs to
+func NewLocalClient(dir, remote string, push bool) (*LocalClient, error) {
+	if push && len(remote) == 0 {
+		return nil, errors.New("missing git remote to push to")
+	}
+
+	c := &LocalClient{Dir: dir, Remote: remote, Push: push}
+	top, err := c.git(nil, "rev-parse", "--show-toplevel")
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is not in a git repository", dir)
+	}
+
+	c.Dir = top
+
+	return c, nil
+}
+
+// Repository returns LocalOwner and the name of the top directory of the working copy
+func (c *LocalClient) Repository() (string, string) {
+	return LocalOwner, filepath.Base(c.Dir)
+}
+
+// TagsOnRelease tells that a release is an annotated tag, which is created right away
+func (c *LocalClient) TagsOnRelease() {}
+
+// MissingPermissions checks that the remote exists if the branch and the tag are pushed to it
+func (c *LocalClient) MissingPermissions() ([]string, error) {
+	if !c.Push {
+		return nil, nil
+	}
+
+	if _, err := c.git(nil, "ls-remote", "--heads", c.Remote); err != nil {
+		return []string{"push to remote " + c.Remote + ": " + err.Error()}, nil
+	}
+
+	return nil, nil
+}
+
+// GetBranch gets a local branch, and returns nil if it does not exist
+func (c *LocalClient) GetBranch(name string) (*github.Branch, error) {
+	sha, ok, err := c.revParse("refs/heads/" + name + "^{commit}")
+
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return &github.Branch{Name: github.String(name), Protected: github.Bool(false), Commit: &github.RepositoryCommit{SHA: github.String(sha)}}, nil
+}
+
+// CreateNewBranch creates a new local branch from the head of the origin
+func (c *LocalClient) CreateNewBranch(origin, new string) error {
+	if len(origin) == 0 {
+		return errors.New("missing git origin branch name")
+	}
+
+	if len(new) == 0 {
+		return errors.New("missing git branch name")
+	}
+
+	if _, err := c.git(nil, "branch", "--no-track", new, "refs/heads/"+origin); err != nil {
+		return errors.Wrapf(err, "failed to create a new branch %s from %s", new, origin)
+	}
+
+	return nil
+}
+
+// DeleteLatestRef deletes the local branch, and the branch on the remote as well if it has been pushed
+func (c *LocalClient) DeleteLatestRef(branch string) error {
+	if len(branch) == 0 {
+		return errors.New("missing git branch name")
+	}
+
+	if err := c.deleteRemoteRef("refs/heads/" + branch); err != nil {
+		return err
+	}
+
+	if _, err := c.git(nil, "branch", "-D", branch); err != nil {
+		return errors.Wrapf(err, "failed to delete branch %s", branch)
+	}
+
+	return nil
+}
+
+// GetFile gets a file of the branch as it is committed
+func (c *LocalClient) GetFile(branch, path string) (*github.RepositoryContent, error) {
+	if len(branch) == 0 {
+		return nil, errors.New("missing git branch name")
+	}
+
+	if len(path) == 0 {
+		return nil, errors.New("missing git file path")
+	}
+
+	sha, ok, err := c.revParse("refs/heads/" + branch + ":" + path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, &NotFoundError{What: path + " of branch " + branch}
+	}
+
+	content, err := c.run(nil, nil, "cat-file", "blob", sha)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get file: path: %s", path)
+	}
+
+	return &github.RepositoryContent{
+		Type:     github.String("file"),
+		Name:     github.String(filepath.Base(path)),
+		Path:     github.String(path),
+		Size:     github.Int(len(content)),
+		Encoding: github.String("base64"),
+		Content:  github.String(base64.StdEncoding.EncodeToString(content)),
+		SHA:      github.String(sha),
+	}, nil
+}
+
+// ListFiles lists names of files and directories in a directory of the branch, dir is empty for the root
+func (c *LocalClient) ListFiles(branch, dir string) ([]string, error) {
+	if len(branch) == 0 {
+		return nil, errors.New("missing git branch name")
+	}
+
+	out, err := c.git(nil, "ls-tree", "--name-only", "refs/heads/"+branch+":"+dir)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list files: directory: %s", dir)
+	}
+
+	return lines(out), nil
+}
+
+// UpdateFiles updates several files of the branch in a single commit. It builds the tree in a temporary index,
+// so the working copy is left as it is, and returns ErrRefMoved if the branch has moved in the meantime
+func (c *LocalClient) UpdateFiles(branch, message string, files map[string][]byte) (string, error) {
+	if len(branch) == 0 {
+		return "", errors.New("missing git branch name")
+	}
+
+	if len(message) == 0 {
+		return "", errors.New("missing git commit message")
+	}
+
+	if len(files) == 0 {
+		return "", errors.New("missing git files to update")
+	}
+
+	ref := "refs/heads/" + branch
+	head, ok, err := c.revParse(ref + "^{commit}")
+
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "", errors.Errorf("failed to create a new commit: branch %s does not exist", branch)
+	}
+
+	index, err := ioutil.TempFile("", "gemer-index")
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create a temporary index")
+	}
+
+	// git creates the index itself, and fails to read an empty file
+	index.Close()
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if _, err := c.git(env, "read-tree", head); err != nil {
+		return "", errors.Wrapf(err, "failed to read the tree of branch %s", branch)
+	}
+
+	var paths []string
+
+	for p := range files {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		blob, err := c.gitInput(bytes.NewReader(files[p]), "hash-object", "-w", "--stdin")
+
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to write file: path: %s", p)
+		}
+
+		if _, err := c.git(env, "update-index", "--add", "--cacheinfo", "100644,"+blob+","+p); err != nil {
+			return "", errors.Wrapf(err, "failed to add file: path: %s", p)
+		}
+	}
+
+	tree, err := c.git(env, "write-tree")
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write a new tree")
+	}
+
+	commit, err := c.git(nil, "commit-tree", tree, "-p", head, "-m", message)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create a new commit: branch name: %s", branch)
+	}
+
+	// Moving the branch only if it is still at head makes the update fast-forward
+	if _, err := c.git(nil, "update-ref", "-m", "gemer: "+message, ref, commit, head); err != nil {
+		if now, _, e := c.revParse(ref); e == nil && now != head {
+			return "", errors.Wrapf(ErrRefMoved, "branch name: %s", branch)
+		}
+
+		return "", errors.Wrapf(err, "failed to update branch %s", branch)
+	}
+
+	return commit, nil
+}
+
+// CreatePullRequest pushes the head branch to the remote if Push is set, as a local repository has no pull requests.
+// The pull request returned only has the branches
+func (c *LocalClient) CreatePullRequest(title, head, base, body string) (*github.PullRequest, error) {
+	if len(head) == 0 {
+		return nil, errors.New("missing git head branch")
+	}
+
+	if c.Push {
+		if _, err := c.git(nil, "push", c.Remote, "refs/heads/"+head+":refs/heads/"+head); err != nil {
+			return nil, errors.Wrapf(err, "failed to push branch %s to %s", head, c.Remote)
+		}
+	}
+
+	return &github.PullRequest{
+		Title: github.String(title),
+		Head:  &github.PullRequestBranch{Ref: github.String(head)},
+		Base:  &github.PullRequestBranch{Ref: github.String(base)},
+	}, nil
+}
+
+// ClosePullRequest does nothing, and the pushed branch is deleted with the local one by DeleteLatestRef
+func (c *LocalClient) ClosePullRequest(number int) error {
+	return nil
+}
+
+// ListOpenPullRequests returns nothing, as a local repository has no pull requests
+func (c *LocalClient) ListOpenPullRequests(base string) ([]*github.PullRequest, error) {
+	return nil, nil
+}
+
+// ListMergedPullRequestsWithCommit returns nothing, so that release notes list the commits themselves
+func (c *LocalClient) ListMergedPullRequestsWithCommit(sha string) ([]*github.PullRequest, error) {
+	return nil, nil
+}
+
+// CreateRelease creates an annotated tag on targetCommitish, whose message is the name and the body of the release,
+// and pushes it to the remote if Push is set
+func (c *LocalClient) CreateRelease(tagName, targetCommitish, name, body string) (*github.RepositoryRelease, error) {
+	if len(tagName) == 0 {
+		return nil, errors.New("missing git tag name")
+	}
+
+	if len(targetCommitish) == 0 {
+		return nil, errors.New("missing git tag target")
+	}
+
+	if _, err := c.git(nil, "tag", "-a", tagName, "-m", name+"\n\n"+body, targetCommitish); err != nil {
+		return nil, errors.Wrapf(err, "failed to create tag %s", tagName)
+	}
+
+	if c.Push {
+		if _, err := c.git(nil, "push", c.Remote, "refs/tags/"+tagName); err != nil {
+			// The release step is not completed, so rollbacks would leave the tag behind
+			c.git(nil, "tag", "-d", tagName)
+			return nil, errors.Wrapf(err, "failed to push tag %s to %s", tagName, c.Remote)
+		}
+	}
+
+	return &github.RepositoryRelease{TagName: github.String(tagName), Name: github.String(name), Body: github.String(body), Draft: github.Bool(false)}, nil
+}
+
+// DeleteRelease deletes the tag, and the tag on the remote as well if it has been pushed. id is not used
+func (c *LocalClient) DeleteRelease(id int64, tag string) error {
+	if len(tag) == 0 {
+		return errors.New("missing git tag name")
+	}
+
+	if err := c.deleteRemoteRef("refs/tags/" + tag); err != nil {
+		return err
+	}
+
+	if _, err := c.git(nil, "tag", "-d", tag); err != nil {
+		return errors.Wrapf(err, "failed to delete tag %s", tag)
+	}
+
+	return nil
+}
+
+// FindRelease returns nil, as releases are tags, which TagExists finds
+func (c *LocalClient) FindRelease(tag string) (*github.RepositoryRelease, error) {
+	return nil, nil
+}
+
+// LatestReleaseTag returns the tag of the greatest version, or an empty string if there is none
+func (c *LocalClient) LatestReleaseTag() (string, error) {
+	out, err := c.git(nil, "tag", "--list", "v*")
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list tags")
+	}
+
+	var latestTag string
+	var latest *GemVersion
+
+	for _, tag := range lines(out) {
+		v, err := ParseGemVersion(strings.TrimPrefix(tag, "v"))
+
+		if err == nil && (latest == nil || v.Compare(latest) > 0) {
+			latestTag, latest = tag, v
+		}
+	}
+
+	return latestTag, nil
+}
+
+// TagExists returns true if the tag exists
+func (c *LocalClient) TagExists(tag string) (bool, error) {
+	_, ok, err := c.revParse("refs/tags/" + tag)
+	return ok, err
+}
+
+// CompareCommits lists commits from base, which is a tag or a branch, to head in the same way as `git log base..head`
+func (c *LocalClient) CompareCommits(base, head string) (*ComparedCommits, error) {
+	if len(base) == 0 {
+		return nil, errors.New("missing git base commit")
+	}
+
+	if len(head) == 0 {
+		return nil, errors.New("missing git head commit")
+	}
+
+	// Fields are separated by NUL and commits by RS, as messages have new lines
+	out, err := c.git(nil, "log", "--reverse", "--format=%H%x00%an%x00%P%x00%B%x1e", base+".."+head)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compare %s with %s", head, base)
+	}
+
+	var ccs []*ComparedCommit
+
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x00", 4)
+
+		if len(fields) != 4 {
+			continue
+		}
+
+		ccs = append(ccs, &ComparedCommit{SHA: fields[0], Author: fields[1], Message: strings.TrimSpace(fields[3]), Merge: len(strings.Fields(fields[2])) > 1})
+	}
+
+	return &ComparedCommits{Commits: ccs}, nil
+}
+
+// deleteRemoteRef deletes a ref on the remote if Push is set and the remote has it
+func (c *LocalClient) deleteRemoteRef(ref string) error {
+	if !c.Push {
+		return nil
+	}
+
+	out, err := c.git(nil, "ls-remote", c.Remote, ref)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to look up %s on %s", ref, c.Remote)
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	if _, err := c.git(nil, "push", c.Remote, "--delete", ref); err != nil {
+		return errors.Wrapf(err, "failed to delete %s on %s", ref, c.Remote)
+	}
+
+	return nil
+}
+
+// revParse resolves a revision to its object name, and returns false if it does not exist
+func (c *LocalClient) revParse(rev string) (string, bool, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", rev)
+	cmd.Dir = c.Dir
+	out, err := cmd.Output()
+
+	if _, ok := err.(*exec.ExitError); ok && len(out) == 0 {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed to resolve %s", rev)
+	}
+
+	return strings.TrimSpace(string(out)), true, nil
+}
+
+// git runs a git command in the working copy with additional environment variables, and returns its output without
+// the trailing new line. The error has what git writes to stderr
+func (c *LocalClient) git(env []string, args ...string) (string, error) {
+	out, err := c.run(env, nil, args...)
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+// gitInput runs a git command with stdin
+func (c *LocalClient) gitInput(stdin io.Reader, args ...string) (string, error) {
+	out, err := c.run(nil, stdin, args...)
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+// run runs a git command, and returns its output as it is
+func (c *LocalClient) run(env []string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = c.Dir
+	cmd.Stdin = stdin
+
+	if len(env) != 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+
+	if err != nil {
+		return nil, errors.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()+" "+err.Error()))
+	}
+
+	return out, nil
+}
+
+// lines splits the output of a command into lines, and returns nil if it is empty
+func lines(out string) []string {
+	if len(out) == 0 {
+		return nil
+	}
+
+	return strings.Split(out, "\n")
+}
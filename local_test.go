@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// testGit runs a git command in dir, and returns its output without the trailing new line
+func testGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+
+	if err != nil {
+		t.Fatalf("git %s failed: %s: %s", strings.Join(args, " "), err, out)
+	}
+
+	return strings.TrimRight(string(out), "\n")
+}
+
+// testLocalRepository creates a git repository of a gem released as v0.1.0 with two commits since then,
+// and a bare repository as its origin
+func testLocalRepository(t *testing.T) (string, string, func()) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, cleanup := testConfigDir(t)
+	work, origin := filepath.Join(dir, "r"), filepath.Join(dir, "origin.git")
+
+	testWriteFile(t, filepath.Join(work, "r.gemspec"), "")
+	testWriteFile(t, filepath.Join(work, "lib", "r", "version.rb"), "module R\n  VERSION = '0.1.0'\nend\n")
+
+	testGit(t, dir, "init", "--bare", origin)
+	testGit(t, work, "init")
+	testGit(t, work, "symbolic-ref", "HEAD", "refs/heads/master")
+	testGit(t, work, "config", "user.name", "Shuhei Kitagawa")
+	testGit(t, work, "config", "user.email", "shuheiktgw@example.com")
+	testGit(t, work, "remote", "add", "origin", origin)
+	testGit(t, work, "add", ".")
+	testGit(t, work, "commit", "-m", "Initial commit")
+	testGit(t, work, "tag", "-a", "v0.1.0", "-m", "v0.1.0")
+
+	for _, message := range []string{"feat: add foo", "fix: fix bar"} {
+		testWriteFile(t, filepath.Join(work, message), message)
+		testGit(t, work, "add", ".")
+		testGit(t, work, "commit", "-m", message)
+	}
+
+	testGit(t, work, "push", "origin", "master", "v0.1.0")
+
+	return work, origin, cleanup
+}
+
+func TestNewLocalClient(t *testing.T) {
+	work, _, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	c, err := NewLocalClient(filepath.Join(work, "lib", "r"), DefaultRemote, false)
+
+	if err != nil {
+		t.Fatalf("NewLocalClient failed: %s", err)
+	}
+
+	if top, _ := filepath.EvalSymlinks(work); c.Dir != top {
+		t.Fatalf("invalid top directory: want: %s, got: %s", top, c.Dir)
+	}
+
+	if owner, repo := c.Repository(); owner != LocalOwner || repo != "r" {
+		t.Fatalf("invalid repository: %s/%s", owner, repo)
+	}
+
+	if _, err := NewLocalClient(filepath.Dir(work), DefaultRemote, false); err == nil {
+		t.Fatalf("NewLocalClient is supposed to fail outside of a git repository")
+	}
+
+	if _, err := NewLocalClient(work, "", true); err == nil {
+		t.Fatalf("NewLocalClient is supposed to fail to push without a remote")
+	}
+}
+
+func TestLocalClientMissingPermissions(t *testing.T) {
+	work, _, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	cases := []struct {
+		remote string
+		push   bool
+		want   string
+	}{
+		{remote: DefaultRemote, push: false},
+		{remote: DefaultRemote, push: true},
+		{remote: "unknown", push: false},
+		{remote: "unknown", push: true, want: "push to remote unknown"},
+	}
+
+	for i, tc := range cases {
+		missing, err := (&LocalClient{Dir: work, Remote: tc.remote, Push: tc.push}).MissingPermissions()
+
+		if err != nil {
+			t.Fatalf("#%d MissingPermissions failed: %s", i, err)
+		}
+
+		if got := strings.Join(missing, "\n"); !strings.HasPrefix(got, tc.want) || (len(tc.want) == 0) != (len(got) == 0) {
+			t.Fatalf("#%d invalid missing permissions: want: %q, got: %q", i, tc.want, missing)
+		}
+	}
+}
+
+func TestLocalClientBranchesAndFiles(t *testing.T) {
+	work, _, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	c := &LocalClient{Dir: work, Remote: DefaultRemote}
+
+	if err := c.CreateNewBranch("master", "feature/new"); err != nil {
+		t.Fatalf("CreateNewBranch failed: %s", err)
+	}
+
+	b, err := c.GetBranch("feature/new")
+
+	if err != nil || b == nil || b.GetName() != "feature/new" || b.GetCommit().GetSHA() != testGit(t, work, "rev-parse", "master") {
+		t.Fatalf("invalid branch: %+v, %v", b, err)
+	}
+
+	if b, err := c.GetBranch("unknown"); err != nil || b != nil {
+		t.Fatalf("GetBranch is supposed to return nil for an unknown branch: %+v, %v", b, err)
+	}
+
+	for i, tc := range []struct{ origin, new string }{{origin: "", new: "new"}, {origin: "master", new: ""}, {origin: "unknown", new: "new"}, {origin: "master", new: "feature/new"}} {
+		if err := c.CreateNewBranch(tc.origin, tc.new); err == nil {
+			t.Fatalf("#%d CreateNewBranch is supposed to fail", i)
+		}
+	}
+
+	rc, err := c.GetFile("feature/new", "lib/r/version.rb")
+
+	if err != nil {
+		t.Fatalf("GetFile failed: %s", err)
+	}
+
+	if content, err := decodeContent(rc); err != nil || content != "module R\n  VERSION = '0.1.0'\nend\n" || rc.GetName() != "version.rb" {
+		t.Fatalf("invalid file: %+v: %q, %v", rc, content, err)
+	}
+
+	for i, tc := range []struct{ branch, path string }{{branch: "master", path: "unknown"}, {branch: "unknown", path: "r.gemspec"}} {
+		if _, err := c.GetFile(tc.branch, tc.path); !isNotFound(err) {
+			t.Fatalf("#%d GetFile is supposed to fail with not found: %v", i, err)
+		}
+	}
+
+	if names, err := c.ListFiles("master", ""); err != nil || strings.Join(names, ",") != "feat: add foo,fix: fix bar,lib,r.gemspec" {
+		t.Fatalf("invalid files: %q, %v", names, err)
+	}
+
+	if names, err := c.ListFiles("master", "lib/r"); err != nil || strings.Join(names, ",") != "version.rb" {
+		t.Fatalf("invalid files of lib/r: %q, %v", names, err)
+	}
+
+	if _, err := c.ListFiles("master", "unknown"); err == nil {
+		t.Fatalf("ListFiles is supposed to fail for an unknown directory")
+	}
+
+	if err := c.DeleteLatestRef("feature/new"); err != nil {
+		t.Fatalf("DeleteLatestRef failed: %s", err)
+	}
+
+	if b, err := c.GetBranch("feature/new"); err != nil || b != nil {
+		t.Fatalf("branch is supposed to be deleted: %+v, %v", b, err)
+	}
+}
+
+func TestLocalClientUpdateFiles(t *testing.T) {
+	work, _, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	c := &LocalClient{Dir: work, Remote: DefaultRemote}
+	testGit(t, work, "branch", "bump", "master")
+
+	files := map[string][]byte{
+		"lib/r/version.rb": []byte("module R\n  VERSION = '0.1.1'\nend\n"),
+		"CHANGELOG.md":     []byte("# Changelog\n"),
+	}
+
+	sha, err := c.UpdateFiles("bump", "Bumps up to 0.1.1", files)
+
+	if err != nil {
+		t.Fatalf("UpdateFiles failed: %s", err)
+	}
+
+	if head := testGit(t, work, "rev-parse", "bump"); sha != head || testGit(t, work, "rev-parse", sha+"^") != testGit(t, work, "rev-parse", "master") {
+		t.Fatalf("invalid commit: %s", sha)
+	}
+
+	for p, content := range files {
+		if got := testGit(t, work, "show", sha+":"+p); got+"\n" != string(content) {
+			t.Fatalf("invalid content of %s: want: %q, got: %q", p, content, got)
+		}
+	}
+
+	// The working copy stays on master as it is
+	if status := testGit(t, work, "status", "--porcelain"); len(status) != 0 || testGit(t, work, "rev-parse", "--abbrev-ref", "HEAD") != "master" {
+		t.Fatalf("working copy is not supposed to change: %s", status)
+	}
+
+	failures := []struct {
+		branch, message string
+		files           map[string][]byte
+	}{
+		{branch: "", message: "Bumps up to 0.1.1", files: files},
+		{branch: "bump", message: "", files: files},
+		{branch: "bump", message: "Bumps up to 0.1.1", files: nil},
+		{branch: "unknown", message: "Bumps up to 0.1.1", files: files},
+	}
+
+	for i, tc := range failures {
+		if _, err := c.UpdateFiles(tc.branch, tc.message, tc.files); err == nil {
+			t.Fatalf("#%d UpdateFiles is supposed to fail", i)
+		}
+	}
+}
+
+func TestLocalClientReleases(t *testing.T) {
+	work, origin, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	c := &LocalClient{Dir: work, Remote: DefaultRemote, Push: true}
+
+	for _, tag := range []string{"v0.9.0", "v0.10.0", "nightly", "vendor"} {
+		testGit(t, work, "tag", tag, "master~1")
+	}
+
+	if latest, err := c.LatestReleaseTag(); err != nil || latest != "v0.10.0" {
+		t.Fatalf("invalid latest release: %s, %v", latest, err)
+	}
+
+	r, err := c.CreateRelease("v1.0.0.rc1", "master", "v1.0.0.rc1", "Bumps up to 1.0.0.rc1")
+
+	if err != nil {
+		t.Fatalf("CreateRelease failed: %s", err)
+	}
+
+	if r.GetTagName() != "v1.0.0.rc1" || r.GetDraft() {
+		t.Fatalf("invalid release: %+v", r)
+	}
+
+	if kind := testGit(t, work, "cat-file", "-t", "v1.0.0.rc1"); kind != "tag" {
+		t.Fatalf("tag is supposed to be annotated: %s", kind)
+	}
+
+	if message := testGit(t, work, "tag", "-l", "--format=%(contents)", "v1.0.0.rc1"); message != "v1.0.0.rc1\n\nBumps up to 1.0.0.rc1" {
+		t.Fatalf("invalid tag message: %q", message)
+	}
+
+	if remote := testGit(t, origin, "tag", "--list", "v1.0.0.rc1"); remote != "v1.0.0.rc1" {
+		t.Fatalf("tag is supposed to be pushed")
+	}
+
+	if latest, err := c.LatestReleaseTag(); err != nil || latest != "v1.0.0.rc1" {
+		t.Fatalf("invalid latest release: %s, %v", latest, err)
+	}
+
+	if exists, err := c.TagExists("v1.0.0.rc1"); err != nil || !exists {
+		t.Fatalf("tag is supposed to exist: %v", err)
+	}
+
+	if _, err := c.CreateRelease("v1.0.0.rc1", "master", "v1.0.0.rc1", "Bumps up to 1.0.0.rc1"); err == nil {
+		t.Fatalf("CreateRelease is supposed to fail for the same tag")
+	}
+
+	if err := c.DeleteRelease(0, "v1.0.0.rc1"); err != nil {
+		t.Fatalf("DeleteRelease failed: %s", err)
+	}
+
+	if exists, err := c.TagExists("v1.0.0.rc1"); err != nil || exists {
+		t.Fatalf("tag is supposed to be deleted: %v", err)
+	}
+
+	if remote := testGit(t, origin, "tag", "--list", "v1.0.0.rc1"); len(remote) != 0 {
+		t.Fatalf("tag on the remote is supposed to be deleted")
+	}
+
+	if err := c.DeleteRelease(0, "v1.0.0.rc1"); err == nil {
+		t.Fatalf("DeleteRelease is supposed to fail for an unknown tag")
+	}
+}
+
+func TestLocalClientCompareCommits(t *testing.T) {
+	work, _, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	c := &LocalClient{Dir: work, Remote: DefaultRemote}
+
+	testGit(t, work, "checkout", "-q", "-b", "topic", "master~1")
+	testWriteFile(t, filepath.Join(work, "baz"), "baz")
+	testGit(t, work, "add", ".")
+	testGit(t, work, "commit", "-m", "feat: add baz\n\nBaz is handy")
+	testGit(t, work, "checkout", "-q", "master")
+	testGit(t, work, "merge", "--no-ff", "-m", "Merge branch 'topic'", "topic")
+
+	ccs, err := c.CompareCommits("v0.1.0", "master")
+
+	if err != nil {
+		t.Fatalf("CompareCommits failed: %s", err)
+	}
+
+	var messages []string
+
+	for _, cc := range ccs.Commits {
+		messages = append(messages, cc.Message)
+
+		if cc.Author != "Shuhei Kitagawa" || len(cc.SHA) != 40 || cc.Merge != strings.HasPrefix(cc.Message, "Merge") {
+			t.Fatalf("invalid commit: %+v", cc)
+		}
+	}
+
+	if got := strings.Join(messages, "|"); got != "feat: add foo|feat: add baz\n\nBaz is handy|fix: fix bar|Merge branch 'topic'" {
+		t.Fatalf("invalid commits: %q", got)
+	}
+
+	for i, tc := range []struct{ base, head string }{{base: "", head: "master"}, {base: "v0.1.0", head: ""}, {base: "unknown", head: "master"}} {
+		if _, err := c.CompareCommits(tc.base, tc.head); err == nil {
+			t.Fatalf("#%d CompareCommits is supposed to fail", i)
+		}
+	}
+}
+
+func TestGemerUpdateVersionLocal(t *testing.T) {
+	work, origin, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	c, err := NewLocalClient(work, DefaultRemote, true)
+
+	if err != nil {
+		t.Fatalf("NewLocalClient failed: %s", err)
+	}
+
+	out := new(bytes.Buffer)
+	g := &Gemer{Forge: c, outStream: out}
+
+	result, err := g.UpdateVersion("master", &VersionRBSource{FilePath: "lib/r/version.rb", Constant: DefaultConstant}, AutoVersion, "")
+
+	if err != nil {
+		t.Fatalf("UpdateVersion failed: %s\n%s", err, out)
+	}
+
+	if result.Branch != "bumps_up_to_0.2.0" || result.Tag != "v0.2.0" {
+		t.Fatalf("invalid result: %+v", result)
+	}
+
+	head := testGit(t, work, "rev-parse", result.Branch)
+
+	if got := testGit(t, work, "show", head+":lib/r/version.rb"); got != "module R\n  VERSION = '0.2.0'\nend" {
+		t.Fatalf("invalid version file: %q", got)
+	}
+
+	// The annotated tag is on the commit bumping up the version, and both of them are pushed
+	if tagged := testGit(t, work, "rev-parse", "v0.2.0^{commit}"); tagged != head {
+		t.Fatalf("invalid tag: want: %s, got: %s", head, tagged)
+	}
+
+	if remote := testGit(t, origin, "rev-parse", result.Branch, "v0.2.0^{commit}"); remote != head+"\n"+head {
+		t.Fatalf("branch and tag are supposed to be pushed: %s", remote)
+	}
+
+	notes := testGit(t, work, "tag", "-l", "--format=%(contents)", "v0.2.0")
+
+	if !strings.Contains(notes, "feat: add foo") || !strings.Contains(notes, "fix: fix bar") {
+		t.Fatalf("release notes are supposed to contain the commits: %s", notes)
+	}
+
+	if status := testGit(t, work, "status", "--porcelain"); len(status) != 0 || testGit(t, work, "rev-parse", "--abbrev-ref", "HEAD") != "master" {
+		t.Fatalf("working copy is not supposed to change: %s", status)
+	}
+}
+
+func TestGemerUpdateVersionLocalRollback(t *testing.T) {
+	work, origin, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	// The remote rejects the tag, which only exists there
+	testGit(t, origin, "tag", "v0.1.1", "master")
+
+	c := &LocalClient{Dir: work, Remote: DefaultRemote, Push: true}
+	g := &Gemer{Forge: c, outStream: ioutil.Discard}
+
+	_, err := g.UpdateVersion("master", &VersionRBSource{FilePath: "lib/r/version.rb", Constant: DefaultConstant}, PatchVersion, "")
+
+	if _, ok := err.(*RollbackError); !ok {
+		t.Fatalf("UpdateVersion is supposed to fail with RollbackError: %v", err)
+	}
+
+	if branches := testGit(t, work, "branch", "--list", "bumps_up_to_0.1.1") + testGit(t, origin, "branch", "--list", "bumps_up_to_0.1.1"); len(branches) != 0 {
+		t.Fatalf("branch is supposed to be deleted locally and on the remote: %s", branches)
+	}
+
+	if exists, err := c.TagExists("v0.1.1"); err != nil || exists {
+		t.Fatalf("tag is not supposed to be left behind: %v", err)
+	}
+
+	if errors.Cause(err) == nil || !strings.Contains(err.Error(), "v0.1.1") {
+		t.Fatalf("error is supposed to tell the tag: %s", err)
+	}
+}
+
+func TestJournalLocal(t *testing.T) {
+	work, _, cleanup := testLocalRepository(t)
+	defer cleanup()
+
+	dir := filepath.Join(filepath.Dir(work), "state")
+	b := &versionBump{Current: "0.1.0", Next: "0.1.1", Rendered: &RenderedTemplates{Branch: "bumps_up_to_0.1.1"}}
+	j := newJournal(dir, &LocalClient{Dir: work, Remote: DefaultRemote, Push: true}, "master", b)
+
+	if err := j.Save(); err != nil {
+		t.Fatalf("Save failed: %s", err)
+	}
+
+	loaded, err := LoadJournal(filepath.Join(dir, journalFile(LocalOwner, "r", "0.1.1")))
+
+	if err != nil {
+		t.Fatalf("LoadJournal failed: %s", err)
+	}
+
+	if !loaded.Local || !loaded.Push || loaded.Owner != LocalOwner {
+		t.Fatalf("journal is supposed to record the local run: %+v", loaded)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("journal directory is supposed to be created: %s", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GemfileLock is the name of the lockfile of Bundler
+const GemfileLock = "Gemfile.lock"
+
+// lockfileSpecRegex matches a spec of a gem in a lockfile, e.g. `    mygem (1.2.3)`
+var lockfileSpecRegex = regexp.MustCompile(`^    ([^\s(]+) \(([^)]+)\)$`)
+
+// rewriteLockfile rewrites versions of the gem itself in a lockfile, that is specs of PATH sections
+// whose remote is `.` and whose version is current. It returns false if there is nothing to rewrite
+func rewriteLockfile(content, current, next string) (string, bool) {
+	lines := strings.Split(content, "\n")
+	var section string
+	var own, changed bool
+
+	for i, l := range lines {
+		if len(l) != 0 && l[0] != ' ' {
+			section = strings.TrimSpace(l)
+			own = false
+			continue
+		}
+
+		if section != "PATH" {
+			continue
+		}
+
+		if strings.TrimSpace(l) == "remote: ." {
+			own = true
+			continue
+		}
+
+		m := lockfileSpecRegex.FindStringSubmatch(strings.TrimRight(l, "\r"))
+
+		if !own || m == nil || m[2] != current {
+			continue
+		}
+
+		lines[i] = strings.Replace(l, "("+current+")", "("+next+")", 1)
+		changed = true
+	}
+
+	return strings.Join(lines, "\n"), changed
+}
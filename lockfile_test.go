@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+)
+
+const testLockfile = `PATH
+  remote: .
+  specs:
+    mygem (1.2.3)
+      rake (>= 10.0)
+
+PATH
+  remote: ../other
+  specs:
+    other (1.2.3)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    mygem-plugin (1.2.3)
+    rake (12.3.1)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  mygem!
+  other!
+  rake (~> 12.0)
+
+BUNDLED WITH
+   1.16.2
+`
+
+func TestRewriteLockfile(t *testing.T) {
+	cases := []struct {
+		content, current, next, want string
+		changed                      bool
+	}{
+		{
+			content: testLockfile,
+			current: "1.2.3",
+			next:    "1.3.0.rc1",
+			want: `PATH
+  remote: .
+  specs:
+    mygem (1.3.0.rc1)
+      rake (>= 10.0)
+
+PATH
+  remote: ../other
+  specs:
+    other (1.2.3)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    mygem-plugin (1.2.3)
+    rake (12.3.1)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  mygem!
+  other!
+  rake (~> 12.0)
+
+BUNDLED WITH
+   1.16.2
+`,
+			changed: true,
+		},
+		{content: testLockfile, current: "1.2.2", next: "1.2.3", want: testLockfile, changed: false},
+		{content: "GEM\n  remote: https://rubygems.org/\n  specs:\n    rake (12.3.1)\n", current: "12.3.1", next: "12.3.2", want: "GEM\n  remote: https://rubygems.org/\n  specs:\n    rake (12.3.1)\n", changed: false},
+	}
+
+	for i, tc := range cases {
+		got, changed := rewriteLockfile(tc.content, tc.current, tc.next)
+
+		if changed != tc.changed {
+			t.Fatalf("#%d invalid changed: want: %t, got: %t", i, tc.changed, changed)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid content: want: %q, got: %q", i, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// requiredAppPermissions are the permissions of GitHub App gemer needs, and what they are needed for
+var requiredAppPermissions = []struct {
+	name, purpose string
+}{
+	{name: "contents", purpose: "push branches and create releases"},
+	{name: "pull_requests", purpose: "open pull requests"},
+}
+
+// MissingPermissions checks that the client can push branches, open pull requests and create releases
+// on the repository, and returns the missing permissions. Permissions of the GitHub App are checked if
+// the client authenticates as an app, and scopes of the token otherwise
+func (c *GitHubClient) MissingPermissions() ([]string, error) {
+	repo, res, err := c.Client.Repositories.Get(context.TODO(), c.Owner, c.Repo)
+
+	if err != nil {
+		if res != nil && (res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusUnauthorized) {
+			return []string{fmt.Sprintf("access to %s/%s: the repository is not found, or the credential cannot read it", c.Owner, c.Repo)}, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to get repository %s/%s", c.Owner, c.Repo)
+	}
+
+	if c.App != nil {
+		perms, err := c.App.Permissions()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return missingAppPermissions(perms), nil
+	}
+
+	var missing []string
+
+	// Only classic personal access tokens have scopes
+	if _, ok := res.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; ok {
+		missing = append(missing, missingTokenScopes(res.Header.Get("X-OAuth-Scopes"), repo.GetPrivate())...)
+	}
+
+	if repo.Permissions != nil && !(*repo.Permissions)["push"] {
+		missing = append(missing, fmt.Sprintf("push access to %s/%s: to push branches, open pull requests and create releases", c.Owner, c.Repo))
+	}
+
+	return missing, nil
+}
+
+// missingTokenScopes returns the missing scopes of a classic personal access token given by X-OAuth-Scopes header
+func missingTokenScopes(header string, private bool) []string {
+	scopes := make(map[string]bool)
+
+	for _, s := range strings.Split(header, ",") {
+		scopes[strings.TrimSpace(s)] = true
+	}
+
+	if scopes["repo"] || (!private && scopes["public_repo"]) {
+		return nil
+	}
+
+	if private {
+		return []string{"`repo` scope of the token: to push branches, open pull requests and create releases on the private repository"}
+	}
+
+	return []string{"`repo` or `public_repo` scope of the token: to push branches, open pull requests and create releases"}
+}
+
+// missingAppPermissions returns the missing permissions of a GitHub App installation
+func missingAppPermissions(perms map[string]string) []string {
+	var missing []string
+
+	for _, p := range requiredAppPermissions {
+		if perms[p.name] != "write" {
+			missing = append(missing, fmt.Sprintf("`%s: write` permission of the GitHub App: to %s", p.name, p.purpose))
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestMissingTokenScopes(t *testing.T) {
+	cases := []struct {
+		header  string
+		private bool
+		want    int
+	}{
+		{header: "repo, user", private: true, want: 0},
+		{header: "repo", private: false, want: 0},
+		{header: "public_repo, gist", private: false, want: 0},
+		{header: "public_repo, gist", private: true, want: 1},
+		{header: "", private: false, want: 1},
+		{header: "read:org, user", private: true, want: 1},
+	}
+
+	for i, tc := range cases {
+		if got := missingTokenScopes(tc.header, tc.private); len(got) != tc.want {
+			t.Fatalf("#%d invalid number of missing scopes: want: %d, got: %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestMissingAppPermissions(t *testing.T) {
+	cases := []struct {
+		perms map[string]string
+		want  int
+	}{
+		{perms: map[string]string{"contents": "write", "pull_requests": "write", "metadata": "read"}, want: 0},
+		{perms: map[string]string{"contents": "read", "pull_requests": "write"}, want: 1},
+		{perms: map[string]string{"metadata": "read"}, want: 2},
+	}
+
+	for i, tc := range cases {
+		if got := missingAppPermissions(tc.perms); len(got) != tc.want {
+			t.Fatalf("#%d invalid number of missing permissions: want: %d, got: %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestMissingPermissions(t *testing.T) {
+	cases := []struct {
+		status      int
+		scopes      *string
+		body        string
+		wantMissing int
+	}{
+		{status: http.StatusOK, scopes: github.String("repo"), body: `{"private": true, "permissions": {"push": true}}`, wantMissing: 0},
+		{status: http.StatusOK, scopes: github.String("public_repo"), body: `{"private": true, "permissions": {"push": true}}`, wantMissing: 1},
+		{status: http.StatusOK, scopes: github.String(""), body: `{"private": false, "permissions": {"push": false}}`, wantMissing: 2},
+		{status: http.StatusOK, body: `{"private": true, "permissions": {"push": true}}`, wantMissing: 0},
+		{status: http.StatusOK, body: `{"private": true, "permissions": {"push": false}}`, wantMissing: 1},
+		{status: http.StatusNotFound, body: `{"message": "Not Found"}`, wantMissing: 1},
+	}
+
+	for i, tc := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.scopes != nil {
+				w.Header().Set("X-OAuth-Scopes", *tc.scopes)
+			}
+
+			w.WriteHeader(tc.status)
+			fmt.Fprint(w, tc.body)
+		}))
+
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(server.URL + "/")
+		c := &GitHubClient{Owner: "o", Repo: "r", Client: client}
+
+		missing, err := c.MissingPermissions()
+		server.Close()
+
+		if err != nil {
+			t.Fatalf("#%d MissingPermissions failed: %s", i, err)
+		}
+
+		if len(missing) != tc.wantMissing {
+			t.Fatalf("#%d invalid number of missing permissions: want: %d, got: %q", i, tc.wantMissing, missing)
+		}
+	}
+}
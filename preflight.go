@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PreflightError tells every problem found before making any changes
+type PreflightError struct {
+	Problems []string
+}
+
+func (e *PreflightError) Error() string {
+	return "preflight check failed:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// preflight checks that the version bump can be done without leaving anything behind, and returns
+// a PreflightError with every problem found. It only reads from GitHub
+func (g *Gemer) preflight(branch string, b *versionBump) error {
+	var problems []string
+	nextTag := "v" + b.Next
+
+	missing, err := g.Forge.MissingPermissions()
+
+	if err != nil {
+		return err
+	}
+
+	for _, m := range missing {
+		problems = append(problems, "missing permission: "+m)
+	}
+
+	base, err := g.Forge.GetBranch(branch)
+
+	if err != nil {
+		return err
+	}
+
+	if base == nil {
+		problems = append(problems, fmt.Sprintf("base branch `%s` does not exist", branch))
+	} else if base.GetProtected() {
+		// The bump is proposed by a pull request, so a protected base branch is fine
+		fmt.Fprintf(g.outStream, "==> Base branch `%s` is protected, the pull request needs to satisfy its rules to be merged\n", branch)
+	}
+
+	bump, err := g.Forge.GetBranch(b.Rendered.Branch)
+
+	if err != nil {
+		return err
+	}
+
+	if bump != nil {
+		problems = append(problems, fmt.Sprintf("branch `%s` already exists", b.Rendered.Branch))
+	}
+
+	prs, err := g.Forge.ListOpenPullRequests(branch)
+
+	if err != nil {
+		return err
+	}
+
+	for _, pr := range prs {
+		if pr.GetHead().GetRef() == b.Rendered.Branch || pr.GetTitle() == b.Rendered.PullRequestTitle {
+			problems = append(problems, fmt.Sprintf("pull request #%d `%s` to bump up the version is already open: %s", pr.GetNumber(), pr.GetTitle(), pr.GetHTMLURL()))
+		}
+	}
+
+	tagExists, err := g.Forge.TagExists(nextTag)
+
+	if err != nil {
+		return err
+	}
+
+	if tagExists {
+		problems = append(problems, fmt.Sprintf("tag `%s` already exists", nextTag))
+	}
+
+	release, err := g.Forge.FindRelease(nextTag)
+
+	if err != nil {
+		return err
+	}
+
+	if release != nil {
+		kind := "release"
+
+		if release.GetDraft() {
+			kind = "draft release"
+		}
+
+		problems = append(problems, fmt.Sprintf("%s of `%s` already exists: %s", kind, nextTag, release.GetHTMLURL()))
+	}
+
+	latest, err := g.Forge.LatestReleaseTag()
+
+	if err != nil {
+		return err
+	}
+
+	if p := versionNotGoingUp(b.Next, latest); len(p) != 0 {
+		problems = append(problems, p)
+	}
+
+	if len(problems) != 0 {
+		return &PreflightError{Problems: problems}
+	}
+
+	return nil
+}
+
+// versionNotGoingUp describes the problem if next is not greater than the version of the latest release tag
+func versionNotGoingUp(next, latestTag string) string {
+	if len(latestTag) == 0 {
+		return ""
+	}
+
+	latest, err := ParseGemVersion(strings.TrimPrefix(latestTag, "v"))
+
+	// Tags which are not versions are none of gemer's business
+	if err != nil {
+		return ""
+	}
+
+	n, err := ParseGemVersion(next)
+
+	if err != nil || n.Compare(latest) > 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("version does not go up: the next version %s is not greater than the latest release `%s`", next, latestTag)
+}
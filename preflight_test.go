@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// testPreflightServer stands in for GitHub API. Each flag makes one of the preflight checks fail
+type testPreflightServer struct {
+	noPush, noBase, bumpBranch, openPR, tag, release, latest bool
+}
+
+func (s *testPreflightServer) start() *httptest.Server {
+	mux := http.NewServeMux()
+	notFound := func(w http.ResponseWriter) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	}
+
+	mux.HandleFunc("/repos/o/r", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-OAuth-Scopes", "repo")
+		fmt.Fprintf(w, `{"private": true, "permissions": {"push": %t}}`, !s.noPush)
+	})
+
+	mux.HandleFunc("/repos/o/r/branches/master", func(w http.ResponseWriter, r *http.Request) {
+		if s.noBase {
+			notFound(w)
+			return
+		}
+
+		fmt.Fprint(w, `{"name": "master", "protected": true}`)
+	})
+
+	mux.HandleFunc("/repos/o/r/branches/bumps_up_to_0.1.1", func(w http.ResponseWriter, r *http.Request) {
+		if !s.bumpBranch {
+			notFound(w)
+			return
+		}
+
+		fmt.Fprint(w, `{"name": "bumps_up_to_0.1.1", "protected": false}`)
+	})
+
+	mux.HandleFunc("/repos/o/r/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != "open" || r.URL.Query().Get("base") != "master" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+
+		if !s.openPR {
+			fmt.Fprint(w, `[{"number": 1, "title": "Add foo", "head": {"ref": "add_foo"}}]`)
+			return
+		}
+
+		fmt.Fprint(w, `[{"number": 1, "title": "Add foo", "head": {"ref": "add_foo"}}, {"number": 2, "title": "Bumps up to 0.1.1", "head": {"ref": "release"}}]`)
+	})
+
+	mux.HandleFunc("/repos/o/r/git/refs/tags/v0.1.1", func(w http.ResponseWriter, r *http.Request) {
+		if !s.tag {
+			notFound(w)
+			return
+		}
+
+		fmt.Fprint(w, `{"ref": "refs/tags/v0.1.1", "object": {"sha": "d6ed804c"}}`)
+	})
+
+	mux.HandleFunc("/repos/o/r/releases", func(w http.ResponseWriter, r *http.Request) {
+		if !s.release {
+			fmt.Fprint(w, `[{"tag_name": "v0.1.0"}]`)
+			return
+		}
+
+		fmt.Fprint(w, `[{"tag_name": "v0.1.1", "draft": true}, {"tag_name": "v0.1.0"}]`)
+	})
+
+	mux.HandleFunc("/repos/o/r/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		if !s.latest {
+			fmt.Fprint(w, `{"tag_name": "v0.1.0"}`)
+			return
+		}
+
+		fmt.Fprint(w, `{"tag_name": "v0.2.0"}`)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func testPreflightGemer(server *httptest.Server) *Gemer {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	return &Gemer{Forge: &GitHubClient{Owner: "o", Repo: "r", Client: client}, outStream: new(bytes.Buffer)}
+}
+
+func TestPreflight(t *testing.T) {
+	b := &versionBump{
+		Current:  "0.1.0",
+		Next:     "0.1.1",
+		Rendered: &RenderedTemplates{Branch: "bumps_up_to_0.1.1", PullRequestTitle: "Bumps up to 0.1.1"},
+	}
+
+	cases := []struct {
+		server       *testPreflightServer
+		wantProblems int
+	}{
+		{server: &testPreflightServer{}, wantProblems: 0},
+		{server: &testPreflightServer{noPush: true}, wantProblems: 1},
+		{server: &testPreflightServer{noBase: true}, wantProblems: 1},
+		{server: &testPreflightServer{bumpBranch: true}, wantProblems: 1},
+		{server: &testPreflightServer{openPR: true}, wantProblems: 1},
+		{server: &testPreflightServer{tag: true}, wantProblems: 1},
+		{server: &testPreflightServer{release: true}, wantProblems: 1},
+		{server: &testPreflightServer{latest: true}, wantProblems: 1},
+		{server: &testPreflightServer{noPush: true, noBase: true, bumpBranch: true, openPR: true, tag: true, release: true, latest: true}, wantProblems: 7},
+	}
+
+	for i, tc := range cases {
+		server := tc.server.start()
+		err := testPreflightGemer(server).preflight("master", b)
+		server.Close()
+
+		if tc.wantProblems == 0 {
+			if err != nil {
+				t.Fatalf("#%d preflight failed: %s", i, err)
+			}
+
+			continue
+		}
+
+		pe, ok := err.(*PreflightError)
+
+		if !ok {
+			t.Fatalf("#%d preflight is supposed to fail with PreflightError: got: %v", i, err)
+		}
+
+		if len(pe.Problems) != tc.wantProblems {
+			t.Fatalf("#%d invalid number of problems: want: %d, got: %q", i, tc.wantProblems, pe.Problems)
+		}
+	}
+}
+
+func TestVersionNotGoingUp(t *testing.T) {
+	cases := []struct {
+		next, latest string
+		want         bool
+	}{
+		{next: "0.1.1", latest: "", want: false},
+		{next: "0.1.1", latest: "v0.1.0", want: false},
+		{next: "0.1.1", latest: "v0.1.1", want: true},
+		{next: "0.1.1", latest: "0.2.0", want: true},
+		{next: "1.0.0", latest: "v1.0.0.rc1", want: false},
+		{next: "1.0.0.rc1", latest: "v1.0.0", want: true},
+		{next: "0.1.1", latest: "nightly", want: false},
+	}
+
+	for i, tc := range cases {
+		if got := versionNotGoingUp(tc.next, tc.latest); (len(got) != 0) != tc.want {
+			t.Fatalf("#%d invalid result: want: %t, got: %q", i, tc.want, got)
+		}
+	}
+}
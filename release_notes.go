@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// OtherSection is the title of a release note section for pull requests and commits which do not belong to any section
+const OtherSection = "Other"
+
+// ReleaseNoteSection is a section of release notes, which collects pull requests having any of Labels
+type ReleaseNoteSection struct {
+	Title  string
+	Labels []string
+}
+
+// DefaultReleaseNoteSections is the default mapping from labels of pull requests to sections of release notes
+var DefaultReleaseNoteSections = []*ReleaseNoteSection{
+	{Title: "Features", Labels: []string{"feature", "enhancement"}},
+	{Title: "Bug Fixes", Labels: []string{"bug", "fix"}},
+	{Title: "Maintenance", Labels: []string{"maintenance", "chore", "refactoring", "documentation"}},
+	{Title: "Dependencies", Labels: []string{"dependencies"}},
+}
+
+// ParseReleaseNoteSections parses a mapping of sections such as `Features=feature,enhancement;Bug Fixes=bug`
+func ParseReleaseNoteSections(s string) ([]*ReleaseNoteSection, error) {
+	var sections []*ReleaseNoteSection
+
+	for _, def := range strings.Split(s, ";") {
+		if len(strings.TrimSpace(def)) == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(def, "=", 2)
+
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, errors.Errorf("invalid release note section: section must be in the form of `Title=label1,label2`: %s", def)
+		}
+
+		section := &ReleaseNoteSection{Title: strings.TrimSpace(kv[0])}
+
+		for _, l := range strings.Split(kv[1], ",") {
+			if l = strings.TrimSpace(l); len(l) != 0 {
+				section.Labels = append(section.Labels, l)
+			}
+		}
+
+		if len(section.Labels) == 0 {
+			return nil, errors.Errorf("invalid release note section: section must have at least one label: %s", def)
+		}
+
+		sections = append(sections, section)
+	}
+
+	if len(sections) == 0 {
+		return nil, errors.New("invalid release note sections: no section is given")
+	}
+
+	return sections, nil
+}
+
+// buildReleaseNotes builds release notes from merged pull requests grouped into sections by their labels.
+// Pull requests which do not match any section and commits without a pull request go into the Other section
+func buildReleaseNotes(sections []*ReleaseNoteSection, prs []*github.PullRequest, orphans []*ComparedCommit) string {
+	entries := make(map[string][]string)
+
+	for _, pr := range prs {
+		title := releaseNoteSectionOf(sections, pr)
+		entries[title] = append(entries[title], fmt.Sprintf("- %s ([#%d](%s)) @%s", pr.GetTitle(), pr.GetNumber(), pr.GetHTMLURL(), pr.GetUser().GetLogin()))
+	}
+
+	for _, c := range orphans {
+		if c.Merge {
+			continue
+		}
+
+		entries[OtherSection] = append(entries[OtherSection], fmt.Sprintf("- %s ([%s](%s)) @%s", c.Title(), shortSHA(c.SHA), c.HTMLURL, c.Author))
+	}
+
+	var notes []string
+
+	for _, s := range append(sections, &ReleaseNoteSection{Title: OtherSection}) {
+		if len(entries[s.Title]) == 0 {
+			continue
+		}
+
+		notes = append(notes, "## "+s.Title+"\n"+strings.Join(entries[s.Title], "\n"))
+		delete(entries, s.Title)
+	}
+
+	if len(notes) == 0 {
+		return "No changes"
+	}
+
+	return strings.Join(notes, "\n\n")
+}
+
+// releaseNoteSectionOf returns the title of the first section any label of the pull request matches
+func releaseNoteSectionOf(sections []*ReleaseNoteSection, pr *github.PullRequest) string {
+	for _, s := range sections {
+		for _, sl := range s.Labels {
+			for _, l := range pr.Labels {
+				if strings.EqualFold(sl, l.GetName()) {
+					return s.Title
+				}
+			}
+		}
+	}
+
+	return OtherSection
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+
+	return sha
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseReleaseNoteSectionsSuccess(t *testing.T) {
+	sections, err := ParseReleaseNoteSections("Features=feature, enhancement;Bug Fixes=bug;")
+
+	if err != nil {
+		t.Fatalf("ParseReleaseNoteSections failed: %s", err)
+	}
+
+	if len(sections) != 2 {
+		t.Fatalf("invalid number of sections: want: 2, got: %d", len(sections))
+	}
+
+	if sections[0].Title != "Features" || len(sections[0].Labels) != 2 || sections[0].Labels[1] != "enhancement" {
+		t.Fatalf("invalid section: %+v", sections[0])
+	}
+
+	if sections[1].Title != "Bug Fixes" || len(sections[1].Labels) != 1 || sections[1].Labels[0] != "bug" {
+		t.Fatalf("invalid section: %+v", sections[1])
+	}
+}
+
+func TestParseReleaseNoteSectionsFail(t *testing.T) {
+	cases := []string{"", ";", "Features", "=feature", "Features=", "Features=feature;Bug Fixes"}
+
+	for i, tc := range cases {
+		if _, err := ParseReleaseNoteSections(tc); err == nil {
+			t.Fatalf("#%d ParseReleaseNoteSections is supposed to fail: %q", i, tc)
+		}
+	}
+}
+
+func TestBuildReleaseNotes(t *testing.T) {
+	pr := func(number int, title string, labels ...string) *github.PullRequest {
+		pr := testPullRequest(number, labels...)
+		pr.Title = github.String(title)
+		pr.User = &github.User{Login: github.String("shuheiktgw")}
+		return pr
+	}
+
+	prs := []*github.PullRequest{
+		pr(1, "Add foo", "enhancement"),
+		pr(2, "Fix bar", "bug"),
+		pr(3, "Bump rake", "dependencies"),
+		pr(4, "Add baz", "feature"),
+		pr(5, "Something"),
+	}
+
+	orphans := []*ComparedCommit{
+		{SHA: "d6ed804c9bbaefef", Author: "shuheiktgw", Message: "Fix typo\n\nin README", HTMLURL: "https://github.com/o/r/commit/d6ed804c9bbaefef"},
+		{SHA: "e6ed804c9bbaefef", Author: "shuheiktgw", Message: "Merge branch 'master' into develop", HTMLURL: "https://github.com/o/r/commit/e6ed804c9bbaefef", Merge: true},
+	}
+
+	want := `## Features
+- Add foo ([#1](https://github.com/o/r/pull/1)) @shuheiktgw
+- Add baz ([#4](https://github.com/o/r/pull/1)) @shuheiktgw
+
+## Bug Fixes
+- Fix bar ([#2](https://github.com/o/r/pull/1)) @shuheiktgw
+
+## Dependencies
+- Bump rake ([#3](https://github.com/o/r/pull/1)) @shuheiktgw
+
+## Other
+- Something ([#5](https://github.com/o/r/pull/1)) @shuheiktgw
+- Fix typo ([d6ed804](https://github.com/o/r/commit/d6ed804c9bbaefef)) @shuheiktgw`
+
+	if got := buildReleaseNotes(DefaultReleaseNoteSections, prs, orphans); got != want {
+		t.Fatalf("invalid release notes: want: %s, got: %s", want, got)
+	}
+
+	if got := buildReleaseNotes(DefaultReleaseNoteSections, nil, nil); got != "No changes" {
+		t.Fatalf("invalid release notes: want: No changes, got: %s", got)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRemote is the name of the git remote gemer infers a GitHub repository from by default
+const DefaultRemote = "origin"
+
+// GitHubHost is the host of GitHub
+const GitHubHost = "github.com"
+
+// GitLabHost is the host of GitLab.com
+const GitLabHost = "gitlab.com"
+
+// GiteaHost is the host of Gitea.com
+const GiteaHost = "gitea.com"
+
+// CodebergHost is the host of Codeberg, which runs Forgejo
+const CodebergHost = "codeberg.org"
+
+// RemoteRepository infers the owner and the name of a GitHub repository from a remote of the git repository dir belongs to
+func RemoteRepository(dir, remote, host string) (string, string, error) {
+	u, err := gitRemoteURL(dir, remote)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	owner, repo, err := parseGitHubURL(u, host)
+
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to infer a GitHub repository from remote %s", remote)
+	}
+
+	return owner, repo, nil
+}
+
+// RemoteGitLabProject infers the namespace and the name of a GitLab project from a remote of the git repository dir belongs to.
+// The namespace can be a group nested in other groups, e.g. `group/subgroup`
+func RemoteGitLabProject(dir, remote, host string) (string, string, error) {
+	u, err := gitRemoteURL(dir, remote)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	namespace, project, err := parseGitLabURL(u, host)
+
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to infer a GitLab project from remote %s", remote)
+	}
+
+	return namespace, project, nil
+}
+
+// RemoteHost returns the host of a remote of the git repository dir belongs to
+func RemoteHost(dir, remote string) (string, error) {
+	u, err := gitRemoteURL(dir, remote)
+
+	if err != nil {
+		return "", err
+	}
+
+	host, _, err := splitGitURL(u)
+
+	return host, err
+}
+
+// gitRemoteURL reads the url of a remote from the config of the git repository dir belongs to,
+// and falls back to `git remote get-url` if the config does not have it
+func gitRemoteURL(dir, remote string) (string, error) {
+	if gitDir, err := findGitDir(dir); err == nil {
+		if content, err := ioutil.ReadFile(filepath.Join(gitDir, "config")); err == nil {
+			if u := gitConfigRemoteURL(string(content), remote); len(u) != 0 {
+				return u, nil
+			}
+		}
+	}
+
+	cmd := exec.Command("git", "remote", "get-url", remote)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+
+	if err != nil {
+		return "", errors.Errorf("failed to get the url of remote %s: %s", remote, strings.TrimSpace(stderr.String()+" "+err.Error()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// findGitDir looks for the git directory from dir up to the root. `.git` can be a file pointing to the git directory
+func findGitDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return "", err
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		p := filepath.Join(d, ".git")
+		fi, err := os.Stat(p)
+
+		if err == nil && fi.IsDir() {
+			return p, nil
+		}
+
+		if err == nil {
+			content, err := ioutil.ReadFile(p)
+
+			if err != nil {
+				return "", err
+			}
+
+			if s := strings.TrimSpace(string(content)); strings.HasPrefix(s, "gitdir:") {
+				gitDir := strings.TrimSpace(strings.TrimPrefix(s, "gitdir:"))
+
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(d, gitDir)
+				}
+
+				return gitDir, nil
+			}
+		}
+
+		if filepath.Dir(d) == d {
+			return "", errors.Errorf("%s is not in a git repository", dir)
+		}
+	}
+}
+
+// gitConfigRemoteURL returns the url of a remote in the content of a git config file, or an empty string
+func gitConfigRemoteURL(content, remote string) string {
+	var section string
+	s := bufio.NewScanner(strings.NewReader(content))
+
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+
+		if len(l) == 0 || l[0] == '#' || l[0] == ';' {
+			continue
+		}
+
+		if l[0] == '[' {
+			section = strings.TrimSuffix(strings.TrimPrefix(l, "["), "]")
+			continue
+		}
+
+		kv := strings.SplitN(l, "=", 2)
+
+		if len(kv) == 2 && section == `remote "`+remote+`"` && strings.EqualFold(strings.TrimSpace(kv[0]), "url") {
+			return strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		}
+	}
+
+	return ""
+}
+
+// parseGitHubURL parses a url of a GitHub repository in the form of SSH (`git@github.com:owner/repo.git`
+// or `ssh://git@github.com/owner/repo.git`), HTTPS or `git://`, and returns the owner and the repository
+func parseGitHubURL(rawurl, host string) (string, string, error) {
+	parts, err := gitURLPath(rawurl, host)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", errors.Errorf("%s is not a url of a GitHub repository", rawurl)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// parseGitLabURL parses a url of a GitLab project in the same forms as parseGitHubURL, and returns the namespace,
+// which can have subgroups, and the project
+func parseGitLabURL(rawurl, host string) (string, string, error) {
+	parts, err := gitURLPath(rawurl, host)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	invalid := len(parts) < 2
+
+	// `-` separates the project from its pages, e.g. https://gitlab.com/group/project/-/tree/master
+	for _, p := range parts {
+		invalid = invalid || len(p) == 0 || p == "-"
+	}
+
+	if invalid {
+		return "", "", errors.Errorf("%s is not a url of a GitLab project", rawurl)
+	}
+
+	last := len(parts) - 1
+
+	return strings.Join(parts[:last], "/"), parts[last], nil
+}
+
+// gitURLPath splits the path of a git url on host into its elements, without `.git` suffix
+func gitURLPath(rawurl, host string) ([]string, error) {
+	h, path, err := splitGitURL(rawurl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(h, host) {
+		return nil, errors.Errorf("%s is not a repository on %s", rawurl, host)
+	}
+
+	return strings.Split(strings.Trim(strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git"), "/"), "/"), nil
+}
+
+// splitGitURL returns the host and the path of a git url, which is in the form of SSH, HTTPS or `git://`
+func splitGitURL(rawurl string) (string, string, error) {
+	var h, path string
+
+	if i := strings.Index(rawurl, "://"); i >= 0 {
+		u, err := url.Parse(rawurl)
+
+		if err != nil {
+			return "", "", errors.Wrapf(err, "invalid git url %s", rawurl)
+		}
+
+		switch u.Scheme {
+		case "ssh", "git", "https", "http", "git+ssh", "ssh+git":
+		default:
+			return "", "", errors.Errorf("unsupported git url %s", rawurl)
+		}
+
+		h, path = u.Hostname(), u.Path
+	} else if i := strings.Index(rawurl, ":"); i >= 0 {
+		// scp-like syntax, e.g. git@github.com:owner/repo.git
+		h, path = rawurl[:i], rawurl[i+1:]
+
+		if j := strings.Index(h, "@"); j >= 0 {
+			h = h[j+1:]
+		}
+	} else {
+		return "", "", errors.Errorf("unsupported git url %s", rawurl)
+	}
+
+	return h, path, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RESTError is an error response from the JSON REST API of GitLab or Gitea
+type RESTError struct {
+	Method, Path string
+	StatusCode   int
+	Message      string
+}
+
+func (e *RESTError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// restClient sends requests to a JSON REST API, whose endpoints are under Prefix, e.g. a project of GitLab API
+type restClient struct {
+	HTTPClient *http.Client
+	Prefix     string
+
+	// AuthHeader is the header to send the token in, whose value is AuthValue
+	AuthHeader, AuthValue string
+
+	// ErrorMessage reads the message of an error response body, and returns an empty string if it has none
+	ErrorMessage func(content []byte) string
+}
+
+// do sends a request to an endpoint below the prefix, e.g. `branches`, with in as a JSON body, and decodes
+// the response into out. in and out can be nil. It returns a RESTError if the response is not successful
+func (c *restClient) do(method, path string, query url.Values, in, out interface{}) error {
+	endpoint := c.Prefix
+
+	if len(path) != 0 {
+		endpoint += "/" + path
+	}
+
+	if len(query) != 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var body io.Reader
+
+	if in != nil {
+		b, err := json.Marshal(in)
+
+		if err != nil {
+			return errors.Wrap(err, "failed to encode a request body")
+		}
+
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to build a request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(c.AuthHeader, c.AuthValue)
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	hc := c.HTTPClient
+
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	res, err := hc.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return c.newError(method, path, res)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return errors.Wrapf(err, "failed to decode a response of %s %s", method, path)
+	}
+
+	return nil
+}
+
+// newError reads an error response, falling back on its status if the body has no message
+func (c *restClient) newError(method, path string, res *http.Response) *RESTError {
+	e := &RESTError{Method: method, Path: path, StatusCode: res.StatusCode, Message: res.Status}
+	content, _ := ioutil.ReadAll(res.Body)
+
+	if c.ErrorMessage == nil {
+		return e
+	}
+
+	if m := c.ErrorMessage(content); len(m) != 0 {
+		e.Message = m
+	}
+
+	return e
+}
+
+// restStatus returns the status code of a RESTError, or 0 if err is not a RESTError
+func restStatus(err error) int {
+	if e, ok := errors.Cause(err).(*RESTError); ok {
+		return e.StatusCode
+	}
+
+	return 0
+}
+
+// restAPIURL normalizes the url of an API, whose default is defaultURL, appending version to it,
+// e.g. https://gitlab.example.com/ to https://gitlab.example.com/api/v4/ if version is api/v4/
+func restAPIURL(apiURL, defaultURL, version string) (*url.URL, error) {
+	if len(apiURL) == 0 {
+		apiURL = defaultURL
+	}
+
+	u, err := parseAPIURL(apiURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(u.Path, "/"+version) {
+		u.Path += version
+	}
+
+	return u, nil
+}
+
+// restWebHost returns the host of the web pages of a forge whose API is served on apiURL, or defaultHost if apiURL is empty
+func restWebHost(apiURL, defaultHost string) string {
+	u, err := url.Parse(apiURL)
+
+	if len(apiURL) == 0 || err != nil || len(u.Hostname()) == 0 {
+		return defaultHost
+	}
+
+	return u.Hostname()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// restResponse is a response of a JSON REST API, whose status is 200 OK if it is 0
+type restResponse struct {
+	status int
+	body   string
+}
+
+// restRequest is a request which testRESTServer has received
+type restRequest struct {
+	route string
+	query url.Values
+	body  map[string]interface{}
+}
+
+// testRESTServer serves the responses of routes, which are given as "METHOD /path" below prefix with escaped paths,
+// e.g. "GET /repository/files/lib%2Fversion.rb", and 404 Not Found for the others. A route can be given for a page
+// as well, e.g. "GET /pulls?page=2", which wins over the route without it. It records the requests it receives
+func testRESTServer(prefix string, routes map[string]restResponse) (*httptest.Server, *[]*restRequest) {
+	var requests []*restRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &restRequest{route: r.Method + " " + strings.TrimPrefix(r.URL.EscapedPath(), prefix), query: r.URL.Query()}
+
+		if content, _ := ioutil.ReadAll(r.Body); len(content) != 0 {
+			json.Unmarshal(content, &req.body)
+		}
+
+		requests = append(requests, req)
+		res, ok := routes[req.route+"?page="+req.query.Get("page")]
+
+		if !ok {
+			res, ok = routes[req.route]
+		}
+
+		if !ok || !strings.HasPrefix(r.URL.EscapedPath(), prefix) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "404 Not Found"}`)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if res.status != 0 {
+			w.WriteHeader(res.status)
+		}
+
+		fmt.Fprint(w, res.body)
+	}))
+
+	return server, &requests
+}
+
+// restRoutes returns the routes of the requests
+func restRoutes(requests []*restRequest) []string {
+	var routes []string
+
+	for _, r := range requests {
+		routes = append(routes, r.route)
+	}
+
+	return routes
+}
+
+func TestRestClientDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "401 Unauthorized"}`)
+			return
+		}
+
+		if r.URL.EscapedPath() != "/projects/o%2Fr/branches" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<html>Not Found</html>`)
+			return
+		}
+
+		fmt.Fprintf(w, `{"name": %q}`, r.URL.Query().Get("search"))
+	}))
+	defer server.Close()
+
+	message := func(content []byte) string {
+		if string(content) == `{"message": "401 Unauthorized"}` {
+			return "401 Unauthorized"
+		}
+
+		return ""
+	}
+
+	c := &restClient{Prefix: server.URL + "/projects/" + url.PathEscape("o/r"), AuthHeader: "X-Token", AuthValue: "secret", ErrorMessage: message}
+
+	var b struct {
+		Name string `json:"name"`
+	}
+
+	if err := c.do("GET", "branches", url.Values{"search": {"master"}}, nil, &b); err != nil {
+		t.Fatalf("do failed: %s", err)
+	}
+
+	if b.Name != "master" {
+		t.Fatalf("invalid response: %+v", b)
+	}
+
+	cases := []struct {
+		authValue, path, want string
+		wantStatus            int
+	}{
+		{authValue: "invalid", path: "branches", want: "GET branches: 401 401 Unauthorized", wantStatus: http.StatusUnauthorized},
+		{authValue: "secret", path: "tags", want: "GET tags: 404 404 Not Found", wantStatus: http.StatusNotFound},
+	}
+
+	for i, tc := range cases {
+		c.AuthValue = tc.authValue
+		err := c.do("GET", tc.path, nil, nil, nil)
+
+		if err == nil || err.Error() != tc.want {
+			t.Fatalf("#%d invalid error: want: %q, got: %v", i, tc.want, err)
+		}
+
+		if got := restStatus(err); got != tc.wantStatus {
+			t.Fatalf("#%d invalid status: want: %d, got: %d", i, tc.wantStatus, got)
+		}
+	}
+}
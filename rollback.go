@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// undoAction undoes what a step of a run created
+type undoAction struct {
+	// steps are the steps the action undoes
+	steps []string
+
+	// description tells what the action cleans up
+	description string
+
+	undo func() error
+}
+
+// RollbackError tells what was and wasn't cleaned up when a run is rolled back
+type RollbackError struct {
+	// Err is the error the run is rolled back for, which is nil if the rollback is requested
+	Err error
+
+	// CleanedUp lists what was cleaned up
+	CleanedUp []string
+
+	// Failures lists what wasn't cleaned up and why
+	Failures []string
+}
+
+func (e *RollbackError) Error() string {
+	var b strings.Builder
+
+	if e.Err != nil {
+		fmt.Fprintf(&b, "%s\nrolled back", e.Err)
+	} else {
+		b.WriteString("rolled back")
+	}
+
+	if len(e.Failures) != 0 {
+		b.WriteString(" partially")
+	}
+
+	if len(e.CleanedUp) != 0 {
+		fmt.Fprintf(&b, "\ncleaned up:\n  - %s", strings.Join(e.CleanedUp, "\n  - "))
+	}
+
+	if len(e.Failures) != 0 {
+		fmt.Fprintf(&b, "\nnot cleaned up:\n  - %s", strings.Join(e.Failures, "\n  - "))
+	}
+
+	return b.String()
+}
+
+// Cause returns the error the run is rolled back for, so that errors.Cause finds it
+func (e *RollbackError) Cause() error {
+	return e.Err
+}
+
+// undoActions returns the actions to undo the completed steps of the journal, in the order the steps are done
+func (g *Gemer) undoActions(j *Journal) []*undoAction {
+	var actions []*undoAction
+
+	// Deleting the branch takes the commit on it away as well
+	if j.Done(StepBranch) {
+		actions = append(actions, &undoAction{
+			steps:       []string{StepBranch, StepCommit},
+			description: fmt.Sprintf("branch `%s`", j.Templates.Branch),
+			undo:        func() error { return g.Forge.DeleteLatestRef(j.Templates.Branch) },
+		})
+	}
+
+	if j.Done(StepPullRequest) {
+		actions = append(actions, &undoAction{
+			steps:       []string{StepPullRequest},
+			description: fmt.Sprintf("pull request #%d", j.PrNumber),
+			undo:        func() error { return g.Forge.ClosePullRequest(j.PrNumber) },
+		})
+	}
+
+	if j.Done(StepRelease) {
+		actions = append(actions, &undoAction{
+			steps:       []string{StepRelease},
+			description: fmt.Sprintf("release `v%s`", j.Next),
+			undo:        func() error { return g.Forge.DeleteRelease(j.ReleaseID, "v"+j.Next) },
+		})
+	}
+
+	return actions
+}
+
+// rollbackUpdateVersion undoes the completed steps of the journal in the reverse order, so that the pull request
+// is closed before its branch is deleted. It attempts every undo even if some of them fail, and keeps the journal
+// with the steps left so that `gemer rollback` can try again. It returns err if there is nothing to roll back,
+// nil if the rollback is requested and succeeds, and a RollbackError otherwise
+func (g *Gemer) rollbackUpdateVersion(err error, j *Journal) error {
+	actions := g.undoActions(j)
+
+	if err != nil && len(actions) == 0 {
+		if e := j.Remove(); e != nil {
+			return &RollbackError{Err: err, Failures: []string{"journal: " + e.Error()}}
+		}
+
+		return err
+	}
+
+	re := &RollbackError{Err: err}
+	left := false
+
+	for i := len(actions) - 1; i >= 0; i-- {
+		a := actions[i]
+		fmt.Fprintf(g.outStream, "==> Roll back %s\n", a.description)
+
+		if e := a.undo(); e != nil {
+			re.Failures = append(re.Failures, fmt.Sprintf("%s: %s", a.description, e))
+			left = true
+			continue
+		}
+
+		re.CleanedUp = append(re.CleanedUp, a.description)
+
+		for _, s := range a.steps {
+			if e := j.Undo(s); e != nil {
+				re.Failures = append(re.Failures, fmt.Sprintf("journal %s: step %s is undone but not recorded: %s", j.Path(), s, e))
+			}
+		}
+	}
+
+	// The journal is kept as long as something is left to clean up
+	var e error
+
+	if !left {
+		e = j.Remove()
+	} else {
+		e = j.Save()
+	}
+
+	if e != nil {
+		re.Failures = append(re.Failures, fmt.Sprintf("journal %s: %s", j.Path(), e))
+	}
+
+	if err == nil && len(re.Failures) == 0 {
+		return nil
+	}
+
+	return re
+}
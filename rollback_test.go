@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	testDeleteBranch  = "DELETE /repos/o/r/git/refs/heads/bumps_up_to_0.1.1"
+	testClosePR       = "PATCH /repos/o/r/pulls/3"
+	testDeleteRelease = "DELETE /repos/o/r/releases/5"
+	testCreatePR      = "POST /repos/o/r/pulls"
+	testCreateRelease = "POST /repos/o/r/releases"
+)
+
+func TestGemerRollback(t *testing.T) {
+	all := []string{StepBranch, StepCommit, StepPullRequest, StepRelease}
+
+	cases := []struct {
+		completed     []string
+		fail          []string
+		wantRequests  []string
+		wantCleanedUp int
+		wantLeft      []string
+	}{
+		{
+			completed:    all,
+			wantRequests: []string{testDeleteRelease, testClosePR, testDeleteBranch},
+		},
+		{
+			completed:    []string{StepBranch, StepCommit},
+			wantRequests: []string{testDeleteBranch},
+		},
+		{
+			completed:    nil,
+			wantRequests: nil,
+		},
+		{
+			completed:     all,
+			fail:          []string{testDeleteRelease},
+			wantRequests:  []string{testDeleteRelease, testClosePR, testDeleteBranch},
+			wantCleanedUp: 2,
+			wantLeft:      []string{StepRelease},
+		},
+		{
+			completed:     all,
+			fail:          []string{testClosePR},
+			wantRequests:  []string{testDeleteRelease, testClosePR, testDeleteBranch},
+			wantCleanedUp: 2,
+			wantLeft:      []string{StepPullRequest},
+		},
+		{
+			completed:     all,
+			fail:          []string{testDeleteBranch},
+			wantRequests:  []string{testDeleteRelease, testClosePR, testDeleteBranch},
+			wantCleanedUp: 2,
+			wantLeft:      []string{StepBranch, StepCommit},
+		},
+		{
+			completed:     all,
+			fail:          []string{testDeleteRelease, testClosePR, testDeleteBranch},
+			wantRequests:  []string{testDeleteRelease, testClosePR, testDeleteBranch},
+			wantCleanedUp: 0,
+			wantLeft:      all,
+		},
+	}
+
+	_, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	for i, tc := range cases {
+		var requests []string
+		fail := make(map[string]bool)
+
+		for _, f := range tc.fail {
+			fail[f] = true
+		}
+
+		server := testJournalServer(&requests, fail)
+
+		j := testJournal(JournalDir())
+		j.Completed, j.PrNumber, j.ReleaseID = tc.completed, 3, 5
+
+		if err := j.Save(); err != nil {
+			t.Fatalf("#%d Save failed: %s", i, err)
+		}
+
+		err := testJournalGemer(server).Rollback(j)
+		server.Close()
+
+		if fmt.Sprint(requests) != fmt.Sprint(tc.wantRequests) {
+			t.Fatalf("#%d invalid requests: want: %q, got: %q", i, tc.wantRequests, requests)
+		}
+
+		if len(tc.fail) == 0 {
+			if err != nil {
+				t.Fatalf("#%d Rollback failed: %s", i, err)
+			}
+
+			if _, err := os.Stat(j.Path()); !os.IsNotExist(err) {
+				t.Fatalf("#%d journal is supposed to be removed: %v", i, err)
+			}
+
+			continue
+		}
+
+		re, ok := err.(*RollbackError)
+
+		if !ok {
+			t.Fatalf("#%d Rollback is supposed to fail with RollbackError: got: %v", i, err)
+		}
+
+		if len(re.CleanedUp) != tc.wantCleanedUp || len(re.Failures) != len(tc.fail) {
+			t.Fatalf("#%d invalid RollbackError: %s", i, re)
+		}
+
+		left, err := LoadJournal(j.Path())
+
+		if err != nil {
+			t.Fatalf("#%d journal is supposed to be kept: %s", i, err)
+		}
+
+		if fmt.Sprint(left.Completed) != fmt.Sprint(tc.wantLeft) {
+			t.Fatalf("#%d invalid steps left: want: %q, got: %q", i, tc.wantLeft, left.Completed)
+		}
+
+		left.Remove()
+	}
+}
+
+func TestGemerRollbackUnrecorded(t *testing.T) {
+	var requests []string
+	server := testJournalServer(&requests, nil)
+	defer server.Close()
+
+	_, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	j := testJournal(JournalDir())
+	j.Completed, j.PrNumber, j.ReleaseID = []string{StepBranch, StepCommit, StepPullRequest, StepRelease}, 3, 5
+
+	if err := j.Save(); err != nil {
+		t.Fatalf("Save failed: %s", err)
+	}
+
+	// The journal cannot be written any more once its directory is replaced with a file
+	if err := os.RemoveAll(JournalDir()); err != nil {
+		t.Fatalf("failed to remove the journal directory: %s", err)
+	}
+
+	testWriteFile(t, JournalDir(), "")
+
+	err := testJournalGemer(server).Rollback(j)
+	re, ok := err.(*RollbackError)
+
+	if !ok {
+		t.Fatalf("Rollback is supposed to fail with RollbackError: got: %v", err)
+	}
+
+	if len(re.CleanedUp) != 3 {
+		t.Fatalf("invalid RollbackError: %s", re)
+	}
+
+	for _, s := range []string{StepBranch, StepCommit, StepPullRequest, StepRelease} {
+		if !strings.Contains(re.Error(), "step "+s+" is undone but not recorded") {
+			t.Fatalf("failure to record step %s is supposed to be reported: %s", s, re)
+		}
+	}
+}
+
+func TestGemerRunJournalFail(t *testing.T) {
+	cases := []struct {
+		fail          []string
+		wantRequests  []string
+		wantCleanedUp int
+		wantFailures  int
+	}{
+		{
+			fail:          []string{testCreatePR},
+			wantRequests:  []string{testCreatePR, testDeleteBranch},
+			wantCleanedUp: 1,
+		},
+		{
+			fail:          []string{testCreateRelease},
+			wantRequests:  []string{testCreatePR, testCreateRelease, testClosePR, testDeleteBranch},
+			wantCleanedUp: 2,
+		},
+		{
+			fail:          []string{testCreateRelease, testClosePR},
+			wantRequests:  []string{testCreatePR, testCreateRelease, testClosePR, testDeleteBranch},
+			wantCleanedUp: 1,
+			wantFailures:  1,
+		},
+	}
+
+	_, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	for i, tc := range cases {
+		var requests []string
+		fail := make(map[string]bool)
+
+		for _, f := range tc.fail {
+			fail[f] = true
+		}
+
+		server := testJournalServer(&requests, fail)
+
+		j := testJournal(JournalDir())
+		j.Completed = []string{StepBranch, StepCommit}
+
+		_, err := testJournalGemer(server).runJournal(j)
+		server.Close()
+
+		if fmt.Sprint(requests) != fmt.Sprint(tc.wantRequests) {
+			t.Fatalf("#%d invalid requests: want: %q, got: %q", i, tc.wantRequests, requests)
+		}
+
+		re, ok := err.(*RollbackError)
+
+		if !ok {
+			t.Fatalf("#%d runJournal is supposed to fail with RollbackError: got: %v", i, err)
+		}
+
+		if re.Err == nil || errors.Cause(err) != errors.Cause(re.Err) {
+			t.Fatalf("#%d RollbackError is supposed to hold the original error: %s", i, re)
+		}
+
+		if len(re.CleanedUp) != tc.wantCleanedUp || len(re.Failures) != tc.wantFailures {
+			t.Fatalf("#%d invalid RollbackError: %s", i, re)
+		}
+
+		j.Remove()
+	}
+}
+
+func TestRollbackErrorNothingToRollBack(t *testing.T) {
+	var requests []string
+	server := testJournalServer(&requests, nil)
+	defer server.Close()
+
+	original := errors.New("failed to create a new branch")
+
+	if err := testJournalGemer(server).rollbackUpdateVersion(original, testJournal("")); err != original {
+		t.Fatalf("the original error is supposed to be returned: got: %v", err)
+	}
+
+	if len(requests) != 0 {
+		t.Fatalf("nothing is supposed to be rolled back: %q", requests)
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultConstant is the name of the constant gemer bumps up by default
+const DefaultConstant = "VERSION"
+
+// rubyAssignment represents an assignment of a string literal in a Ruby source,
+// e.g. `VERSION = '1.2.3'.freeze`. Start and End are the offsets of Value in the source
+type rubyAssignment struct {
+	Name, Value string
+	Line        int
+	Start, End  int
+	Dynamic     bool
+}
+
+// rubyMatcher tells whether an identifier starting at start in src is the target of an assignment
+type rubyMatcher func(src string, start int, id string) bool
+
+// rubyScanner is a tiny scanner which knows just enough Ruby syntax to skip comments,
+// string literals and heredocs, so that only real assignments are picked up
+type rubyScanner struct {
+	src       string
+	pos, line int
+	heredocs  []heredoc
+
+	// err is the first string literal which is not terminated, which leaves the rest of the source unreliable
+	err error
+}
+
+type heredoc struct {
+	id       string
+	indented bool
+}
+
+// matchConstant matches a constant named name, e.g. VERSION or Foo::VERSION
+func matchConstant(name string) rubyMatcher {
+	return func(src string, start int, id string) bool {
+		if id != name || start == 0 {
+			return id == name
+		}
+
+		switch src[start-1] {
+		case '.', '$', '@':
+			return false
+		case ':':
+			return start >= 2 && src[start-2] == ':'
+		}
+
+		return true
+	}
+}
+
+// matchAttribute matches an attribute named name of any receiver, e.g. spec.version or s.version
+func matchAttribute(name string) rubyMatcher {
+	return func(src string, start int, id string) bool {
+		return id == name && start >= 2 && src[start-1] == '.' && isIdentChar(src[start-2])
+	}
+}
+
+// findRubyAssignments finds all assignments of a string literal to identifiers matched by match,
+// and returns an error if a string literal in src is not terminated
+func findRubyAssignments(src string, match rubyMatcher) ([]*rubyAssignment, error) {
+	s := &rubyScanner{src: src, line: 1}
+	var assignments []*rubyAssignment
+
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+
+		switch {
+		case c == '\n':
+			s.newline()
+		case c == '#':
+			s.skipComment()
+		case c == '=' && s.atLineStart() && strings.HasPrefix(s.src[s.pos:], "=begin"):
+			s.skipEmbeddedDocument()
+		case c == '\'' || c == '"' || c == '`':
+			s.skipString(c, c)
+		case c == '%' && s.isPercentLiteral():
+			s.skipPercentLiteral()
+		case c == '<' && s.isHeredoc():
+			s.readHeredoc()
+		case isIdentStart(c):
+			start := s.pos
+			id := s.readIdentifier()
+
+			if !match(s.src, start, id) {
+				continue
+			}
+
+			if a := s.readAssignment(id); a != nil {
+				assignments = append(assignments, a)
+			}
+		default:
+			s.pos++
+		}
+	}
+
+	return assignments, s.err
+}
+
+// extractVersion extracts a version string assigned to a given constant
+func extractVersion(content, constant string) (string, error) {
+	return extractLiteral(content, constant+" constant", matchConstant(constant))
+}
+
+// rewriteVersion replaces a version string assigned to a given constant, keeping
+// its quotes, `.freeze` and everything else in the source as it is
+func rewriteVersion(content, constant, next string) (string, error) {
+	return rewriteLiteral(content, constant+" constant", matchConstant(constant), next)
+}
+
+// extractLiteral extracts a string literal assigned to an identifier matched by match,
+// desc describes the identifier in error messages
+func extractLiteral(content, desc string, match rubyMatcher) (string, error) {
+	a, err := findLiteralAssignment(content, desc, match)
+
+	if err != nil {
+		return "", err
+	}
+
+	return a.Value, nil
+}
+
+// rewriteLiteral replaces a string literal assigned to an identifier matched by match
+func rewriteLiteral(content, desc string, match rubyMatcher, next string) (string, error) {
+	a, err := findLiteralAssignment(content, desc, match)
+
+	if err != nil {
+		return "", err
+	}
+
+	return content[:a.Start] + next + content[a.End:], nil
+}
+
+func findLiteralAssignment(content, desc string, match rubyMatcher) (*rubyAssignment, error) {
+	as, err := findRubyAssignments(content, match)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s", desc)
+	}
+
+	if len(as) == 0 {
+		return nil, errors.Errorf("failed to find %s assigned to a string literal", desc)
+	}
+
+	if len(as) > 1 {
+		var lines []string
+
+		for _, a := range as {
+			lines = append(lines, fmt.Sprint(a.Line))
+		}
+
+		return nil, errors.Errorf("found more than one candidate of %s: lines: %s", desc, strings.Join(lines, ", "))
+	}
+
+	a := as[0]
+
+	if a.Dynamic {
+		return nil, errors.Errorf("%s contains string interpolation and cannot be rewritten: line: %d", desc, a.Line)
+	}
+
+	return a, nil
+}
+
+// readAssignment reads `= 'value'` following a constant, and returns nil if it is not an assignment of a string literal
+func (s *rubyScanner) readAssignment(name string) *rubyAssignment {
+	i := s.skipSpaces(s.pos)
+
+	if i >= len(s.src) || s.src[i] != '=' {
+		return nil
+	}
+
+	if i+1 < len(s.src) && strings.IndexByte("=~>", s.src[i+1]) >= 0 {
+		return nil
+	}
+
+	i = s.skipSpaces(i + 1)
+
+	if i >= len(s.src) || (s.src[i] != '\'' && s.src[i] != '"') {
+		return nil
+	}
+
+	quote := s.src[i]
+	line := s.line
+	s.pos = i
+	if !s.skipString(quote, quote) {
+		return nil
+	}
+
+	value := s.src[i+1 : s.pos-1]
+
+	return &rubyAssignment{
+		Name:    name,
+		Value:   value,
+		Line:    line,
+		Start:   i + 1,
+		End:     s.pos - 1,
+		Dynamic: quote == '"' && (strings.Contains(value, "#{") || strings.Contains(value, "\\")),
+	}
+}
+
+func (s *rubyScanner) newline() {
+	s.pos++
+	s.line++
+
+	if len(s.heredocs) == 0 {
+		return
+	}
+
+	heredocs := s.heredocs
+	s.heredocs = nil
+
+	for _, h := range heredocs {
+		s.skipHeredocBody(h)
+	}
+}
+
+func (s *rubyScanner) skipComment() {
+	for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+		s.pos++
+	}
+}
+
+func (s *rubyScanner) skipEmbeddedDocument() {
+	for s.pos < len(s.src) {
+		end := strings.IndexByte(s.src[s.pos:], '\n')
+
+		if end < 0 {
+			s.pos = len(s.src)
+			return
+		}
+
+		isEnd := strings.HasPrefix(s.src[s.pos:], "=end")
+		s.pos += end + 1
+		s.line++
+
+		if isEnd {
+			return
+		}
+	}
+}
+
+// skipString skips a string literal starting at the current position, taking care of
+// escapes, nested delimiters and interpolations. It returns false and records an error
+// if the source ends before the literal is closed
+func (s *rubyScanner) skipString(open, close byte) bool {
+	line := s.line
+	s.pos++
+	depth := 0
+
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+
+		switch {
+		case c == '\\':
+			s.pos++
+		case c == '\n':
+			s.line++
+		case c == '#' && open != '\'' && s.pos+1 < len(s.src) && s.src[s.pos+1] == '{':
+			s.skipInterpolation()
+			continue
+		case c == close && depth == 0:
+			s.pos++
+			return true
+		case c == close:
+			depth--
+		case c == open && open != close:
+			depth++
+		}
+
+		s.pos++
+	}
+
+	if s.err == nil {
+		s.err = errors.Errorf("string literal is not terminated: line: %d", line)
+	}
+
+	s.pos = len(s.src)
+
+	return false
+}
+
+func (s *rubyScanner) skipInterpolation() {
+	s.pos += 2
+	depth := 1
+
+	for s.pos < len(s.src) && depth > 0 {
+		switch c := s.src[s.pos]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '\n':
+			s.line++
+		case '\'', '"':
+			s.skipString(c, c)
+			continue
+		}
+
+		s.pos++
+	}
+}
+
+// isPercentLiteral tells `%q(...)`, `%w[...]` and so on from the modulo operator. Following an identifier,
+// it is a literal only if it is preceded by a space and not followed by one, e.g. `puts %w(a b)`, as Ruby does
+func (s *rubyScanner) isPercentLiteral() bool {
+	i := s.pos + 1
+
+	if i < len(s.src) && strings.IndexByte("qQwWiI", s.src[i]) >= 0 {
+		i++
+	}
+
+	if i >= len(s.src) || strings.IndexByte("([{<|!/", s.src[i]) < 0 {
+		return false
+	}
+
+	prev := s.prevNonSpace()
+
+	if isIdentChar(prev) {
+		// the opener right after `%` has been checked above, so that only the space before it is left
+		return s.src[s.pos-1] == ' ' || s.src[s.pos-1] == '\t'
+	}
+
+	return prev != ')' && prev != ']' && prev != '}'
+}
+
+func (s *rubyScanner) skipPercentLiteral() {
+	s.pos++
+
+	if strings.IndexByte("qQwWiI", s.src[s.pos]) >= 0 {
+		s.pos++
+	}
+
+	open := s.src[s.pos]
+	close := open
+
+	switch open {
+	case '(':
+		close = ')'
+	case '[':
+		close = ']'
+	case '{':
+		close = '}'
+	case '<':
+		close = '>'
+	}
+
+	s.skipString(open, close)
+}
+
+// isHeredoc tells `<<~EOS`, `<<-EOS` and `<<EOS` from the shift operator
+func (s *rubyScanner) isHeredoc() bool {
+	rest := s.src[s.pos:]
+
+	if !strings.HasPrefix(rest, "<<") {
+		return false
+	}
+
+	rest = rest[2:]
+
+	if len(rest) > 0 && (rest[0] == '~' || rest[0] == '-') {
+		rest = rest[1:]
+	}
+
+	return len(rest) > 0 && (rest[0] == '\'' || rest[0] == '"' || (rest[0] >= 'A' && rest[0] <= 'Z') || rest[0] == '_')
+}
+
+func (s *rubyScanner) readHeredoc() {
+	s.pos += 2
+	h := heredoc{}
+
+	if s.src[s.pos] == '~' || s.src[s.pos] == '-' {
+		h.indented = true
+		s.pos++
+	}
+
+	if q := s.src[s.pos]; q == '\'' || q == '"' {
+		start := s.pos + 1
+		s.skipString(q, q)
+		h.id = s.src[start : s.pos-1]
+	} else {
+		h.id = s.readIdentifier()
+	}
+
+	s.heredocs = append(s.heredocs, h)
+}
+
+func (s *rubyScanner) skipHeredocBody(h heredoc) {
+	for s.pos < len(s.src) {
+		end := strings.IndexByte(s.src[s.pos:], '\n')
+		line := s.src[s.pos:]
+
+		if end >= 0 {
+			line = s.src[s.pos : s.pos+end]
+		}
+
+		if end < 0 {
+			s.pos = len(s.src)
+		} else {
+			s.pos += end + 1
+			s.line++
+		}
+
+		if line == h.id || (h.indented && strings.TrimSpace(line) == h.id) {
+			return
+		}
+	}
+}
+
+func (s *rubyScanner) readIdentifier() string {
+	start := s.pos
+
+	for s.pos < len(s.src) && isIdentChar(s.src[s.pos]) {
+		s.pos++
+	}
+
+	return s.src[start:s.pos]
+}
+
+func (s *rubyScanner) atLineStart() bool {
+	return s.pos == 0 || s.src[s.pos-1] == '\n'
+}
+
+func (s *rubyScanner) skipSpaces(i int) int {
+	for i < len(s.src) && (s.src[i] == ' ' || s.src[i] == '\t') {
+		i++
+	}
+
+	return i
+}
+
+func (s *rubyScanner) prevNonSpace() byte {
+	for i := s.pos - 1; i >= 0; i-- {
+		if c := s.src[i]; c != ' ' && c != '\t' {
+			return c
+		}
+	}
+
+	return 0
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestExtractVersionSuccess(t *testing.T) {
+	cases := []struct {
+		content, constant, want string
+	}{
+		{content: "module Foo\n  VERSION = '1.2.3'\nend", constant: "VERSION", want: "1.2.3"},
+		{content: "module Foo\n  VERSION = \"1.3.0.rc1\"\nend", constant: "VERSION", want: "1.3.0.rc1"},
+		{content: "module Foo\n  VERSION = '2.0.0.beta1'\nend", constant: "VERSION", want: "2.0.0.beta1"},
+		{content: "module Foo\n  VERSION = '1.2.3.4'.freeze\nend", constant: "VERSION", want: "1.2.3.4"},
+		{content: "module Foo; module Bar; VERSION = '1.2'; end; end", constant: "VERSION", want: "1.2"},
+		{content: "# VERSION = '0.0.1'\nmodule Foo\n  MIN_RUBY_VERSION = '2.3.0'\n  VERSION = '1.2.3'\nend", constant: "VERSION", want: "1.2.3"},
+		{content: "module Foo\n  MIN_RUBY_VERSION = '2.3.0'\n  VERSION = '1.2.3'\nend", constant: "MIN_RUBY_VERSION", want: "2.3.0"},
+		{content: "module Foo\n  DOC = <<~EOS\n    VERSION = '0.0.1'\n  EOS\n  VERSION = '1.2.3'\nend", constant: "VERSION", want: "1.2.3"},
+		{content: "=begin\nVERSION = '0.0.1'\n=end\nFoo::VERSION = '1.2.3'\n", constant: "VERSION", want: "1.2.3"},
+		{content: "module Foo\n  NOTE = \"VERSION = '0.0.1'\"\n  VERSION = '1.2.3'\nend", constant: "VERSION", want: "1.2.3"},
+		{content: "puts %w(VERSION = '7')\nVERSION = '1.2.3'\n", constant: "VERSION", want: "1.2.3"},
+		{content: "HALF = LIMIT %(2)\nVERSION = '1.2.3'\n", constant: "VERSION", want: "1.2.3"},
+		{content: "HALF = LIMIT % 2\nVERSION = '1.2.3'\n", constant: "VERSION", want: "1.2.3"},
+	}
+
+	for i, tc := range cases {
+		got, err := extractVersion(tc.content, tc.constant)
+
+		if err != nil {
+			t.Fatalf("#%d extractVersion failed: %s", i, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid version: want: %s, got: %s", i, tc.want, got)
+		}
+	}
+}
+
+func TestExtractVersionFail(t *testing.T) {
+	cases := []struct {
+		content, constant string
+	}{
+		{content: "module Foo\nend", constant: "VERSION"},
+		{content: "module Foo\n  VERSION = '1.2.3'\n  module Bar\n    VERSION = '0.1.0'\n  end\nend", constant: "VERSION"},
+		{content: "module Foo\n  VERSION = \"#{MAJOR}.0.0\"\nend", constant: "VERSION"},
+		{content: "module Foo\n  VERSION = '1.2.3'\nend", constant: "UNKNOWN"},
+		{content: "module Foo\n  VERSION == '1.2.3'\nend", constant: "VERSION"},
+		{content: "module Foo\n  VERSION = '", constant: "VERSION"},
+		{content: "module Foo\n  VERSION = \"1.2.3", constant: "VERSION"},
+		{content: "module Foo\n  NOTE = 'unterminated\n  VERSION = '1.2.3'\nend", constant: "VERSION"},
+	}
+
+	for i, tc := range cases {
+		if _, err := extractVersion(tc.content, tc.constant); err == nil {
+			t.Fatalf("#%d extractVersion is supposed to fail", i)
+		}
+	}
+}
+
+func TestRewriteVersion(t *testing.T) {
+	cases := []struct {
+		content, constant, next, want string
+	}{
+		{
+			content:  "module Foo\n  VERSION = '1.2.3'\nend",
+			constant: "VERSION",
+			next:     "1.2.4",
+			want:     "module Foo\n  VERSION = '1.2.4'\nend",
+		},
+		{
+			content:  "# Bumped from 1.2.3\nmodule Foo\n  MIN_RUBY_VERSION = \"1.2.3\".freeze\n  VERSION = \"1.2.3\".freeze\nend",
+			constant: "VERSION",
+			next:     "1.3.0.rc1",
+			want:     "# Bumped from 1.2.3\nmodule Foo\n  MIN_RUBY_VERSION = \"1.2.3\".freeze\n  VERSION = \"1.3.0.rc1\".freeze\nend",
+		},
+		{
+			content:  "module Foo; module Bar\n  MY_VERSION = '0.9.0'\nend; end",
+			constant: "MY_VERSION",
+			next:     "1.0.0",
+			want:     "module Foo; module Bar\n  MY_VERSION = '1.0.0'\nend; end",
+		},
+	}
+
+	for i, tc := range cases {
+		got, err := rewriteVersion(tc.content, tc.constant, tc.next)
+
+		if err != nil {
+			t.Fatalf("#%d rewriteVersion failed: %s", i, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid content: want: %q, got: %q", i, tc.want, got)
+		}
+	}
+}
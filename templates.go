@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// Templates holds Go text/template strings for names and texts gemer creates on GitHub.
+// An empty template falls back to the one of DefaultTemplates
+type Templates struct {
+	Branch, PullRequestTitle, PullRequestBody, ReleaseName, ReleaseBody string
+}
+
+// DefaultTemplates are the templates gemer uses by default
+var DefaultTemplates = &Templates{
+	Branch:           "bumps_up_to_{{.NextVersion}}",
+	PullRequestTitle: "Bumps up to {{.NextVersion}}",
+	PullRequestBody:  "Bumps up to {{.NextVersion}}",
+	ReleaseName:      "Release {{.NextTag}}",
+	ReleaseBody:      "{{.NextTag}} will include the changes below!\n\n{{.ReleaseNotes}}",
+}
+
+// TemplateData is the data templates can refer to
+type TemplateData struct {
+	CurrentVersion, NextVersion string
+	CurrentTag, NextTag         string
+
+	// Branch is the branch the release is based on, and BumpBranch is the branch of the pull request
+	Branch, BumpBranch string
+
+	Commits      []*ComparedCommit
+	PullRequests []*github.PullRequest
+	ReleaseNotes string
+
+	// Date is the date of the release in the form of YYYY-MM-DD
+	Date string
+}
+
+// RenderedTemplates holds the results of Templates
+type RenderedTemplates struct {
+	Branch, PullRequestTitle, PullRequestBody, ReleaseName, ReleaseBody string
+}
+
+// Validate parses the templates and renders them with sample data, so that errors are found before any changes
+func (t *Templates) Validate() error {
+	sample := &TemplateData{
+		CurrentVersion: "1.2.3",
+		NextVersion:    "1.2.4",
+		CurrentTag:     "v1.2.3",
+		NextTag:        "v1.2.4",
+		Branch:         "master",
+		Commits:        []*ComparedCommit{{SHA: "d6ed804c", Author: "shuheiktgw", Message: "Fix foo", HTMLURL: "https://github.com/o/r/commit/d6ed804c"}},
+		PullRequests:   []*github.PullRequest{{Number: github.Int(1), Title: github.String("Fix foo")}},
+		ReleaseNotes:   "## Bug Fixes\n- Fix foo",
+		Date:           "2018-07-01",
+	}
+
+	_, err := t.Render(sample)
+
+	return err
+}
+
+// Render renders the templates with data. BumpBranch of data is filled with the rendered branch name
+func (t *Templates) Render(data *TemplateData) (*RenderedTemplates, error) {
+	var err error
+	r := &RenderedTemplates{}
+
+	if r.Branch, err = renderTemplate("branch", t.Branch, DefaultTemplates.Branch, data); err != nil {
+		return nil, err
+	}
+
+	if strings.ContainsAny(r.Branch, " \t\n~^:?*[\\") || strings.Contains(r.Branch, "..") {
+		return nil, errors.Errorf("invalid branch template: rendered branch name is not a valid git ref: %q", r.Branch)
+	}
+
+	data.BumpBranch = r.Branch
+
+	if r.PullRequestTitle, err = renderTemplate("pull request title", t.PullRequestTitle, DefaultTemplates.PullRequestTitle, data); err != nil {
+		return nil, err
+	}
+
+	if r.PullRequestBody, err = renderTemplate("pull request body", t.PullRequestBody, DefaultTemplates.PullRequestBody, data); err != nil {
+		return nil, err
+	}
+
+	if r.ReleaseName, err = renderTemplate("release name", t.ReleaseName, DefaultTemplates.ReleaseName, data); err != nil {
+		return nil, err
+	}
+
+	if r.ReleaseBody, err = renderTemplate("release body", t.ReleaseBody, DefaultTemplates.ReleaseBody, data); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func renderTemplate(name, text, defaultText string, data *TemplateData) (string, error) {
+	if len(text) == 0 {
+		text = defaultText
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid %s template", name)
+	}
+
+	var b bytes.Buffer
+
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render %s template", name)
+	}
+
+	rendered := strings.TrimSpace(b.String())
+
+	if len(rendered) == 0 {
+		return "", errors.Errorf("invalid %s template: rendered %s is empty", name, name)
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func testTemplateData() *TemplateData {
+	return &TemplateData{
+		CurrentVersion: "0.1.0",
+		NextVersion:    "0.2.0",
+		CurrentTag:     "v0.1.0",
+		NextTag:        "v0.2.0",
+		Branch:         "master",
+		Commits:        []*ComparedCommit{{SHA: "d6ed804c", Author: "shuheiktgw", Message: "feat: add foo"}},
+		PullRequests:   []*github.PullRequest{testPullRequest(1, "enhancement")},
+		ReleaseNotes:   "## Features\n- Add foo",
+		Date:           "2018-07-01",
+	}
+}
+
+func TestTemplatesRenderDefault(t *testing.T) {
+	r, err := (&Templates{}).Render(testTemplateData())
+
+	if err != nil {
+		t.Fatalf("Render failed: %s", err)
+	}
+
+	want := &RenderedTemplates{
+		Branch:           "bumps_up_to_0.2.0",
+		PullRequestTitle: "Bumps up to 0.2.0",
+		PullRequestBody:  "Bumps up to 0.2.0",
+		ReleaseName:      "Release v0.2.0",
+		ReleaseBody:      "v0.2.0 will include the changes below!\n\n## Features\n- Add foo",
+	}
+
+	if *r != *want {
+		t.Fatalf("invalid rendered templates: want: %+v, got: %+v", want, r)
+	}
+}
+
+func TestTemplatesRenderCustom(t *testing.T) {
+	templates := &Templates{
+		Branch:           "release/{{.NextTag}}",
+		PullRequestTitle: "Release {{.NextVersion}} on {{.Date}}",
+		PullRequestBody:  "Merge {{.BumpBranch}} into {{.Branch}}{{range .PullRequests}}\n- #{{.GetNumber}}{{end}}",
+		ReleaseName:      "{{.NextTag}} ({{len .Commits}} commits since {{.CurrentTag}})",
+	}
+
+	r, err := templates.Render(testTemplateData())
+
+	if err != nil {
+		t.Fatalf("Render failed: %s", err)
+	}
+
+	want := &RenderedTemplates{
+		Branch:           "release/v0.2.0",
+		PullRequestTitle: "Release 0.2.0 on 2018-07-01",
+		PullRequestBody:  "Merge release/v0.2.0 into master\n- #1",
+		ReleaseName:      "v0.2.0 (1 commits since v0.1.0)",
+		ReleaseBody:      "v0.2.0 will include the changes below!\n\n## Features\n- Add foo",
+	}
+
+	if *r != *want {
+		t.Fatalf("invalid rendered templates: want: %+v, got: %+v", want, r)
+	}
+}
+
+func TestTemplatesValidateFail(t *testing.T) {
+	cases := []*Templates{
+		{PullRequestTitle: "Bumps up to {{.NextVersion"},
+		{ReleaseName: "Release {{.Unknown}}"},
+		{ReleaseBody: "{{if false}}body{{end}}"},
+		{Branch: "bumps up to {{.NextVersion}}"},
+		{Branch: "{{.CurrentTag}}..{{.NextTag}}"},
+	}
+
+	for i, tc := range cases {
+		if err := tc.Validate(); err == nil {
+			t.Fatalf("#%d Validate is supposed to fail: %+v", i, tc)
+		}
+	}
+}
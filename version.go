@@ -18,17 +18,20 @@ const Version = "0.0.1"
 const Owner = "shuheiktgw"
 
 // OutputVersion outputs current version of gemer. It also checks
-// the latest release and adds a warning to update gemer
-func OutputVersion() string {
+// the latest release and adds a warning to update gemer. apiURL is
+// the url of GitHub API to check it on GitHub Enterprise Server
+func OutputVersion(apiURL string) string {
 	var b bytes.Buffer
 	fmt.Fprintf(&b, "%s current version v%s\n", Name, Version)
 
 	// Get the latest release
 	verCheckCh := make(chan *latest.CheckResponse)
 	go func() {
-		githubTag := &latest.GithubTag{
-			Owner:      Owner,
-			Repository: Name,
+		githubTag, err := latestGithubTag(apiURL)
+
+		// Ignore the error
+		if err != nil {
+			return
 		}
 
 		res, err := latest.Check(githubTag, Version)
@@ -50,4 +53,27 @@ func OutputVersion() string {
 	}
 
 	return b.String()
+}
+
+// latestGithubTag returns the source of the latest release of gemer on GitHub
+// API at apiURL, which is github.com if it is empty
+func latestGithubTag(apiURL string) (*latest.GithubTag, error) {
+	githubTag := &latest.GithubTag{
+		Owner:      Owner,
+		Repository: Name,
+	}
+
+	if WebHost(apiURL) == GitHubHost {
+		return githubTag, nil
+	}
+
+	base, _, err := enterpriseURLs(apiURL, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	githubTag.URL = base.String()
+
+	return githubTag, nil
 }
\ No newline at end of file
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VersionFile is the name of a plain text file which only contains the version of a gem
+const VersionFile = "VERSION"
+
+// VersionSource represents a file which declares the version of a gem
+type VersionSource interface {
+	// Path returns a path to the file from the root of the gem
+	Path() string
+
+	// Extract extracts the current version from the content of the file
+	Extract(content string) (string, error)
+
+	// Rewrite replaces the current version in the content of the file with next
+	Rewrite(content, next string) (string, error)
+}
+
+// VersionRBSource is a version.rb file which assigns the version to a constant
+type VersionRBSource struct {
+	FilePath, Constant string
+}
+
+// GemspecSource is a .gemspec file which assigns the version to `spec.version` directly
+type GemspecSource struct {
+	FilePath string
+}
+
+// PlainVersionSource is a plain text file such as VERSION, which is usually read by a .gemspec file
+type PlainVersionSource struct {
+	FilePath string
+}
+
+// NewVersionSource creates a VersionSource suitable for a given path
+func NewVersionSource(filePath, constant string) (VersionSource, error) {
+	if len(filePath) == 0 {
+		return nil, errors.New("missing version file path")
+	}
+
+	switch {
+	case strings.HasSuffix(filePath, ".gemspec"):
+		return &GemspecSource{FilePath: filePath}, nil
+	case path.Base(filePath) == VersionFile:
+		return &PlainVersionSource{FilePath: filePath}, nil
+	case strings.HasSuffix(filePath, ".rb"):
+		return &VersionRBSource{FilePath: filePath, Constant: constant}, nil
+	}
+
+	return nil, errors.Errorf("invalid version file path: version file must be a .rb file, a .gemspec file or a %s file: invalid path: %s", VersionFile, filePath)
+}
+
+// versionRBCandidates returns paths where version.rb is conventionally placed for a given gem name,
+// e.g. lib/foo_bar/version.rb and lib/foo/bar/version.rb for foo_bar and foo-bar
+func versionRBCandidates(name string) []string {
+	name = strings.ToLower(name)
+	candidates := []string{path.Join("lib", name, "version.rb")}
+
+	for _, n := range []string{strings.Replace(name, "-", "/", -1), strings.Replace(name, "-", "_", -1)} {
+		if c := path.Join("lib", n, "version.rb"); c != candidates[0] {
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates
+}
+
+func (s *VersionRBSource) Path() string {
+	return s.FilePath
+}
+
+func (s *VersionRBSource) Extract(content string) (string, error) {
+	return extractVersion(content, s.Constant)
+}
+
+func (s *VersionRBSource) Rewrite(content, next string) (string, error) {
+	return rewriteVersion(content, s.Constant, next)
+}
+
+func (s *GemspecSource) Path() string {
+	return s.FilePath
+}
+
+func (s *GemspecSource) Extract(content string) (string, error) {
+	return extractLiteral(content, "spec.version", matchAttribute("version"))
+}
+
+func (s *GemspecSource) Rewrite(content, next string) (string, error) {
+	return rewriteLiteral(content, "spec.version", matchAttribute("version"), next)
+}
+
+func (s *PlainVersionSource) Path() string {
+	return s.FilePath
+}
+
+func (s *PlainVersionSource) Extract(content string) (string, error) {
+	v := strings.TrimSpace(content)
+
+	if len(v) == 0 || strings.ContainsAny(v, " \t\r\n") {
+		return "", errors.Errorf("%s must contain only a version: content: %q", s.FilePath, content)
+	}
+
+	return v, nil
+}
+
+func (s *PlainVersionSource) Rewrite(content, next string) (string, error) {
+	current, err := s.Extract(content)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Replace(content, current, next, 1), nil
+}
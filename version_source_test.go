@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNewVersionSourceSuccess(t *testing.T) {
+	cases := []struct {
+		path string
+		want VersionSource
+	}{
+		{path: "lib/foo/version.rb", want: &VersionRBSource{FilePath: "lib/foo/version.rb", Constant: DefaultConstant}},
+		{path: "foo.gemspec", want: &GemspecSource{FilePath: "foo.gemspec"}},
+		{path: "VERSION", want: &PlainVersionSource{FilePath: "VERSION"}},
+	}
+
+	for i, tc := range cases {
+		got, err := NewVersionSource(tc.path, DefaultConstant)
+
+		if err != nil {
+			t.Fatalf("#%d NewVersionSource failed: %s", i, err)
+		}
+
+		if got.Path() != tc.want.Path() {
+			t.Fatalf("#%d invalid path: want: %s, got: %s", i, tc.want.Path(), got.Path())
+		}
+	}
+}
+
+func TestNewVersionSourceFail(t *testing.T) {
+	cases := []string{"", "README.md", "lib/foo"}
+
+	for i, tc := range cases {
+		if _, err := NewVersionSource(tc, DefaultConstant); err == nil {
+			t.Fatalf("#%d NewVersionSource is supposed to fail: path: %s", i, tc)
+		}
+	}
+}
+
+func TestVersionSourceRewrite(t *testing.T) {
+	cases := []struct {
+		source                 VersionSource
+		content, current, want string
+	}{
+		{
+			source:  &VersionRBSource{FilePath: "lib/foo/version.rb", Constant: DefaultConstant},
+			content: "module Foo\n  VERSION = '1.4.2'.freeze\nend\n",
+			current: "1.4.2",
+			want:    "module Foo\n  VERSION = '1.4.3'.freeze\nend\n",
+		},
+		{
+			source:  &GemspecSource{FilePath: "foo.gemspec"},
+			content: "Gem::Specification.new do |spec|\n  spec.name = 'foo'\n  spec.version = \"1.4.2\"\n  spec.required_ruby_version = '>= 2.3'\nend\n",
+			current: "1.4.2",
+			want:    "Gem::Specification.new do |spec|\n  spec.name = 'foo'\n  spec.version = \"1.4.3\"\n  spec.required_ruby_version = '>= 2.3'\nend\n",
+		},
+		{
+			source:  &GemspecSource{FilePath: "foo.gemspec"},
+			content: "Gem::Specification.new do |s|\n  s.version     = '1.4.2'\nend\n",
+			current: "1.4.2",
+			want:    "Gem::Specification.new do |s|\n  s.version     = '1.4.3'\nend\n",
+		},
+		{
+			source:  &PlainVersionSource{FilePath: "VERSION"},
+			content: "1.4.2\n",
+			current: "1.4.2",
+			want:    "1.4.3\n",
+		},
+	}
+
+	for i, tc := range cases {
+		current, err := tc.source.Extract(tc.content)
+
+		if err != nil {
+			t.Fatalf("#%d Extract failed: %s", i, err)
+		}
+
+		if current != tc.current {
+			t.Fatalf("#%d invalid version: want: %s, got: %s", i, tc.current, current)
+		}
+
+		got, err := tc.source.Rewrite(tc.content, "1.4.3")
+
+		if err != nil {
+			t.Fatalf("#%d Rewrite failed: %s", i, err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("#%d invalid content: want: %q, got: %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestVersionSourceExtractFail(t *testing.T) {
+	cases := []struct {
+		source  VersionSource
+		content string
+	}{
+		{source: &GemspecSource{FilePath: "foo.gemspec"}, content: "Gem::Specification.new do |spec|\n  spec.version = Foo::VERSION\nend\n"},
+		{source: &GemspecSource{FilePath: "foo.gemspec"}, content: "Gem::Specification.new do |spec|\n  spec.name = 'foo'\nend\n"},
+		{source: &PlainVersionSource{FilePath: "VERSION"}, content: ""},
+		{source: &PlainVersionSource{FilePath: "VERSION"}, content: "1.4.2\n1.4.3\n"},
+	}
+
+	for i, tc := range cases {
+		if _, err := tc.source.Extract(tc.content); err == nil {
+			t.Fatalf("#%d Extract is supposed to fail", i)
+		}
+	}
+}
+
+func TestVersionRBCandidates(t *testing.T) {
+	got := versionRBCandidates("Foo-Bar")
+	want := []string{"lib/foo-bar/version.rb", "lib/foo/bar/version.rb", "lib/foo_bar/version.rb"}
+
+	if len(got) != len(want) {
+		t.Fatalf("invalid candidates: want: %v, got: %v", want, got)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("invalid candidates: want: %v, got: %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLatestGithubTag(t *testing.T) {
+	cases := []struct {
+		apiURL, want string
+	}{
+		{apiURL: "", want: ""},
+		{apiURL: "https://api.github.com/", want: ""},
+		{apiURL: "https://github.example.com", want: "https://github.example.com/api/v3/"},
+		{apiURL: "https://github.example.com/api/v3/", want: "https://github.example.com/api/v3/"},
+	}
+
+	for i, tc := range cases {
+		tag, err := latestGithubTag(tc.apiURL)
+
+		if err != nil {
+			t.Fatalf("#%d latestGithubTag failed: %s", i, err)
+		}
+
+		if tag.Owner != Owner || tag.Repository != Name || tag.URL != tc.want {
+			t.Fatalf("#%d invalid tag: want url: %q, got: %+v", i, tc.want, tag)
+		}
+	}
+
+	if _, err := latestGithubTag("ftp://github.example.com"); err == nil {
+		t.Fatalf("latestGithubTag is supposed to fail")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlEntry is a scalar of a YAML document. Key is the path to the scalar joined with dots, e.g. templates.branch
+type yamlEntry struct {
+	Key, Value   string
+	Line, Column int
+}
+
+// yamlError is an error of a YAML document with its position, Line and Column are zero if they are unknown
+type yamlError struct {
+	File         string
+	Line, Column int
+	Message      string
+}
+
+func (e *yamlError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+
+	if e.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// parseYAML parses a YAML document of nested mappings of scalars, which gemer config files are. Null values are omitted
+func parseYAML(file, content string) ([]*yamlEntry, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, newYAMLError(file, err)
+	}
+
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+
+	if root.Kind != yaml.MappingNode {
+		return nil, &yamlError{File: file, Line: root.Line, Column: root.Column, Message: "expected `key: value`"}
+	}
+
+	var entries []*yamlEntry
+
+	if err := flattenYAML(file, "", root, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// flattenYAML appends the scalars of a mapping to entries, prefixing their keys with prefix
+func flattenYAML(file, prefix string, mapping *yaml.Node, entries *[]*yamlEntry) error {
+	seen := make(map[string]bool)
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		k, v := mapping.Content[i], mapping.Content[i+1]
+		key := k.Value
+
+		if len(prefix) != 0 {
+			key = prefix + "." + key
+		}
+
+		if seen[key] {
+			return &yamlError{File: file, Line: k.Line, Column: k.Column, Message: fmt.Sprintf("duplicated key %q", key)}
+		}
+
+		seen[key] = true
+
+		if v.Kind == yaml.AliasNode {
+			v = v.Alias
+		}
+
+		switch {
+		case v.Kind == yaml.MappingNode:
+			if err := flattenYAML(file, key, v, entries); err != nil {
+				return err
+			}
+		case v.Kind == yaml.ScalarNode && v.Tag == "!!null":
+			continue
+		case v.Kind == yaml.ScalarNode:
+			*entries = append(*entries, &yamlEntry{Key: key, Value: v.Value, Line: v.Line, Column: v.Column})
+		default:
+			return &yamlError{File: file, Line: v.Line, Column: v.Column, Message: fmt.Sprintf("%s must be a string or a mapping", key)}
+		}
+	}
+
+	return nil
+}
+
+// newYAMLError converts an error of the YAML library, whose message is like `yaml: line 2: ...`, into yamlError
+func newYAMLError(file string, err error) error {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	var line int
+
+	if n, _ := fmt.Sscanf(message, "line %d:", &line); n == 1 {
+		message = strings.TrimSpace(message[strings.Index(message, ":")+1:])
+	}
+
+	return &yamlError{File: file, Line: line, Message: message}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseYAMLSuccess(t *testing.T) {
+	content := `---
+# gemer config
+username: shuheiktgw
+repository: "gemer" # comment
+branch: 'it''s'
+zero_breaking_minor: false
+empty:
+templates:
+  branch: release/{{.NextTag}}
+  release_body: |
+    {{.NextTag}} is out!
+
+    {{.ReleaseNotes}}
+  pr_body: |-
+    Bumps up
+path: ~
+`
+
+	entries, err := parseYAML(".gemer.yml", content)
+
+	if err != nil {
+		t.Fatalf("parseYAML failed: %s", err)
+	}
+
+	want := []yamlEntry{
+		{Key: "username", Value: "shuheiktgw", Line: 3, Column: 11},
+		{Key: "repository", Value: "gemer", Line: 4, Column: 13},
+		{Key: "branch", Value: "it's", Line: 5, Column: 9},
+		{Key: "zero_breaking_minor", Value: "false", Line: 6, Column: 22},
+		{Key: "templates.branch", Value: "release/{{.NextTag}}", Line: 9, Column: 11},
+		{Key: "templates.release_body", Value: "{{.NextTag}} is out!\n\n{{.ReleaseNotes}}\n", Line: 10, Column: 17},
+		{Key: "templates.pr_body", Value: "Bumps up", Line: 14, Column: 12},
+	}
+
+	if len(entries) != len(want) {
+		t.Fatalf("invalid number of entries: want: %d, got: %d", len(want), len(entries))
+	}
+
+	for i, e := range entries {
+		if *e != want[i] {
+			t.Fatalf("#%d invalid entry: want: %+v, got: %+v", i, want[i], *e)
+		}
+	}
+}
+
+func TestParseYAMLFail(t *testing.T) {
+	cases := []struct {
+		content string
+		want    string
+	}{
+		{content: "username shuheiktgw", want: ".gemer.yml:1:1: expected `key: value`"},
+		{content: "username: a\n  branch: b", want: ".gemer.yml:2: mapping values are not allowed in this context"},
+		{content: "username: a\nusername: b", want: ".gemer.yml:2:1: duplicated key \"username\""},
+		{content: "templates:\n  branch: a\n  branch: b", want: ".gemer.yml:3:3: duplicated key \"templates.branch\""},
+		{content: "branch: \"master", want: ".gemer.yml: found unexpected end of stream"},
+		{content: "templates:\n  - foo", want: ".gemer.yml:2:3: templates must be a string or a mapping"},
+		{content: "branch: [master]", want: ".gemer.yml:1:9: branch must be a string or a mapping"},
+		{content: "\tbranch: master", want: ".gemer.yml: found character that cannot start any token"},
+	}
+
+	for i, tc := range cases {
+		_, err := parseYAML(".gemer.yml", tc.content)
+
+		if err == nil {
+			t.Fatalf("#%d parseYAML is supposed to fail: %q", i, tc.content)
+		}
+
+		if err.Error() != tc.want {
+			t.Fatalf("#%d invalid error: want: %q, got: %q", i, tc.want, err.Error())
+		}
+	}
+}